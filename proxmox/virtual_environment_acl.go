@@ -5,14 +5,20 @@
 package proxmox
 
 import (
+	"context"
 	"errors"
 	"sort"
 )
 
 // GetACL retrieves the access control list.
 func (c *VirtualEnvironmentClient) GetACL() ([]*VirtualEnvironmentACLGetResponseData, error) {
+	return c.GetACLWithContext(context.Background())
+}
+
+// GetACLWithContext is like GetACL but uses the specified context.
+func (c *VirtualEnvironmentClient) GetACLWithContext(ctx context.Context) ([]*VirtualEnvironmentACLGetResponseData, error) {
 	resBody := &VirtualEnvironmentACLGetResponseBody{}
-	err := c.DoRequest(hmGET, "access/acl", nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, "access/acl", nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -31,5 +37,10 @@ func (c *VirtualEnvironmentClient) GetACL() ([]*VirtualEnvironmentACLGetResponse
 
 // UpdateACL updates the access control list.
 func (c *VirtualEnvironmentClient) UpdateACL(d *VirtualEnvironmentACLUpdateRequestBody) error {
-	return c.DoRequest(hmPUT, "access/acl", d, nil)
+	return c.UpdateACLWithContext(context.Background(), d)
+}
+
+// UpdateACLWithContext is like UpdateACL but uses the specified context.
+func (c *VirtualEnvironmentClient) UpdateACLWithContext(ctx context.Context, d *VirtualEnvironmentACLUpdateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPUT, "access/acl", d, nil)
 }
@@ -6,40 +6,120 @@ package proxmox
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 )
 
 const (
 	// DefaultRootAccount contains the default username and realm for the root account.
 	DefaultRootAccount = "root@pam"
+
+	authenticationTicketLifetime     = 2 * time.Hour
+	authenticationTicketRenewalDelay = 15 * time.Minute
 )
 
 // Authenticate authenticates against the specified endpoint.
 func (c *VirtualEnvironmentClient) Authenticate(reset bool) error {
-	if c.authenticationData != nil && !reset {
+	return c.AuthenticateWithContext(context.Background(), reset)
+}
+
+// AuthenticateRequest adds authentication data to a new request.
+func (c *VirtualEnvironmentClient) AuthenticateRequest(req *http.Request) error {
+	if c.APIToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("PVEAPIToken=%s", c.APIToken))
+
 		return nil
 	}
 
-	body := bytes.NewBufferString(fmt.Sprintf("username=%s&password=%s", url.QueryEscape(c.Username), url.QueryEscape(c.Password)))
-	req, err := http.NewRequest(hmPOST, fmt.Sprintf("%s/%s/access/ticket", c.Endpoint, basePathJSONAPI), body)
+	err := c.AuthenticateWithContext(req.Context(), false)
 
 	if err != nil {
-		return errors.New("Failed to create authentication request")
+		return err
 	}
 
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	c.authenticationMutex.Lock()
+	csrfPreventionToken := *c.authenticationData.CSRFPreventionToken
+	ticket := *c.authenticationData.Ticket
+	c.authenticationMutex.Unlock()
 
-	res, err := c.httpClient.Do(req)
+	req.AddCookie(&http.Cookie{
+		Name:  "PVEAuthCookie",
+		Value: ticket,
+	})
 
-	if err != nil {
-		return errors.New("Failed to retrieve authentication response")
+	if req.Method != "GET" {
+		req.Header.Add("CSRFPreventionToken", csrfPreventionToken)
+	}
+
+	return nil
+}
+
+// AuthenticateWithContext is like Authenticate but uses the specified context.
+func (c *VirtualEnvironmentClient) AuthenticateWithContext(ctx context.Context, reset bool) error {
+	if c.APIToken != "" {
+		return nil
+	}
+
+	c.authenticationMutex.Lock()
+	defer c.authenticationMutex.Unlock()
+
+	if c.authenticationData != nil && !reset {
+		// Tickets expire after two hours, so we renew them a bit before that happens.
+		if time.Since(c.authenticationTime) < authenticationTicketLifetime-authenticationTicketRenewalDelay {
+			return nil
+		}
+
+		log.Printf("[DEBUG] Renewing the authentication ticket for the Proxmox Virtual Environment API")
+	}
+
+	return c.authenticate(ctx)
+}
+
+// authenticate requests a new ticket from the server. The caller must hold the authentication mutex.
+func (c *VirtualEnvironmentClient) authenticate(ctx context.Context) error {
+	var res *http.Response
+
+	authenticationTime := time.Now()
+
+	for failovers := 0; ; failovers++ {
+		body := bytes.NewBufferString(fmt.Sprintf("username=%s&password=%s", url.QueryEscape(c.Username), url.QueryEscape(c.Password)))
+		endpoint := c.getEndpoint()
+		req, err := http.NewRequestWithContext(ctx, hmPOST, fmt.Sprintf("%s/%s/access/ticket", endpoint, basePathJSONAPI), body)
+
+		if err != nil {
+			return errors.New("Failed to create authentication request")
+		}
+
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+		res, err = c.httpClient.Do(req)
+
+		if err == nil {
+			break
+		}
+
+		if isContextError(err) {
+			return err
+		}
+
+		// Requesting a ticket has no side effects, which is why any of the remaining endpoints can be tried.
+		c.failover(endpoint)
+
+		if failovers >= len(c.Endpoints)-1 {
+			return errors.New("Failed to retrieve authentication response")
+		}
 	}
 
-	err = c.ValidateResponseCode(res)
+	defer res.Body.Close()
+
+	err := c.ValidateResponseCode(res)
 
 	if err != nil {
 		return err
@@ -69,26 +149,39 @@ func (c *VirtualEnvironmentClient) Authenticate(reset bool) error {
 	}
 
 	c.authenticationData = resBody.Data
+	c.authenticationTime = authenticationTime
 
 	return nil
 }
 
-// AuthenticateRequest adds authentication data to a new request.
-func (c *VirtualEnvironmentClient) AuthenticateRequest(req *http.Request) error {
-	err := c.Authenticate(false)
+// reauthenticate requests a new ticket after the server has rejected the one used by a request.
+// Concurrent callers share a single renewal, as the ticket is only replaced if it is still the rejected one.
+func (c *VirtualEnvironmentClient) reauthenticate(rejectedReq *http.Request) error {
+	c.authenticationMutex.Lock()
+	defer c.authenticationMutex.Unlock()
 
-	if err != nil {
-		return err
+	rejectedTicket, err := rejectedReq.Cookie("PVEAuthCookie")
+
+	if err == nil && c.authenticationData != nil && *c.authenticationData.Ticket != rejectedTicket.Value {
+		return nil
 	}
 
-	req.AddCookie(&http.Cookie{
-		Name:  "PVEAuthCookie",
-		Value: *c.authenticationData.Ticket,
-	})
+	return c.authenticate(rejectedReq.Context())
+}
 
-	if req.Method != "GET" {
-		req.Header.Add("CSRFPreventionToken", *c.authenticationData.CSRFPreventionToken)
+// parseAPIToken validates an API token (user@realm!tokenid=secret) and returns the user, which includes the realm (user@realm).
+func parseAPIToken(apiToken string) (*string, error) {
+	tokenParts := strings.SplitN(apiToken, "=", 2)
+
+	if len(tokenParts) != 2 || tokenParts[1] == "" {
+		return nil, errors.New("You must specify a valid API token for the Proxmox Virtual Environment API (valid: username@realm!tokenid=secret)")
 	}
 
-	return nil
+	idParts := strings.SplitN(tokenParts[0], "!", 2)
+
+	if len(idParts) != 2 || idParts[1] == "" || !strings.Contains(idParts[0], "@") {
+		return nil, errors.New("You must specify a valid API token for the Proxmox Virtual Environment API (valid: username@realm!tokenid=secret)")
+	}
+
+	return &idParts[0], nil
 }
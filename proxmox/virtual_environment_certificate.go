@@ -5,6 +5,7 @@
 package proxmox
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -12,13 +13,23 @@ import (
 
 // DeleteCertificate deletes the custom certificate for a node.
 func (c *VirtualEnvironmentClient) DeleteCertificate(nodeName string, d *VirtualEnvironmentCertificateDeleteRequestBody) error {
-	return c.DoRequest(hmDELETE, fmt.Sprintf("nodes/%s/certificates/custom", url.PathEscape(nodeName)), d, nil)
+	return c.DeleteCertificateWithContext(context.Background(), nodeName, d)
+}
+
+// DeleteCertificateWithContext is like DeleteCertificate but uses the specified context.
+func (c *VirtualEnvironmentClient) DeleteCertificateWithContext(ctx context.Context, nodeName string, d *VirtualEnvironmentCertificateDeleteRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmDELETE, fmt.Sprintf("nodes/%s/certificates/custom", url.PathEscape(nodeName)), d, nil)
 }
 
 // ListCertificates retrieves the list of certificates for a node.
 func (c *VirtualEnvironmentClient) ListCertificates(nodeName string) (*[]VirtualEnvironmentCertificateListResponseData, error) {
+	return c.ListCertificatesWithContext(context.Background(), nodeName)
+}
+
+// ListCertificatesWithContext is like ListCertificates but uses the specified context.
+func (c *VirtualEnvironmentClient) ListCertificatesWithContext(ctx context.Context, nodeName string) (*[]VirtualEnvironmentCertificateListResponseData, error) {
 	resBody := &VirtualEnvironmentCertificateListResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("nodes/%s/certificates/info", url.PathEscape(nodeName)), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/certificates/info", url.PathEscape(nodeName)), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -33,5 +44,10 @@ func (c *VirtualEnvironmentClient) ListCertificates(nodeName string) (*[]Virtual
 
 // UpdateCertificate updates the custom certificate for a node.
 func (c *VirtualEnvironmentClient) UpdateCertificate(nodeName string, d *VirtualEnvironmentCertificateUpdateRequestBody) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/certificates/custom", url.PathEscape(nodeName)), d, nil)
+	return c.UpdateCertificateWithContext(context.Background(), nodeName, d)
+}
+
+// UpdateCertificateWithContext is like UpdateCertificate but uses the specified context.
+func (c *VirtualEnvironmentClient) UpdateCertificateWithContext(ctx context.Context, nodeName string, d *VirtualEnvironmentCertificateUpdateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/certificates/custom", url.PathEscape(nodeName)), d, nil)
 }
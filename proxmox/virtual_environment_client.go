@@ -6,65 +6,174 @@ package proxmox
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/google/go-querystring/query"
 )
 
 // NewVirtualEnvironmentClient creates and initializes a VirtualEnvironmentClient instance.
-func NewVirtualEnvironmentClient(endpoint, username, password string, insecure bool) (*VirtualEnvironmentClient, error) {
-	url, err := url.ParseRequestURI(endpoint)
+func NewVirtualEnvironmentClient(endpoint, username, password, apiToken string, insecure bool) (*VirtualEnvironmentClient, error) {
+	parsedEndpoint, err := parseEndpoint(endpoint)
 
 	if err != nil {
-		return nil, errors.New("You must specify a valid endpoint for the Proxmox Virtual Environment API (valid: https://host:port/)")
+		return nil, err
 	}
 
-	if url.Scheme != "https" {
-		return nil, errors.New("You must specify a secure endpoint for the Proxmox Virtual Environment API (valid: https://host:port/)")
-	}
+	if apiToken != "" {
+		tokenUsername, err := parseAPIToken(apiToken)
 
-	if password == "" {
-		return nil, errors.New("You must specify a password for the Proxmox Virtual Environment API")
-	}
+		if err != nil {
+			return nil, err
+		}
+
+		if username == "" {
+			username = *tokenUsername
+		}
+	} else {
+		if password == "" {
+			return nil, errors.New("You must specify a password or an API token for the Proxmox Virtual Environment API")
+		}
 
-	if username == "" {
-		return nil, errors.New("You must specify a username for the Proxmox Virtual Environment API")
+		if username == "" {
+			return nil, errors.New("You must specify a username or an API token for the Proxmox Virtual Environment API")
+		}
 	}
 
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: insecure,
-			},
-		},
+	sshHostKeyVerifier, err := newSSHHostKeyVerifier(&VirtualEnvironmentSSHHostKeyPolicy{})
+
+	if err != nil {
+		return nil, err
 	}
 
+	httpClient := newHTTPClient(&tls.Config{
+		InsecureSkipVerify: insecure,
+	})
+
 	return &VirtualEnvironmentClient{
-		Endpoint:   strings.TrimRight(url.String(), "/"),
-		Insecure:   insecure,
-		Password:   password,
-		Username:   username,
-		httpClient: httpClient,
+		APIToken:  apiToken,
+		Endpoint:  *parsedEndpoint,
+		Endpoints: []string{*parsedEndpoint},
+		Insecure:  insecure,
+		Password:  password,
+		RetryPolicy: &VirtualEnvironmentRetryPolicy{
+			Attempts: DefaultRetryAttempts,
+			WaitMax:  DefaultRetryWaitMax,
+			WaitMin:  DefaultRetryWaitMin,
+		},
+		Username:           username,
+		httpClient:         httpClient,
+		sshHostKeyVerifier: sshHostKeyVerifier,
+		sshSettings:        &VirtualEnvironmentSSHSettings{},
 	}, nil
 }
 
+// ConfigureTLS verifies the server certificate against a PEM encoded CA bundle and/or a list of SHA-256 fingerprints.
+// Multiple fingerprints can be specified, as every node in a cluster has its own certificate.
+func (c *VirtualEnvironmentClient) ConfigureTLS(caBundle []byte, fingerprints []string) error {
+	if len(caBundle) == 0 && len(fingerprints) == 0 {
+		return nil
+	}
+
+	if c.Insecure {
+		return errors.New("You cannot specify a CA bundle or a certificate fingerprint when TLS verification is disabled")
+	}
+
+	tlsConfig := &tls.Config{}
+
+	var rootCAs *x509.CertPool
+
+	if len(caBundle) > 0 {
+		rootCAs = x509.NewCertPool()
+
+		if !rootCAs.AppendCertsFromPEM(caBundle) {
+			return errors.New("The CA bundle does not contain any valid PEM encoded certificates")
+		}
+
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if len(fingerprints) > 0 {
+		expectedFingerprints := make([][]byte, len(fingerprints))
+
+		for i, fingerprint := range fingerprints {
+			expectedFingerprint, err := parseCertificateFingerprint(fingerprint)
+
+			if err != nil {
+				return err
+			}
+
+			expectedFingerprints[i] = expectedFingerprint
+		}
+
+		// The pinned fingerprints replace the default verification, which would otherwise reject self-signed certificates.
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("The server did not present a certificate")
+			}
+
+			actualFingerprint := sha256.Sum256(rawCerts[0])
+			matched := false
+
+			for _, expectedFingerprint := range expectedFingerprints {
+				if bytes.Equal(actualFingerprint[:], expectedFingerprint) {
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return fmt.Errorf(
+					"The server certificate fingerprint %s does not match any of the expected fingerprints",
+					formatCertificateFingerprint(actualFingerprint[:]),
+				)
+			}
+
+			if rootCAs == nil {
+				return nil
+			}
+
+			return c.verifyCertificateChain(rawCerts, rootCAs)
+		}
+	}
+
+	c.httpClient = newHTTPClient(tlsConfig)
+
+	return nil
+}
+
 // DoRequest performs a HTTP request against a JSON API endpoint.
 func (c *VirtualEnvironmentClient) DoRequest(method, path string, requestBody interface{}, responseBody interface{}) error {
+	return c.DoRequestWithContext(context.Background(), method, path, requestBody, responseBody)
+}
+
+// DoRequestWithContext is like DoRequest but uses the specified context.
+func (c *VirtualEnvironmentClient) DoRequestWithContext(ctx context.Context, method, path string, requestBody interface{}, responseBody interface{}) error {
+	var reqBodyBytes []byte
 	var reqBodyReader io.Reader
 	var reqContentLength *int64
 
 	log.Printf("[DEBUG] Performing HTTP %s request (path: %s)", method, path)
 
+	loggedPath := path
 	modifiedPath := path
+	reqBodyReplayable := true
 	reqBodyType := ""
 
 	if requestBody != nil {
@@ -73,19 +182,21 @@ func (c *VirtualEnvironmentClient) DoRequest(method, path string, requestBody in
 
 		if multipart {
 			reqBodyReader = multipartData.Reader
+			reqBodyReplayable = false
 			reqBodyType = fmt.Sprintf("multipart/form-data; boundary=%s", multipartData.Boundary)
 			reqContentLength = multipartData.Size
 
-			log.Printf("[DEBUG] Added multipart request body to HTTP %s request (path: %s)", method, modifiedPath)
+			log.Printf("[DEBUG] Added multipart request body to HTTP %s request (path: %s)", method, loggedPath)
 		} else if pipedBody {
 			reqBodyReader = pipedBodyReader
+			reqBodyReplayable = false
 
-			log.Printf("[DEBUG] Added piped request body to HTTP %s request (path: %s)", method, modifiedPath)
+			log.Printf("[DEBUG] Added piped request body to HTTP %s request (path: %s)", method, loggedPath)
 		} else {
 			v, err := query.Values(requestBody)
 
 			if err != nil {
-				fErr := fmt.Errorf("Failed to encode HTTP %s request (path: %s) - Reason: %s", method, modifiedPath, err.Error())
+				fErr := fmt.Errorf("Failed to encode HTTP %s request (path: %s) - Reason: %s", method, loggedPath, err.Error())
 				log.Printf("[DEBUG] WARNING: %s", fErr.Error())
 				return fErr
 			}
@@ -100,95 +211,399 @@ func (c *VirtualEnvironmentClient) DoRequest(method, path string, requestBody in
 						modifiedPath = fmt.Sprintf("%s&%s", modifiedPath, encodedValues)
 					}
 				} else {
-					reqBodyReader = bytes.NewBufferString(encodedValues)
+					reqBodyBytes = []byte(encodedValues)
 					reqBodyType = "application/x-www-form-urlencoded"
 				}
 
-				log.Printf("[DEBUG] Added request body to HTTP %s request (path: %s) - Body: %s", method, modifiedPath, encodedValues)
+				loggedPath = redactPath(modifiedPath)
+
+				log.Printf("[DEBUG] Added request body to HTTP %s request (path: %s) - Body: %s", method, loggedPath, redactValues(v))
 			}
 		}
 	} else {
-		reqBodyReader = new(bytes.Buffer)
+		reqBodyBytes = []byte{}
 	}
 
-	req, err := http.NewRequest(method, fmt.Sprintf("%s/%s/%s", c.Endpoint, basePathJSONAPI, modifiedPath), reqBodyReader)
+	attempt := 1
+	failovers := 0
+	reauthenticated := false
 
-	if err != nil {
-		fErr := fmt.Errorf("Failed to create HTTP %s request (path: %s) - Reason: %s", method, modifiedPath, err.Error())
-		log.Printf("[DEBUG] WARNING: %s", fErr.Error())
-		return fErr
-	}
+	for {
+		if reqBodyBytes != nil {
+			reqBodyReader = bytes.NewReader(reqBodyBytes)
+		}
 
-	req.Header.Add("Accept", "application/json")
+		endpoint := c.getEndpoint()
+		req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/%s/%s", endpoint, basePathJSONAPI, modifiedPath), reqBodyReader)
 
-	if reqContentLength != nil {
-		req.ContentLength = *reqContentLength
-	}
+		if err != nil {
+			fErr := fmt.Errorf("Failed to create HTTP %s request (path: %s) - Reason: %s", method, loggedPath, err.Error())
+			log.Printf("[DEBUG] WARNING: %s", fErr.Error())
+			return fErr
+		}
 
-	if reqBodyType != "" {
-		req.Header.Add("Content-Type", reqBodyType)
-	}
+		req.Header.Add("Accept", "application/json")
 
-	err = c.AuthenticateRequest(req)
+		if reqContentLength != nil {
+			req.ContentLength = *reqContentLength
+		}
 
-	if err != nil {
-		log.Printf("[DEBUG] WARNING: %s", err.Error())
-		return err
-	}
+		if reqBodyType != "" {
+			req.Header.Add("Content-Type", reqBodyType)
+		}
 
-	res, err := c.httpClient.Do(req)
+		err = c.AuthenticateRequest(req)
 
-	if err != nil {
-		fErr := fmt.Errorf("Failed to perform HTTP %s request (path: %s) - Reason: %s", method, modifiedPath, err.Error())
-		log.Printf("[DEBUG] WARNING: %s", fErr.Error())
-		return fErr
-	}
+		if err != nil {
+			log.Printf("[DEBUG] WARNING: %s", err.Error())
+			return err
+		}
 
-	defer res.Body.Close()
+		// The slot is only held for a single attempt, as retries would otherwise block other requests while waiting.
+		release, err := acquireSemaphore(ctx, c.requestSemaphore)
 
-	err = c.ValidateResponseCode(res)
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		log.Printf("[DEBUG] WARNING: %s", err.Error())
-		return err
-	}
+		log.Printf("[DEBUG] Sending HTTP %s request (path: %s) - Headers: %s", method, loggedPath, redactHeaders(req.Header))
 
-	if responseBody != nil {
-		err = json.NewDecoder(res.Body).Decode(responseBody)
+		res, err := c.httpClient.Do(req)
 
 		if err != nil {
-			fErr := fmt.Errorf("Failed to decode HTTP %s response (path: %s) - Reason: %s", method, modifiedPath, err.Error())
+			release()
+
+			fErr := fmt.Errorf("Failed to perform HTTP %s request (path: %s) - Reason: %s", method, loggedPath, err.Error())
+
+			// The remaining endpoints are tried immediately, as they are likely to be unaffected by the error.
+			if !isContextError(err) {
+				c.failover(endpoint)
+
+				if reqBodyReplayable && failovers < len(c.Endpoints)-1 && canReplayRequest(method, err) {
+					log.Printf("[DEBUG] WARNING: %s (endpoint %d of %d)", fErr.Error(), failovers+1, len(c.Endpoints))
+
+					failovers++
+
+					continue
+				}
+			}
+
+			if reqBodyReplayable && c.shouldRetryRequest(method, attempt, nil, err) {
+				log.Printf("[DEBUG] WARNING: %s (attempt %d)", fErr.Error(), attempt)
+
+				err = c.waitForRetry(ctx, attempt)
+
+				if err != nil {
+					return err
+				}
+
+				attempt++
+
+				continue
+			}
+
 			log.Printf("[DEBUG] WARNING: %s", fErr.Error())
 			return fErr
 		}
-	} else {
-		data, _ := ioutil.ReadAll(res.Body)
-		log.Printf("[DEBUG] WARNING: Unhandled HTTP response body: %s", string(data))
-	}
 
-	return nil
+		log.Printf("[DEBUG] Received an HTTP %d response for HTTP %s request (path: %s) - Headers: %s", res.StatusCode, method, loggedPath, redactHeaders(res.Header))
+
+		// The ticket may have expired or been revoked, in which case we need to re-authenticate and replay the request once.
+		if res.StatusCode == http.StatusUnauthorized && !reauthenticated && reqBodyReplayable && c.APIToken == "" {
+			res.Body.Close()
+			release()
+
+			reauthenticated = true
+
+			log.Printf("[DEBUG] Received an HTTP 401 response for HTTP %s request (path: %s) - Re-authenticating", method, loggedPath)
+
+			err = c.reauthenticate(req)
+
+			if err != nil {
+				log.Printf("[DEBUG] WARNING: %s", err.Error())
+				return err
+			}
+
+			continue
+		}
+
+		if reqBodyReplayable && c.shouldRetryRequest(method, attempt, res, nil) {
+			res.Body.Close()
+			release()
+
+			log.Printf("[DEBUG] WARNING: Received an HTTP %d response for HTTP %s request (path: %s) - Retrying (attempt %d)", res.StatusCode, method, loggedPath, attempt)
+
+			err = c.waitForRetry(ctx, attempt)
+
+			if err != nil {
+				return err
+			}
+
+			attempt++
+
+			continue
+		}
+
+		defer release()
+		defer res.Body.Close()
+
+		err = c.ValidateResponseCode(res)
+
+		if err != nil {
+			log.Printf("[DEBUG] WARNING: %s", err.Error())
+			return err
+		}
+
+		if responseBody != nil {
+			err = json.NewDecoder(res.Body).Decode(responseBody)
+
+			if err != nil {
+				fErr := fmt.Errorf("Failed to decode HTTP %s response (path: %s) - Reason: %s", method, loggedPath, err.Error())
+				log.Printf("[DEBUG] WARNING: %s", fErr.Error())
+				return fErr
+			}
+		} else {
+			data, _ := ioutil.ReadAll(res.Body)
+			log.Printf("[DEBUG] WARNING: Unhandled HTTP response body: %s", redactJSON(data))
+		}
+
+		return nil
+	}
 }
 
 // ValidateResponseCode ensures that a response is valid.
+// An *APIError is returned for responses with a status code outside the 2xx range.
 func (c *VirtualEnvironmentClient) ValidateResponseCode(res *http.Response) error {
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		status := strings.TrimPrefix(res.Status, fmt.Sprintf("%d ", res.StatusCode))
+		apiErr := &APIError{
+			Errors:     map[string]string{},
+			Message:    strings.TrimPrefix(res.Status, fmt.Sprintf("%d ", res.StatusCode)),
+			StatusCode: res.StatusCode,
+		}
+
+		if res.Request != nil {
+			apiErr.Method = res.Request.Method
+			apiErr.Path = strings.TrimPrefix(res.Request.URL.Path, fmt.Sprintf("/%s/", basePathJSONAPI))
+		}
 
 		errRes := &VirtualEnvironmentErrorResponseBody{}
 		err := json.NewDecoder(res.Body).Decode(errRes)
 
 		if err == nil && errRes.Errors != nil {
-			errList := []string{}
-
 			for k, v := range *errRes.Errors {
-				errList = append(errList, fmt.Sprintf("%s: %s", k, strings.TrimRight(v, "\n\r")))
+				apiErr.Errors[k] = strings.TrimRight(v, "\n\r")
 			}
-
-			status = fmt.Sprintf("%s (%s)", status, strings.Join(errList, " - "))
 		}
 
-		return fmt.Errorf("Received an HTTP %d response - Reason: %s", res.StatusCode, status)
+		return apiErr
 	}
 
 	return nil
 }
+
+// shouldRetryRequest determines whether a failed request can safely be retried.
+// Idempotent requests are retried on connection errors and gateway errors, while other requests are only retried
+// when the server is known to have rejected them before performing any changes.
+func (c *VirtualEnvironmentClient) shouldRetryRequest(method string, attempt int, res *http.Response, err error) bool {
+	if c.RetryPolicy == nil || attempt >= c.RetryPolicy.Attempts {
+		return false
+	}
+
+	if isContextError(err) {
+		return false
+	}
+
+	if err != nil {
+		return canReplayRequest(method, err)
+	}
+
+	idempotent := isIdempotentMethod(method)
+
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	case http.StatusInternalServerError:
+		// The cluster filesystem reports "got timeout" when a lock cannot be acquired, which happens before any changes are made.
+		return strings.Contains(res.Status, "got timeout")
+	}
+
+	return false
+}
+
+// canReplayRequest determines whether a request, which failed due to a connection error, can safely be sent again.
+func canReplayRequest(method string, err error) bool {
+	if isIdempotentMethod(method) {
+		return true
+	}
+
+	// Failing to establish a connection means that the request was never sent.
+	opErr := &net.OpError{}
+
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isContextError determines whether an error was caused by a cancelled context or an exceeded deadline.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// isIdempotentMethod determines whether repeating a request with the specified method has the same effect as sending it once.
+func isIdempotentMethod(method string) bool {
+	return method == hmDELETE || method == hmGET || method == hmHEAD || method == hmPUT
+}
+
+// verifyCertificateChain verifies a certificate chain against a set of root CAs and the hostnames of the endpoints.
+func (c *VirtualEnvironmentClient) verifyCertificateChain(rawCerts [][]byte, rootCAs *x509.CertPool) error {
+	certs := make([]*x509.Certificate, len(rawCerts))
+
+	for i, rawCert := range rawCerts {
+		cert, err := x509.ParseCertificate(rawCert)
+
+		if err != nil {
+			return err
+		}
+
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	// The hostname of the current connection is not available, which is why the certificate must be valid for one of the endpoints.
+	err := errors.New("The server certificate is not valid for any of the endpoints")
+
+	for _, endpoint := range c.Endpoints {
+		endpointURL, parseErr := url.Parse(endpoint)
+
+		if parseErr != nil {
+			continue
+		}
+
+		_, err = certs[0].Verify(x509.VerifyOptions{
+			DNSName:       endpointURL.Hostname(),
+			Intermediates: intermediates,
+			Roots:         rootCAs,
+		})
+
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// waitForRetry sleeps for an exponentially increasing amount of time with jitter before the next attempt.
+func (c *VirtualEnvironmentClient) waitForRetry(ctx context.Context, attempt int) error {
+	delay := c.RetryPolicy.WaitMin
+
+	for i := 1; i < attempt && delay < c.RetryPolicy.WaitMax; i++ {
+		delay *= 2
+	}
+
+	if delay > c.RetryPolicy.WaitMax {
+		delay = c.RetryPolicy.WaitMax
+	}
+
+	if delay > 0 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+
+	return sleepWithContext(ctx, delay)
+}
+
+// sleepWithContext pauses the current goroutine for a duration or until the context is done.
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// IsAlreadyExists determines whether an error was caused by a resource which already exists.
+func IsAlreadyExists(err error) bool {
+	apiErr := &APIError{}
+
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if strings.Contains(apiErr.Message, "already exists") {
+		return true
+	}
+
+	for _, v := range apiErr.Errors {
+		if strings.Contains(v, "already exists") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsLocked determines whether an error was caused by a locked resource, e.g. a virtual machine being cloned.
+func IsLocked(err error) bool {
+	apiErr := &APIError{}
+
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		return false
+	}
+
+	return strings.Contains(apiErr.Message, "is locked") || strings.Contains(apiErr.Message, "can't lock file")
+}
+
+// IsNotFound determines whether an error was caused by a resource which does not exist.
+func IsNotFound(err error) bool {
+	apiErr := &APIError{}
+
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if apiErr.StatusCode == http.StatusNotFound {
+		return true
+	}
+
+	// Most endpoints report missing resources with a generic server error.
+	return apiErr.StatusCode == http.StatusInternalServerError &&
+		(strings.Contains(apiErr.Message, "does not exist") || strings.HasPrefix(apiErr.Message, "no such "))
+}
+
+// formatCertificateFingerprint formats a certificate fingerprint the same way as the Proxmox Virtual Environment GUI.
+func formatCertificateFingerprint(fingerprint []byte) string {
+	hexPairs := make([]string, len(fingerprint))
+
+	for i, b := range fingerprint {
+		hexPairs[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return strings.Join(hexPairs, ":")
+}
+
+// newHTTPClient creates a HTTP client which uses the specified TLS configuration.
+func newHTTPClient(tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+}
+
+// parseCertificateFingerprint parses a SHA-256 fingerprint with or without colon separators.
+func parseCertificateFingerprint(fingerprint string) ([]byte, error) {
+	fingerprintBytes, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+
+	if err != nil || len(fingerprintBytes) != sha256.Size {
+		return nil, errors.New("You must specify a valid SHA-256 certificate fingerprint (valid: AB:CD:...)")
+	}
+
+	return fingerprintBytes, nil
+}
@@ -0,0 +1,84 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// SetEndpoints sets the endpoints for the Proxmox Virtual Environment API.
+// The endpoints are tried in order, whenever the current endpoint cannot be reached.
+func (c *VirtualEnvironmentClient) SetEndpoints(endpoints []string) error {
+	if len(endpoints) == 0 {
+		return errors.New("You must specify at least one endpoint for the Proxmox Virtual Environment API")
+	}
+
+	parsedEndpoints := make([]string, len(endpoints))
+
+	for i, endpoint := range endpoints {
+		parsedEndpoint, err := parseEndpoint(endpoint)
+
+		if err != nil {
+			return err
+		}
+
+		parsedEndpoints[i] = *parsedEndpoint
+	}
+
+	c.endpointMutex.Lock()
+	defer c.endpointMutex.Unlock()
+
+	c.Endpoint = parsedEndpoints[0]
+	c.Endpoints = parsedEndpoints
+	c.endpointIndex = 0
+
+	return nil
+}
+
+// failover switches to the next endpoint, unless another request has already done so.
+func (c *VirtualEnvironmentClient) failover(failedEndpoint string) {
+	c.endpointMutex.Lock()
+	defer c.endpointMutex.Unlock()
+
+	if len(c.Endpoints) < 2 || c.Endpoints[c.endpointIndex] != failedEndpoint {
+		return
+	}
+
+	c.endpointIndex = (c.endpointIndex + 1) % len(c.Endpoints)
+
+	log.Printf("[DEBUG] WARNING: Failed to reach endpoint %s - Switching to endpoint %s", failedEndpoint, c.Endpoints[c.endpointIndex])
+}
+
+// getEndpoint returns the endpoint, which most recently responded to a request.
+func (c *VirtualEnvironmentClient) getEndpoint() string {
+	c.endpointMutex.Lock()
+	defer c.endpointMutex.Unlock()
+
+	if len(c.Endpoints) == 0 {
+		return c.Endpoint
+	}
+
+	return c.Endpoints[c.endpointIndex]
+}
+
+// parseEndpoint validates an endpoint and returns it without a trailing slash.
+func parseEndpoint(endpoint string) (*string, error) {
+	u, err := url.ParseRequestURI(endpoint)
+
+	if err != nil {
+		return nil, errors.New("You must specify a valid endpoint for the Proxmox Virtual Environment API (valid: https://host:port/)")
+	}
+
+	if u.Scheme != "https" {
+		return nil, errors.New("You must specify a secure endpoint for the Proxmox Virtual Environment API (valid: https://host:port/)")
+	}
+
+	parsedEndpoint := strings.TrimRight(u.String(), "/")
+
+	return &parsedEndpoint, nil
+}
@@ -0,0 +1,41 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"context"
+)
+
+// SetConcurrencyLimits limits the number of API requests and SSH sessions, which can be active at the same time.
+// A limit of zero disables the corresponding limit.
+func (c *VirtualEnvironmentClient) SetConcurrencyLimits(maxRequests, maxSSHSessions int) {
+	c.requestSemaphore = newSemaphore(maxRequests)
+	c.sshSemaphore = newSemaphore(maxSSHSessions)
+}
+
+// acquireSemaphore waits for a free slot in a semaphore and returns a function, which releases the slot again.
+func acquireSemaphore(ctx context.Context, semaphore chan struct{}) (func(), error) {
+	if semaphore == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case semaphore <- struct{}{}:
+		return func() {
+			<-semaphore
+		}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// newSemaphore creates a semaphore with the specified number of slots or nil, if the number is zero.
+func newSemaphore(size int) chan struct{} {
+	if size <= 0 {
+		return nil
+	}
+
+	return make(chan struct{}, size)
+}
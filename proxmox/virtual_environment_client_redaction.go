@@ -0,0 +1,134 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	redactedValue = "REDACTED"
+)
+
+var (
+	// redactedFields contains the names of the form and JSON fields, which may contain secrets.
+	redactedFields = map[string]bool{
+		"cipassword":          true,
+		"csrfpreventiontoken": true,
+		"key":                 true,
+		"keys":                true,
+		"password":            true,
+		"ticket":              true,
+	}
+
+	// redactedHeaders contains the names of the headers, which may contain secrets.
+	redactedHeaders = map[string]bool{
+		"Authorization":       true,
+		"Cookie":              true,
+		"Csrfpreventiontoken": true,
+		"Set-Cookie":          true,
+	}
+)
+
+// redactHeaders returns a loggable representation of HTTP headers with the sensitive values masked.
+func redactHeaders(headers http.Header) string {
+	keys := make([]string, 0, len(headers))
+
+	for k := range headers {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	headerList := make([]string, len(keys))
+
+	for i, k := range keys {
+		value := strings.Join(headers[k], ", ")
+
+		if redactedHeaders[http.CanonicalHeaderKey(k)] {
+			value = redactedValue
+		}
+
+		headerList[i] = fmt.Sprintf("%s: %s", k, value)
+	}
+
+	return strings.Join(headerList, " - ")
+}
+
+// redactJSON returns a loggable representation of a JSON document with the sensitive values masked.
+func redactJSON(data []byte) string {
+	var document interface{}
+
+	err := json.Unmarshal(data, &document)
+
+	if err != nil {
+		return string(data)
+	}
+
+	redactedData, err := json.Marshal(redactJSONValue(document))
+
+	if err != nil {
+		return string(data)
+	}
+
+	return string(redactedData)
+}
+
+// redactJSONValue masks the sensitive values in a decoded JSON document.
+func redactJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, fv := range v {
+			if redactedFields[strings.ToLower(k)] {
+				v[k] = redactedValue
+			} else {
+				v[k] = redactJSONValue(fv)
+			}
+		}
+	case []interface{}:
+		for i, iv := range v {
+			v[i] = redactJSONValue(iv)
+		}
+	}
+
+	return value
+}
+
+// redactPath returns a loggable representation of a request path with the sensitive query values masked.
+func redactPath(path string) string {
+	pathParts := strings.SplitN(path, "?", 2)
+
+	if len(pathParts) < 2 {
+		return path
+	}
+
+	values, err := url.ParseQuery(pathParts[1])
+
+	if err != nil {
+		return pathParts[0]
+	}
+
+	return fmt.Sprintf("%s?%s", pathParts[0], redactValues(values))
+}
+
+// redactValues returns a loggable representation of encoded form values with the sensitive values masked.
+func redactValues(values url.Values) string {
+	redactedValues := url.Values{}
+
+	for k, v := range values {
+		if redactedFields[strings.ToLower(k)] {
+			redactedValues[k] = []string{redactedValue}
+		} else {
+			redactedValues[k] = v
+		}
+	}
+
+	return redactedValues.Encode()
+}
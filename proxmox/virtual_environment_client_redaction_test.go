@@ -0,0 +1,148 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+const (
+	testSecret = "s3cr3t-value"
+)
+
+// testCaptureLog captures the log output produced by a function.
+func testCaptureLog(f func()) string {
+	buf := &bytes.Buffer{}
+
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	f()
+
+	return buf.String()
+}
+
+// testRedactedRequest performs a request and verifies that the secret does not appear in the log output.
+func testRedactedRequest(t *testing.T, c *VirtualEnvironmentClient, method string, requestBody interface{}) {
+	output := testCaptureLog(func() {
+		err := c.DoRequest(method, "test", requestBody, nil)
+
+		if err != nil {
+			t.Fatalf("Expected the request to succeed - got: %s", err.Error())
+		}
+	})
+
+	if strings.Contains(output, testSecret) {
+		t.Fatalf("Expected the secret to be redacted from the log output - got: %s", output)
+	}
+
+	if !strings.Contains(output, redactedValue) {
+		t.Fatalf("Expected the log output to contain the redacted value - got: %s", output)
+	}
+}
+
+// TestVirtualEnvironmentClientDoRequestRedactsRequestBodies tests whether secrets are redacted from logged request bodies.
+func TestVirtualEnvironmentClientDoRequestRedactsRequestBodies(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":null}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	secret := testSecret
+
+	tests := map[string]interface{}{
+		"certificate": &VirtualEnvironmentCertificateUpdateRequestBody{
+			Certificates: "-----BEGIN CERTIFICATE-----",
+			PrivateKey:   &secret,
+		},
+		"cloud-init": &VirtualEnvironmentVMCreateRequestBody{
+			CloudInitConfig: &CustomCloudInitConfig{
+				Password: &secret,
+			},
+		},
+		"container": &VirtualEnvironmentContainerCreateRequestBody{
+			Password: &secret,
+		},
+		"user password": &VirtualEnvironmentUserChangePasswordRequestBody{
+			ID:       "test@pve",
+			Password: secret,
+		},
+		"user creation": &VirtualEnvironmentUserCreateRequestBody{
+			ID:       "test@pve",
+			Keys:     &secret,
+			Password: secret,
+		},
+		"user update": &VirtualEnvironmentUserUpdateRequestBody{
+			Keys: &secret,
+		},
+	}
+
+	for name, requestBody := range tests {
+		t.Run(name, func(t *testing.T) {
+			testRedactedRequest(t, c, hmPOST, requestBody)
+		})
+	}
+
+	t.Run("query", func(t *testing.T) {
+		testRedactedRequest(t, c, hmGET, &VirtualEnvironmentUserChangePasswordRequestBody{
+			ID:       "test@pve",
+			Password: secret,
+		})
+	})
+}
+
+// TestVirtualEnvironmentClientDoRequestRedactsHeaders tests whether the ticket cookie and the CSRF token are redacted from logged headers.
+func TestVirtualEnvironmentClientDoRequestRedactsHeaders(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api2/json/access/ticket" {
+			w.Write([]byte(`{"data":{"CSRFPreventionToken":"` + testSecret + `","ticket":"` + testSecret + `","username":"root@pam"}}`))
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{Name: "PVEAuthCookie", Value: testSecret})
+		w.Write([]byte(`{"data":null}`))
+	}))
+	defer server.Close()
+
+	c, err := NewVirtualEnvironmentClient(server.URL, "root@pam", "password", "", true)
+
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+
+	testRedactedRequest(t, c, hmPOST, nil)
+}
+
+// TestVirtualEnvironmentClientDoRequestRedactsResponseBodies tests whether secrets are redacted from logged response bodies.
+func TestVirtualEnvironmentClientDoRequestRedactsResponseBodies(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"ticket":"` + testSecret + `","username":"root@pam"}}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+
+	testRedactedRequest(t, c, hmGET, nil)
+}
+
+// TestRedactHeaders tests whether API tokens are redacted from headers.
+func TestRedactHeaders(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Accept", "application/json")
+	headers.Set("Authorization", "PVEAPIToken=root@pam!test="+testSecret)
+
+	redactedHeaders := redactHeaders(headers)
+
+	if strings.Contains(redactedHeaders, testSecret) || !strings.Contains(redactedHeaders, "Accept: application/json") {
+		t.Fatalf("Expected only the Authorization header to be redacted - got: %s", redactedHeaders)
+	}
+}
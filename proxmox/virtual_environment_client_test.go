@@ -0,0 +1,686 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testNewVirtualEnvironmentClient creates a client which uses API token authentication against a test server.
+func testNewVirtualEnvironmentClient(t *testing.T, server *httptest.Server) *VirtualEnvironmentClient {
+	c, err := NewVirtualEnvironmentClient(server.URL, "", "", "root@pam!test=secret", true)
+
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+
+	c.RetryPolicy = &VirtualEnvironmentRetryPolicy{
+		Attempts: 3,
+		WaitMax:  5 * time.Millisecond,
+		WaitMin:  1 * time.Millisecond,
+	}
+
+	return c
+}
+
+// testWriteRawResponse writes a response with a custom reason phrase, which is how Proxmox VE reports most errors.
+func testWriteRawResponse(t *testing.T, w http.ResponseWriter, statusCode int, reason string) {
+	hj, ok := w.(http.Hijacker)
+
+	if !ok {
+		t.Fatalf("The test server does not support hijacking")
+	}
+
+	conn, buf, err := hj.Hijack()
+
+	if err != nil {
+		t.Fatalf("Failed to hijack connection: %s", err.Error())
+	}
+
+	defer conn.Close()
+
+	body := `{"data":null}`
+
+	fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\nContent-Type: application/json\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", statusCode, reason, len(body), body)
+	buf.Flush()
+}
+
+// TestVirtualEnvironmentClientDoRequestRetriesIdempotentRequests tests whether idempotent requests are retried on gateway errors.
+func TestVirtualEnvironmentClientDoRequestRetriesIdempotentRequests(t *testing.T) {
+	var requests int32
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(`{"data":{"version":"6.1"}}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	data, err := c.Version()
+
+	if err != nil {
+		t.Fatalf("Expected the request to succeed - got: %s", err.Error())
+	}
+
+	if data.Version != "6.1" {
+		t.Fatalf("Expected version \"6.1\" - got: \"%s\"", data.Version)
+	}
+
+	if requests != 3 {
+		t.Fatalf("Expected 3 requests - got: %d", requests)
+	}
+}
+
+// TestVirtualEnvironmentClientDoRequestStopsAfterMaxAttempts tests whether retries stop once the maximum number of attempts has been reached.
+func TestVirtualEnvironmentClientDoRequestStopsAfterMaxAttempts(t *testing.T) {
+	var requests int32
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	_, err := c.Version()
+
+	if err == nil {
+		t.Fatalf("Expected the request to fail")
+	}
+
+	if requests != 3 {
+		t.Fatalf("Expected 3 requests - got: %d", requests)
+	}
+}
+
+// TestVirtualEnvironmentClientDoRequestDoesNotRetryNonIdempotentRequests tests whether POST requests are not retried on gateway errors.
+func TestVirtualEnvironmentClientDoRequestDoesNotRetryNonIdempotentRequests(t *testing.T) {
+	var requests int32
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	_, err := c.StartVM("pve", 100)
+
+	if err == nil {
+		t.Fatalf("Expected the request to fail")
+	}
+
+	if requests != 1 {
+		t.Fatalf("Expected 1 request - got: %d", requests)
+	}
+}
+
+// TestVirtualEnvironmentClientDoRequestRetriesLockTimeouts tests whether POST requests are retried when a lock could not be acquired.
+func TestVirtualEnvironmentClientDoRequestRetriesLockTimeouts(t *testing.T) {
+	var requests int32
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 2 {
+			testWriteRawResponse(t, w, http.StatusInternalServerError, "can't lock file '/var/lock/qemu-server/lock-100.conf' - got timeout")
+			return
+		}
+
+		w.Write([]byte(`{"data":"UPID:pve:00001234:00005678:5E8F0A1B:qmstart:100:root@pam:"}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	_, err := c.StartVM("pve", 100)
+
+	if err != nil {
+		t.Fatalf("Expected the request to succeed - got: %s", err.Error())
+	}
+
+	if requests != 2 {
+		t.Fatalf("Expected 2 requests - got: %d", requests)
+	}
+}
+
+// TestVirtualEnvironmentClientDoRequestDoesNotRetryClientErrors tests whether requests are not retried on client errors.
+func TestVirtualEnvironmentClientDoRequestDoesNotRetryClientErrors(t *testing.T) {
+	var requests int32
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	_, err := c.Version()
+
+	if err == nil {
+		t.Fatalf("Expected the request to fail")
+	}
+
+	if requests != 1 {
+		t.Fatalf("Expected 1 request - got: %d", requests)
+	}
+}
+
+// TestVirtualEnvironmentClientDoRequestReauthenticates tests whether an expired ticket is renewed and the request replayed.
+func TestVirtualEnvironmentClientDoRequestReauthenticates(t *testing.T) {
+	var tickets int32
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api2/json/access/ticket" {
+			ticket := atomic.AddInt32(&tickets, 1)
+
+			fmt.Fprintf(w, `{"data":{"CSRFPreventionToken":"token","ticket":"ticket-%d","username":"root@pam"}}`, ticket)
+			return
+		}
+
+		cookie, err := r.Cookie("PVEAuthCookie")
+
+		if err != nil || cookie.Value != "ticket-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{"data":{"version":"6.1"}}`))
+	}))
+	defer server.Close()
+
+	c, err := NewVirtualEnvironmentClient(server.URL, "root@pam", "password", "", true)
+
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+
+	_, err = c.Version()
+
+	if err != nil {
+		t.Fatalf("Expected the request to succeed - got: %s", err.Error())
+	}
+
+	if tickets != 2 {
+		t.Fatalf("Expected 2 tickets to be issued - got: %d", tickets)
+	}
+}
+
+// TestVirtualEnvironmentClientAuthenticateRenewsTickets tests whether tickets are renewed before they expire.
+func TestVirtualEnvironmentClientAuthenticateRenewsTickets(t *testing.T) {
+	var tickets int32
+
+	usedTicket := ""
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api2/json/access/ticket" {
+			ticket := atomic.AddInt32(&tickets, 1)
+
+			fmt.Fprintf(w, `{"data":{"CSRFPreventionToken":"token","ticket":"ticket-%d","username":"root@pam"}}`, ticket)
+			return
+		}
+
+		cookie, err := r.Cookie("PVEAuthCookie")
+
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		usedTicket = cookie.Value
+
+		w.Write([]byte(`{"data":{"version":"6.1"}}`))
+	}))
+	defer server.Close()
+
+	c, err := NewVirtualEnvironmentClient(server.URL, "root@pam", "password", "", true)
+
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+
+	_, err = c.Version()
+
+	if err != nil {
+		t.Fatalf("Expected the request to succeed - got: %s", err.Error())
+	}
+
+	if tickets != 1 || usedTicket != "ticket-1" {
+		t.Fatalf("Expected the first ticket to be used - got: %s (%d tickets)", usedTicket, tickets)
+	}
+
+	// A ticket, which is about to expire, must be renewed before the next request is sent.
+	c.authenticationTime = time.Now().Add(-authenticationTicketLifetime + authenticationTicketRenewalDelay - time.Minute)
+
+	_, err = c.Version()
+
+	if err != nil {
+		t.Fatalf("Expected the request to succeed - got: %s", err.Error())
+	}
+
+	if tickets != 2 || usedTicket != "ticket-2" {
+		t.Fatalf("Expected the ticket to be renewed - got: %s (%d tickets)", usedTicket, tickets)
+	}
+
+	_, err = c.Version()
+
+	if err != nil {
+		t.Fatalf("Expected the request to succeed - got: %s", err.Error())
+	}
+
+	if tickets != 2 {
+		t.Fatalf("Expected a recently issued ticket to be reused - got: %d tickets", tickets)
+	}
+}
+
+// TestVirtualEnvironmentClientDoRequestReauthenticatesOnce tests whether concurrent requests, which are rejected
+// with HTTP 401, share a single renewal of the ticket.
+func TestVirtualEnvironmentClientDoRequestReauthenticatesOnce(t *testing.T) {
+	var rejected, tickets int32
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api2/json/access/ticket" {
+			ticket := atomic.AddInt32(&tickets, 1)
+
+			fmt.Fprintf(w, `{"data":{"CSRFPreventionToken":"token","ticket":"ticket-%d","username":"root@pam"}}`, ticket)
+			return
+		}
+
+		cookie, err := r.Cookie("PVEAuthCookie")
+
+		if err != nil || cookie.Value != "ticket-2" {
+			atomic.AddInt32(&rejected, 1)
+			time.Sleep(20 * time.Millisecond)
+
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{"data":{"version":"6.1"}}`))
+	}))
+	defer server.Close()
+
+	c, err := NewVirtualEnvironmentClient(server.URL, "root@pam", "password", "", true)
+
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+
+	err = c.Authenticate(false)
+
+	if err != nil {
+		t.Fatalf("Failed to authenticate: %s", err.Error())
+	}
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := c.Version()
+
+			if err != nil {
+				t.Errorf("Expected the request to succeed - got: %s", err.Error())
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if rejected < 2 {
+		t.Fatalf("Expected multiple requests to be rejected - got: %d", rejected)
+	}
+
+	if tickets != 2 {
+		t.Fatalf("Expected the rejected requests to share a single renewal - got: %d tickets", tickets)
+	}
+}
+
+// TestVirtualEnvironmentClientAuthenticateRequestAPIToken tests whether requests are authenticated using an API token,
+// which neither requires a ticket nor a CSRF prevention token.
+func TestVirtualEnvironmentClientAuthenticateRequestAPIToken(t *testing.T) {
+	methods := []string{}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+
+		if r.URL.Path == "/api2/json/access/ticket" {
+			t.Errorf("Expected no ticket to be requested")
+		}
+
+		if r.Header.Get("Authorization") != "PVEAPIToken=root@pam!test=secret" {
+			t.Errorf("Expected the API token to be sent - got: %s", r.Header.Get("Authorization"))
+		}
+
+		if r.Header.Get("CSRFPreventionToken") != "" {
+			t.Errorf("Expected no CSRF prevention token to be sent for HTTP %s requests", r.Method)
+		}
+
+		if _, err := r.Cookie("PVEAuthCookie"); err == nil {
+			t.Errorf("Expected no ticket to be sent for HTTP %s requests", r.Method)
+		}
+
+		if r.Method == http.MethodPost {
+			w.Write([]byte(`{"data":"UPID:pve:00000001:00000001:00000001:qmstart:100:root@pam!test:"}`))
+			return
+		}
+
+		w.Write([]byte(`{"data":{"version":"6.1"}}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	_, err := c.Version()
+
+	if err != nil {
+		t.Fatalf("Expected the GET request to succeed - got: %s", err.Error())
+	}
+
+	_, err = c.StartVM("pve", 100)
+
+	if err != nil {
+		t.Fatalf("Expected the POST request to succeed - got: %s", err.Error())
+	}
+
+	if len(methods) != 2 || methods[0] != http.MethodGet || methods[1] != http.MethodPost {
+		t.Fatalf("Expected a GET and a POST request - got: %v", methods)
+	}
+}
+
+// TestVirtualEnvironmentClientValidateResponseCode tests whether error responses are converted to an *APIError.
+func TestVirtualEnvironmentClientValidateResponseCode(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"data":null,"errors":{"name":"invalid format\n"}}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	err := c.UpdateVM("pve", 100, &VirtualEnvironmentVMUpdateRequestBody{})
+
+	apiErr, ok := err.(*APIError)
+
+	if !ok {
+		t.Fatalf("Expected an *APIError - got: %v", err)
+	}
+
+	if apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d - got: %d", http.StatusBadRequest, apiErr.StatusCode)
+	}
+
+	if apiErr.Method != hmPUT || apiErr.Path != "nodes/pve/qemu/100/config" {
+		t.Fatalf("Expected the request to be \"PUT nodes/pve/qemu/100/config\" - got: \"%s %s\"", apiErr.Method, apiErr.Path)
+	}
+
+	if apiErr.Errors["name"] != "invalid format" {
+		t.Fatalf("Expected a field error for \"name\" - got: %v", apiErr.Errors)
+	}
+}
+
+// TestIsNotFound tests whether IsNotFound recognizes the responses for missing resources.
+func TestIsNotFound(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{&APIError{StatusCode: http.StatusNotFound}, true},
+		{&APIError{StatusCode: http.StatusInternalServerError, Message: "Configuration file 'nodes/pve/qemu-server/100.conf' does not exist"}, true},
+		{&APIError{StatusCode: http.StatusInternalServerError, Message: "no such user ('test@pve')"}, true},
+		{&APIError{StatusCode: http.StatusInternalServerError, Message: "VM is locked (clone)"}, false},
+		{fmt.Errorf("HTTP 404"), false},
+		{nil, false},
+	}
+
+	for i, test := range tests {
+		if IsNotFound(test.err) != test.expected {
+			t.Fatalf("Expected IsNotFound to return %t for test %d", test.expected, i)
+		}
+	}
+}
+
+// TestIsLocked tests whether IsLocked recognizes the responses for locked resources.
+func TestIsLocked(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{&APIError{StatusCode: http.StatusInternalServerError, Message: "VM is locked (clone)"}, true},
+		{&APIError{StatusCode: http.StatusInternalServerError, Message: "can't lock file '/var/lock/qemu-server/lock-100.conf' - got timeout"}, true},
+		{&APIError{StatusCode: http.StatusNotFound}, false},
+		{nil, false},
+	}
+
+	for i, test := range tests {
+		if IsLocked(test.err) != test.expected {
+			t.Fatalf("Expected IsLocked to return %t for test %d", test.expected, i)
+		}
+	}
+}
+
+// TestVirtualEnvironmentClientConfigureTLS tests whether the server certificate is verified against a CA bundle and a fingerprint.
+func TestVirtualEnvironmentClientConfigureTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"version":"6.1"}}`))
+	}))
+	defer server.Close()
+
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	fingerprint := sha256.Sum256(server.Certificate().Raw)
+
+	otherFingerprint := formatCertificateFingerprint(make([]byte, sha256.Size))
+
+	tests := []struct {
+		caBundle     []byte
+		fingerprints []string
+		expected     bool
+	}{
+		{nil, nil, false},
+		{caBundle, nil, true},
+		{nil, []string{formatCertificateFingerprint(fingerprint[:])}, true},
+		{nil, []string{hex.EncodeToString(fingerprint[:])}, true},
+		{nil, []string{otherFingerprint, formatCertificateFingerprint(fingerprint[:])}, true},
+		{caBundle, []string{formatCertificateFingerprint(fingerprint[:])}, true},
+		{nil, []string{otherFingerprint}, false},
+	}
+
+	for i, test := range tests {
+		c, err := NewVirtualEnvironmentClient(server.URL, "", "", "root@pam!test=secret", false)
+
+		if err != nil {
+			t.Fatalf("Failed to create client: %s", err.Error())
+		}
+
+		c.RetryPolicy = &VirtualEnvironmentRetryPolicy{Attempts: 1}
+
+		err = c.ConfigureTLS(test.caBundle, test.fingerprints)
+
+		if err != nil {
+			t.Fatalf("Failed to configure TLS for test %d: %s", i, err.Error())
+		}
+
+		_, err = c.Version()
+
+		if (err == nil) != test.expected {
+			t.Fatalf("Expected success to be %t for test %d - got: %v", test.expected, i, err)
+		}
+	}
+}
+
+// TestVirtualEnvironmentClientDoRequestFailover tests whether requests fail over to the next endpoint and keep using it.
+func TestVirtualEnvironmentClientDoRequestFailover(t *testing.T) {
+	var requests int32
+
+	unavailableServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unavailableServer.Close()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"data":{"version":"6.1"}}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	err := c.SetEndpoints([]string{unavailableServer.URL, server.URL})
+
+	if err != nil {
+		t.Fatalf("Failed to set endpoints: %s", err.Error())
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = c.Version()
+
+		if err != nil {
+			t.Fatalf("Expected the request to succeed - got: %s", err.Error())
+		}
+	}
+
+	if requests != 2 {
+		t.Fatalf("Expected 2 requests - got: %d", requests)
+	}
+
+	if c.getEndpoint() != server.URL {
+		t.Fatalf("Expected the client to remember endpoint \"%s\" - got: \"%s\"", server.URL, c.getEndpoint())
+	}
+}
+
+// TestVirtualEnvironmentClientAuthenticateFailover tests whether a ticket is requested from the next endpoint, if the current one is unavailable.
+func TestVirtualEnvironmentClientAuthenticateFailover(t *testing.T) {
+	unavailableServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unavailableServer.Close()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"CSRFPreventionToken":"token","ticket":"ticket","username":"root@pam"}}`))
+	}))
+	defer server.Close()
+
+	c, err := NewVirtualEnvironmentClient(unavailableServer.URL, "root@pam", "password", "", true)
+
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+
+	err = c.SetEndpoints([]string{unavailableServer.URL, server.URL})
+
+	if err != nil {
+		t.Fatalf("Failed to set endpoints: %s", err.Error())
+	}
+
+	err = c.Authenticate(false)
+
+	if err != nil {
+		t.Fatalf("Expected authentication to succeed - got: %s", err.Error())
+	}
+}
+
+// TestVirtualEnvironmentClientDoRequestConcurrencyLimit tests whether the number of concurrent requests is limited.
+func TestVirtualEnvironmentClientDoRequestConcurrencyLimit(t *testing.T) {
+	var active, maxActive int32
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+
+		for {
+			previous := atomic.LoadInt32(&maxActive)
+
+			if current <= previous || atomic.CompareAndSwapInt32(&maxActive, previous, current) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		w.Write([]byte(`{"data":{"version":"6.1"}}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	c.SetConcurrencyLimits(2, 0)
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := c.Version()
+
+			if err != nil {
+				t.Errorf("Expected the request to succeed - got: %s", err.Error())
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if maxActive > 2 {
+		t.Fatalf("Expected at most 2 concurrent requests - got: %d", maxActive)
+	}
+}
+
+// TestVirtualEnvironmentClientDoRequestConcurrencyLimitRetries tests whether requests, which wait for a retry, do not
+// occupy a slot, as that would block other requests.
+func TestVirtualEnvironmentClientDoRequestConcurrencyLimitRetries(t *testing.T) {
+	var retrying int32
+
+	rejected := make(chan struct{}, 3)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api2/json/nodes" {
+			rejected <- struct{}{}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(`{"data":{"version":"6.1"}}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	c.RetryPolicy.WaitMax = 200 * time.Millisecond
+	c.RetryPolicy.WaitMin = 200 * time.Millisecond
+	c.SetConcurrencyLimits(1, 0)
+
+	atomic.StoreInt32(&retrying, 1)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer atomic.StoreInt32(&retrying, 0)
+
+		c.DoRequest(hmGET, "nodes", nil, nil)
+	}()
+
+	<-rejected
+
+	_, err := c.Version()
+
+	if err != nil {
+		t.Fatalf("Expected the request to succeed - got: %s", err.Error())
+	}
+
+	if atomic.LoadInt32(&retrying) == 0 {
+		t.Fatalf("Expected the request to complete, while the other request is waiting for a retry")
+	}
+
+	<-done
+}
@@ -5,8 +5,15 @@
 package proxmox
 
 import (
+	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
 )
 
 const (
@@ -16,17 +23,50 @@ const (
 	hmHEAD          = "HEAD"
 	hmPOST          = "POST"
 	hmPUT           = "PUT"
+
+	// DefaultRetryAttempts contains the default maximum number of attempts for a request.
+	DefaultRetryAttempts = 5
+	// DefaultRetryWaitMax contains the default maximum delay between two attempts.
+	DefaultRetryWaitMax = 30 * time.Second
+	// DefaultRetryWaitMin contains the default minimum delay between two attempts.
+	DefaultRetryWaitMin = 1 * time.Second
 )
 
+// APIError contains the details of an error response from the Proxmox Virtual Environment API.
+type APIError struct {
+	Errors     map[string]string
+	Message    string
+	Method     string
+	Path       string
+	StatusCode int
+}
+
 // VirtualEnvironmentClient implements an API client for the Proxmox Virtual Environment API.
 type VirtualEnvironmentClient struct {
-	Endpoint string
-	Insecure bool
-	Password string
-	Username string
+	APIToken      string
+	Endpoint      string
+	Endpoints     []string
+	Insecure      bool
+	NodeAddresses map[string]string
+	Password      string
+	RetryPolicy   *VirtualEnvironmentRetryPolicy
+	Username      string
 
-	authenticationData *VirtualEnvironmentAuthenticationResponseData
-	httpClient         *http.Client
+	authenticationData  *VirtualEnvironmentAuthenticationResponseData
+	authenticationMutex sync.Mutex
+	authenticationTime  time.Time
+	endpointIndex       int
+	endpointMutex       sync.Mutex
+	httpClient          *http.Client
+	requestSemaphore    chan struct{}
+	sshHostKeyVerifier  *sshHostKeyVerifier
+	sshSemaphore        chan struct{}
+	sshSettings         *VirtualEnvironmentSSHSettings
+	sshSigner           ssh.Signer
+	vmIDMax             int
+	vmIDMin             int
+	vmIDMutex           sync.Mutex
+	vmIDReservations    map[int]bool
 }
 
 // VirtualEnvironmentErrorResponseBody contains the body of an error response.
@@ -41,3 +81,35 @@ type VirtualEnvironmentMultiPartData struct {
 	Reader   io.Reader
 	Size     *int64
 }
+
+// VirtualEnvironmentRetryPolicy controls how DoRequest retries requests which failed due to transient errors.
+type VirtualEnvironmentRetryPolicy struct {
+	Attempts int
+	WaitMax  time.Duration
+	WaitMin  time.Duration
+}
+
+// Error returns the error message.
+func (e *APIError) Error() string {
+	reason := e.Message
+
+	if len(e.Errors) > 0 {
+		keys := make([]string, 0, len(e.Errors))
+
+		for k := range e.Errors {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		errList := make([]string, len(keys))
+
+		for i, k := range keys {
+			errList[i] = fmt.Sprintf("%s: %s", k, e.Errors[k])
+		}
+
+		reason = fmt.Sprintf("%s (%s)", reason, strings.Join(errList, " - "))
+	}
+
+	return fmt.Sprintf("Received an HTTP %d response - Reason: %s", e.StatusCode, reason)
+}
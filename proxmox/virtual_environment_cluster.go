@@ -5,17 +5,23 @@
 package proxmox
 
 import (
+	"context"
 	"errors"
 )
 
 // GetClusterNextID retrieves the next free VM identifier for the cluster.
 func (c *VirtualEnvironmentClient) GetClusterNextID(vmID *int) (*int, error) {
+	return c.GetClusterNextIDWithContext(context.Background(), vmID)
+}
+
+// GetClusterNextIDWithContext is like GetClusterNextID but uses the specified context.
+func (c *VirtualEnvironmentClient) GetClusterNextIDWithContext(ctx context.Context, vmID *int) (*int, error) {
 	reqBody := &VirtualEnvironmentClusterNextIDRequestBody{
 		VMID: vmID,
 	}
 
 	resBody := &VirtualEnvironmentClusterNextIDResponseBody{}
-	err := c.DoRequest(hmGET, "cluster/nextid", reqBody, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, "cluster/nextid", reqBody, resBody)
 
 	if err != nil {
 		return nil, err
@@ -27,3 +33,24 @@ func (c *VirtualEnvironmentClient) GetClusterNextID(vmID *int) (*int, error) {
 
 	return (*int)(resBody.Data), nil
 }
+
+// GetClusterStatus retrieves the status of the cluster and its nodes.
+func (c *VirtualEnvironmentClient) GetClusterStatus() ([]*VirtualEnvironmentClusterStatusResponseData, error) {
+	return c.GetClusterStatusWithContext(context.Background())
+}
+
+// GetClusterStatusWithContext is like GetClusterStatus but uses the specified context.
+func (c *VirtualEnvironmentClient) GetClusterStatusWithContext(ctx context.Context) ([]*VirtualEnvironmentClusterStatusResponseData, error) {
+	resBody := &VirtualEnvironmentClusterStatusResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmGET, "cluster/status", nil, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a data object in the response")
+	}
+
+	return resBody.Data, nil
+}
@@ -13,3 +13,23 @@ type VirtualEnvironmentClusterNextIDRequestBody struct {
 type VirtualEnvironmentClusterNextIDResponseBody struct {
 	Data *CustomInt `json:"data,omitempty"`
 }
+
+// VirtualEnvironmentClusterStatusResponseBody contains the body from a cluster status response.
+type VirtualEnvironmentClusterStatusResponseBody struct {
+	Data []*VirtualEnvironmentClusterStatusResponseData `json:"data,omitempty"`
+}
+
+// VirtualEnvironmentClusterStatusResponseData contains the data from a cluster status response.
+type VirtualEnvironmentClusterStatusResponseData struct {
+	ID      string      `json:"id"`
+	IP      *string     `json:"ip,omitempty"`
+	Level   *string     `json:"level,omitempty"`
+	Local   *CustomBool `json:"local,omitempty"`
+	Name    string      `json:"name"`
+	NodeID  *int        `json:"nodeid,omitempty"`
+	Nodes   *int        `json:"nodes,omitempty"`
+	Online  *CustomBool `json:"online,omitempty"`
+	Quorate *CustomBool `json:"quorate,omitempty"`
+	Type    string      `json:"type"`
+	Version *int        `json:"version,omitempty"`
+}
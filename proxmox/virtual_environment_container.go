@@ -5,6 +5,7 @@
 package proxmox
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -12,25 +13,78 @@ import (
 	"time"
 )
 
-// CloneContainer clones a container.
-func (c *VirtualEnvironmentClient) CloneContainer(nodeName string, vmID int, d *VirtualEnvironmentContainerCloneRequestBody) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/lxc/%d/clone", url.PathEscape(nodeName), vmID), d, nil)
+// CloneContainer clones a container and returns the task identifier.
+func (c *VirtualEnvironmentClient) CloneContainer(nodeName string, vmID int, d *VirtualEnvironmentContainerCloneRequestBody) (*string, error) {
+	return c.CloneContainerWithContext(context.Background(), nodeName, vmID, d)
 }
 
-// CreateContainer creates a container.
-func (c *VirtualEnvironmentClient) CreateContainer(nodeName string, d *VirtualEnvironmentContainerCreateRequestBody) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/lxc", url.PathEscape(nodeName)), d, nil)
+// CloneContainerWithContext is like CloneContainer but uses the specified context.
+func (c *VirtualEnvironmentClient) CloneContainerWithContext(ctx context.Context, nodeName string, vmID int, d *VirtualEnvironmentContainerCloneRequestBody) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/lxc/%d/clone", url.PathEscape(nodeName), vmID), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
+}
+
+// CreateContainer creates a container and returns the task identifier.
+func (c *VirtualEnvironmentClient) CreateContainer(nodeName string, d *VirtualEnvironmentContainerCreateRequestBody) (*string, error) {
+	return c.CreateContainerWithContext(context.Background(), nodeName, d)
+}
+
+// CreateContainerWithContext is like CreateContainer but uses the specified context.
+func (c *VirtualEnvironmentClient) CreateContainerWithContext(ctx context.Context, nodeName string, d *VirtualEnvironmentContainerCreateRequestBody) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/lxc", url.PathEscape(nodeName)), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
+}
+
+// DeleteContainer deletes a container and returns the task identifier.
+func (c *VirtualEnvironmentClient) DeleteContainer(nodeName string, vmID int) (*string, error) {
+	return c.DeleteContainerWithContext(context.Background(), nodeName, vmID)
 }
 
-// DeleteContainer deletes a container.
-func (c *VirtualEnvironmentClient) DeleteContainer(nodeName string, vmID int) error {
-	return c.DoRequest(hmDELETE, fmt.Sprintf("nodes/%s/lxc/%d", url.PathEscape(nodeName), vmID), nil, nil)
+// DeleteContainerWithContext is like DeleteContainer but uses the specified context.
+func (c *VirtualEnvironmentClient) DeleteContainerWithContext(ctx context.Context, nodeName string, vmID int) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmDELETE, fmt.Sprintf("nodes/%s/lxc/%d", url.PathEscape(nodeName), vmID), nil, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
 }
 
 // GetContainer retrieves a container.
 func (c *VirtualEnvironmentClient) GetContainer(nodeName string, vmID int) (*VirtualEnvironmentContainerGetResponseData, error) {
+	return c.GetContainerWithContext(context.Background(), nodeName, vmID)
+}
+
+// GetContainerWithContext is like GetContainer but uses the specified context.
+func (c *VirtualEnvironmentClient) GetContainerWithContext(ctx context.Context, nodeName string, vmID int) (*VirtualEnvironmentContainerGetResponseData, error) {
 	resBody := &VirtualEnvironmentContainerGetResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("nodes/%s/lxc/%d/config", url.PathEscape(nodeName), vmID), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/lxc/%d/config", url.PathEscape(nodeName), vmID), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -45,8 +99,13 @@ func (c *VirtualEnvironmentClient) GetContainer(nodeName string, vmID int) (*Vir
 
 // GetContainerStatus retrieves the status for a container.
 func (c *VirtualEnvironmentClient) GetContainerStatus(nodeName string, vmID int) (*VirtualEnvironmentContainerGetStatusResponseData, error) {
+	return c.GetContainerStatusWithContext(context.Background(), nodeName, vmID)
+}
+
+// GetContainerStatusWithContext is like GetContainerStatus but uses the specified context.
+func (c *VirtualEnvironmentClient) GetContainerStatusWithContext(ctx context.Context, nodeName string, vmID int) (*VirtualEnvironmentContainerGetStatusResponseData, error) {
 	resBody := &VirtualEnvironmentContainerGetStatusResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("nodes/%s/lxc/%d/status/current", url.PathEscape(nodeName), vmID), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/lxc/%d/status/current", url.PathEscape(nodeName), vmID), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -59,33 +118,128 @@ func (c *VirtualEnvironmentClient) GetContainerStatus(nodeName string, vmID int)
 	return resBody.Data, nil
 }
 
-// RebootContainer reboots a container.
-func (c *VirtualEnvironmentClient) RebootContainer(nodeName string, vmID int, d *VirtualEnvironmentContainerRebootRequestBody) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/lxc/%d/status/reboot", url.PathEscape(nodeName), vmID), d, nil)
+// MigrateContainer migrates a container to another node and returns the task identifier.
+func (c *VirtualEnvironmentClient) MigrateContainer(nodeName string, vmID int, d *VirtualEnvironmentContainerMigrateRequestBody) (*string, error) {
+	return c.MigrateContainerWithContext(context.Background(), nodeName, vmID, d)
+}
+
+// MigrateContainerWithContext is like MigrateContainer but uses the specified context.
+func (c *VirtualEnvironmentClient) MigrateContainerWithContext(ctx context.Context, nodeName string, vmID int, d *VirtualEnvironmentContainerMigrateRequestBody) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/lxc/%d/migrate", url.PathEscape(nodeName), vmID), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
+}
+
+// RebootContainer reboots a container and returns the task identifier.
+func (c *VirtualEnvironmentClient) RebootContainer(nodeName string, vmID int, d *VirtualEnvironmentContainerRebootRequestBody) (*string, error) {
+	return c.RebootContainerWithContext(context.Background(), nodeName, vmID, d)
+}
+
+// RebootContainerWithContext is like RebootContainer but uses the specified context.
+func (c *VirtualEnvironmentClient) RebootContainerWithContext(ctx context.Context, nodeName string, vmID int, d *VirtualEnvironmentContainerRebootRequestBody) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/lxc/%d/status/reboot", url.PathEscape(nodeName), vmID), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
+}
+
+// ShutdownContainer shuts down a container and returns the task identifier.
+func (c *VirtualEnvironmentClient) ShutdownContainer(nodeName string, vmID int, d *VirtualEnvironmentContainerShutdownRequestBody) (*string, error) {
+	return c.ShutdownContainerWithContext(context.Background(), nodeName, vmID, d)
+}
+
+// ShutdownContainerWithContext is like ShutdownContainer but uses the specified context.
+func (c *VirtualEnvironmentClient) ShutdownContainerWithContext(ctx context.Context, nodeName string, vmID int, d *VirtualEnvironmentContainerShutdownRequestBody) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/lxc/%d/status/shutdown", url.PathEscape(nodeName), vmID), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
+}
+
+// StartContainer starts a container and returns the task identifier.
+func (c *VirtualEnvironmentClient) StartContainer(nodeName string, vmID int) (*string, error) {
+	return c.StartContainerWithContext(context.Background(), nodeName, vmID)
 }
 
-// ShutdownContainer shuts down a container.
-func (c *VirtualEnvironmentClient) ShutdownContainer(nodeName string, vmID int, d *VirtualEnvironmentContainerShutdownRequestBody) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/lxc/%d/status/shutdown", url.PathEscape(nodeName), vmID), d, nil)
+// StartContainerWithContext is like StartContainer but uses the specified context.
+func (c *VirtualEnvironmentClient) StartContainerWithContext(ctx context.Context, nodeName string, vmID int) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/lxc/%d/status/start", url.PathEscape(nodeName), vmID), nil, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
 }
 
-// StartContainer starts a container.
-func (c *VirtualEnvironmentClient) StartContainer(nodeName string, vmID int) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/lxc/%d/status/start", url.PathEscape(nodeName), vmID), nil, nil)
+// StopContainer stops a container immediately and returns the task identifier.
+func (c *VirtualEnvironmentClient) StopContainer(nodeName string, vmID int) (*string, error) {
+	return c.StopContainerWithContext(context.Background(), nodeName, vmID)
 }
 
-// StopContainer stops a container immediately.
-func (c *VirtualEnvironmentClient) StopContainer(nodeName string, vmID int) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/lxc/%d/status/stop", url.PathEscape(nodeName), vmID), nil, nil)
+// StopContainerWithContext is like StopContainer but uses the specified context.
+func (c *VirtualEnvironmentClient) StopContainerWithContext(ctx context.Context, nodeName string, vmID int) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/lxc/%d/status/stop", url.PathEscape(nodeName), vmID), nil, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
 }
 
 // UpdateContainer updates a container.
 func (c *VirtualEnvironmentClient) UpdateContainer(nodeName string, vmID int, d *VirtualEnvironmentContainerUpdateRequestBody) error {
-	return c.DoRequest(hmPUT, fmt.Sprintf("nodes/%s/lxc/%d/config", url.PathEscape(nodeName), vmID), d, nil)
+	return c.UpdateContainerWithContext(context.Background(), nodeName, vmID, d)
+}
+
+// UpdateContainerWithContext is like UpdateContainer but uses the specified context.
+func (c *VirtualEnvironmentClient) UpdateContainerWithContext(ctx context.Context, nodeName string, vmID int, d *VirtualEnvironmentContainerUpdateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPUT, fmt.Sprintf("nodes/%s/lxc/%d/config", url.PathEscape(nodeName), vmID), d, nil)
 }
 
 // WaitForContainerState waits for a container to reach a specific state.
 func (c *VirtualEnvironmentClient) WaitForContainerState(nodeName string, vmID int, state string, timeout int, delay int) error {
+	return c.WaitForContainerStateWithContext(context.Background(), nodeName, vmID, state, timeout, delay)
+}
+
+// WaitForContainerStateWithContext is like WaitForContainerState but uses the specified context.
+func (c *VirtualEnvironmentClient) WaitForContainerStateWithContext(ctx context.Context, nodeName string, vmID int, state string, timeout int, delay int) error {
 	state = strings.ToLower(state)
 
 	timeDelay := int64(delay)
@@ -95,7 +249,7 @@ func (c *VirtualEnvironmentClient) WaitForContainerState(nodeName string, vmID i
 
 	for timeElapsed.Seconds() < timeMax {
 		if int64(timeElapsed.Seconds())%timeDelay == 0 {
-			data, err := c.GetContainerStatus(nodeName, vmID)
+			data, err := c.GetContainerStatusWithContext(ctx, nodeName, vmID)
 
 			if err != nil {
 				return err
@@ -105,10 +259,18 @@ func (c *VirtualEnvironmentClient) WaitForContainerState(nodeName string, vmID i
 				return nil
 			}
 
-			time.Sleep(1 * time.Second)
+			err = sleepWithContext(ctx, 1*time.Second)
+
+			if err != nil {
+				return err
+			}
 		}
 
-		time.Sleep(200 * time.Millisecond)
+		err := sleepWithContext(ctx, 200*time.Millisecond)
+
+		if err != nil {
+			return err
+		}
 
 		timeElapsed = time.Now().Sub(timeStart)
 	}
@@ -118,6 +280,11 @@ func (c *VirtualEnvironmentClient) WaitForContainerState(nodeName string, vmID i
 
 // WaitForContainerLock waits for a container lock to be released.
 func (c *VirtualEnvironmentClient) WaitForContainerLock(nodeName string, vmID int, timeout int, delay int, ignoreErrorResponse bool) error {
+	return c.WaitForContainerLockWithContext(context.Background(), nodeName, vmID, timeout, delay, ignoreErrorResponse)
+}
+
+// WaitForContainerLockWithContext is like WaitForContainerLock but uses the specified context.
+func (c *VirtualEnvironmentClient) WaitForContainerLockWithContext(ctx context.Context, nodeName string, vmID int, timeout int, delay int, ignoreErrorResponse bool) error {
 	timeDelay := int64(delay)
 	timeMax := float64(timeout)
 	timeStart := time.Now()
@@ -125,7 +292,7 @@ func (c *VirtualEnvironmentClient) WaitForContainerLock(nodeName string, vmID in
 
 	for timeElapsed.Seconds() < timeMax {
 		if int64(timeElapsed.Seconds())%timeDelay == 0 {
-			data, err := c.GetContainerStatus(nodeName, vmID)
+			data, err := c.GetContainerStatusWithContext(ctx, nodeName, vmID)
 
 			if err != nil {
 				if !ignoreErrorResponse {
@@ -135,10 +302,18 @@ func (c *VirtualEnvironmentClient) WaitForContainerLock(nodeName string, vmID in
 				return nil
 			}
 
-			time.Sleep(1 * time.Second)
+			err = sleepWithContext(ctx, 1*time.Second)
+
+			if err != nil {
+				return err
+			}
 		}
 
-		time.Sleep(200 * time.Millisecond)
+		err := sleepWithContext(ctx, 200*time.Millisecond)
+
+		if err != nil {
+			return err
+		}
 
 		timeElapsed = time.Now().Sub(timeStart)
 	}
@@ -203,6 +203,13 @@ type VirtualEnvironmentContainerGetStatusResponseData struct {
 	VMID             string       `json:"vmid,omitempty"`
 }
 
+// VirtualEnvironmentContainerMigrateRequestBody contains the body for a container migration request.
+type VirtualEnvironmentContainerMigrateRequestBody struct {
+	Restart    *CustomBool `json:"restart,omitempty,int" url:"restart,omitempty,int"`
+	TargetNode string      `json:"target" url:"target"`
+	Timeout    *int        `json:"timeout,omitempty" url:"timeout,omitempty"`
+}
+
 // VirtualEnvironmentContainerRebootRequestBody contains the body for a container reboot request.
 type VirtualEnvironmentContainerRebootRequestBody struct {
 	Timeout *int `json:"timeout,omitempty" url:"timeout,omitempty"`
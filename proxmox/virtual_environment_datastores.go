@@ -5,22 +5,35 @@
 package proxmox
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"mime/multipart"
 	"net/url"
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/sftp"
 )
 
+const (
+	datastoreUploadProgressInterval = 10 * time.Second
+)
+
 // DeleteDatastoreFile deletes a file in a datastore.
 func (c *VirtualEnvironmentClient) DeleteDatastoreFile(nodeName, datastoreID, volumeID string) error {
-	err := c.DoRequest(hmDELETE, fmt.Sprintf("nodes/%s/storage/%s/content/%s", url.PathEscape(nodeName), url.PathEscape(datastoreID), url.PathEscape(volumeID)), nil, nil)
+	return c.DeleteDatastoreFileWithContext(context.Background(), nodeName, datastoreID, volumeID)
+}
+
+// DeleteDatastoreFileWithContext is like DeleteDatastoreFile but uses the specified context.
+func (c *VirtualEnvironmentClient) DeleteDatastoreFileWithContext(ctx context.Context, nodeName, datastoreID, volumeID string) error {
+	err := c.DoRequestWithContext(ctx, hmDELETE, fmt.Sprintf("nodes/%s/storage/%s/content/%s", url.PathEscape(nodeName), url.PathEscape(datastoreID), url.PathEscape(volumeID)), nil, nil)
 
 	if err != nil {
 		return err
@@ -29,10 +42,79 @@ func (c *VirtualEnvironmentClient) DeleteDatastoreFile(nodeName, datastoreID, vo
 	return nil
 }
 
+// DownloadFileToDatastore instructs a node to download a file from a URL to a datastore and returns the task identifier.
+func (c *VirtualEnvironmentClient) DownloadFileToDatastore(nodeName, datastoreID string, d *VirtualEnvironmentDatastoreDownloadURLRequestBody) (*string, error) {
+	return c.DownloadFileToDatastoreWithContext(context.Background(), nodeName, datastoreID, d)
+}
+
+// DownloadFileToDatastoreWithContext is like DownloadFileToDatastore but uses the specified context.
+func (c *VirtualEnvironmentClient) DownloadFileToDatastoreWithContext(ctx context.Context, nodeName, datastoreID string, d *VirtualEnvironmentDatastoreDownloadURLRequestBody) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/storage/%s/download-url", url.PathEscape(nodeName), url.PathEscape(datastoreID)), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
+}
+
+// GetDatastore retrieves the configuration of a datastore.
+func (c *VirtualEnvironmentClient) GetDatastore(datastoreID string) (*VirtualEnvironmentDatastoreGetResponseData, error) {
+	return c.GetDatastoreWithContext(context.Background(), datastoreID)
+}
+
+// GetDatastoreWithContext is like GetDatastore but uses the specified context.
+func (c *VirtualEnvironmentClient) GetDatastoreWithContext(ctx context.Context, datastoreID string) (*VirtualEnvironmentDatastoreGetResponseData, error) {
+	resBody := &VirtualEnvironmentDatastoreGetResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("storage/%s", url.PathEscape(datastoreID)), nil, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a data object in the response")
+	}
+
+	return resBody.Data, nil
+}
+
+// GetDatastorePath retrieves the path of a datastore, which must be backed by a directory.
+func (c *VirtualEnvironmentClient) GetDatastorePath(datastoreID string) (*string, error) {
+	return c.GetDatastorePathWithContext(context.Background(), datastoreID)
+}
+
+// GetDatastorePathWithContext is like GetDatastorePath but uses the specified context.
+func (c *VirtualEnvironmentClient) GetDatastorePathWithContext(ctx context.Context, datastoreID string) (*string, error) {
+	datastore, err := c.GetDatastoreWithContext(ctx, datastoreID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if datastore.Path == nil || *datastore.Path == "" {
+		return nil, fmt.Errorf("Datastore \"%s\" is not backed by a directory (type: %s)", datastoreID, datastore.Type)
+	}
+
+	datastorePath := strings.TrimRight(*datastore.Path, "/")
+
+	return &datastorePath, nil
+}
+
 // ListDatastoreFiles retrieves a list of the files in a datastore.
 func (c *VirtualEnvironmentClient) ListDatastoreFiles(nodeName, datastoreID string) ([]*VirtualEnvironmentDatastoreFileListResponseData, error) {
+	return c.ListDatastoreFilesWithContext(context.Background(), nodeName, datastoreID)
+}
+
+// ListDatastoreFilesWithContext is like ListDatastoreFiles but uses the specified context.
+func (c *VirtualEnvironmentClient) ListDatastoreFilesWithContext(ctx context.Context, nodeName, datastoreID string) ([]*VirtualEnvironmentDatastoreFileListResponseData, error) {
 	resBody := &VirtualEnvironmentDatastoreFileListResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("nodes/%s/storage/%s/content", url.PathEscape(nodeName), url.PathEscape(datastoreID)), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/storage/%s/content", url.PathEscape(nodeName), url.PathEscape(datastoreID)), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -51,8 +133,13 @@ func (c *VirtualEnvironmentClient) ListDatastoreFiles(nodeName, datastoreID stri
 
 // ListDatastores retrieves a list of nodes.
 func (c *VirtualEnvironmentClient) ListDatastores(nodeName string, d *VirtualEnvironmentDatastoreListRequestBody) ([]*VirtualEnvironmentDatastoreListResponseData, error) {
+	return c.ListDatastoresWithContext(context.Background(), nodeName, d)
+}
+
+// ListDatastoresWithContext is like ListDatastores but uses the specified context.
+func (c *VirtualEnvironmentClient) ListDatastoresWithContext(ctx context.Context, nodeName string, d *VirtualEnvironmentDatastoreListRequestBody) ([]*VirtualEnvironmentDatastoreListResponseData, error) {
 	resBody := &VirtualEnvironmentDatastoreListResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("nodes/%s/storage", url.PathEscape(nodeName)), d, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/storage", url.PathEscape(nodeName)), d, resBody)
 
 	if err != nil {
 		return nil, err
@@ -71,80 +158,81 @@ func (c *VirtualEnvironmentClient) ListDatastores(nodeName string, d *VirtualEnv
 
 // UploadFileToDatastore uploads a file to a datastore.
 func (c *VirtualEnvironmentClient) UploadFileToDatastore(d *VirtualEnvironmentDatastoreUploadRequestBody) (*VirtualEnvironmentDatastoreUploadResponseBody, error) {
-	switch d.ContentType {
-	case "iso", "vztmpl":
-		r, w := io.Pipe()
-
-		defer r.Close()
+	return c.UploadFileToDatastoreWithContext(context.Background(), d)
+}
 
-		m := multipart.NewWriter(w)
+// UploadFileToDatastoreWithContext is like UploadFileToDatastore but uses the specified context.
+func (c *VirtualEnvironmentClient) UploadFileToDatastoreWithContext(ctx context.Context, d *VirtualEnvironmentDatastoreUploadRequestBody) (*VirtualEnvironmentDatastoreUploadResponseBody, error) {
+	datastore, err := c.GetDatastoreWithContext(ctx, d.DatastoreID)
 
-		go func() {
-			defer w.Close()
-			defer m.Close()
+	if err != nil {
+		return nil, err
+	}
 
-			m.WriteField("content", d.ContentType)
+	if datastore.Path == nil || *datastore.Path == "" {
+		return nil, fmt.Errorf("Unable to upload file \"%s\" because datastore \"%s\" is not backed by a directory (type: %s)", d.FileName, d.DatastoreID, datastore.Type)
+	}
 
-			part, err := m.CreateFormFile("filename", d.FileName)
+	if !datastore.hasContentType(d.ContentType) {
+		return nil, fmt.Errorf("Unable to upload file \"%s\" because datastore \"%s\" does not support content type \"%s\"", d.FileName, d.DatastoreID, d.ContentType)
+	}
 
-			if err != nil {
-				return
-			}
+	progressReader := &datastoreUploadProgressReader{
+		fileName:   d.FileName,
+		fileSize:   d.FileSize,
+		lastReport: time.Now(),
+		reader:     d.FileReader,
+	}
 
-			_, err = io.Copy(part, d.FileReader)
+	upload := *d
+	upload.FileReader = progressReader
 
-			if err != nil {
-				return
-			}
-		}()
+	datastorePath := strings.TrimRight(*datastore.Path, "/")
+	remoteFilePath := fmt.Sprintf("%s/%s", getDatastoreContentDirectory(datastorePath, d.ContentType), d.FileName)
 
-		// We need to store the multipart content in a temporary file to avoid using high amounts of memory.
-		// This is necessary due to Proxmox VE not supporting chunked transfers in v6.1 and earlier versions.
-		tempMultipartFile, err := ioutil.TempFile("", "multipart")
+	switch d.ContentType {
+	case "iso", "vztmpl":
+		reqBody, closeBody, err := c.newDatastoreUploadMultiPartData(ctx, &upload)
 
 		if err != nil {
 			return nil, err
 		}
 
-		tempMultipartFileName := tempMultipartFile.Name()
-
-		io.Copy(tempMultipartFile, r)
+		defer closeBody()
 
-		err = tempMultipartFile.Close()
+		resBody := &VirtualEnvironmentDatastoreUploadResponseBody{}
+		err = c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/storage/%s/upload", url.PathEscape(d.NodeName), url.PathEscape(d.DatastoreID)), reqBody, resBody)
 
 		if err != nil {
 			return nil, err
 		}
 
-		defer os.Remove(tempMultipartFileName)
-
-		// Now that the multipart data is stored in a file, we can go ahead and do a HTTP POST request.
-		fileReader, err := os.Open(tempMultipartFileName)
+		// Newer versions of Proxmox VE move the uploaded file to the datastore in a separate task.
+		if resBody.UploadID != nil && strings.HasPrefix(*resBody.UploadID, "UPID:") {
+			err = c.WaitForTaskWithContext(ctx, d.NodeName, *resBody.UploadID, 600, 1)
 
-		if err != nil {
-			return nil, err
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		defer fileReader.Close()
-
-		fileInfo, err := fileReader.Stat()
+		remoteFileSize, err := c.getDatastoreFileSize(ctx, d)
 
 		if err != nil {
 			return nil, err
 		}
 
-		fileSize := fileInfo.Size()
+		// The checksum is not verified for API uploads, as this would require shell access to the node.
+		err = verifyDatastoreUploadSize(d, progressReader.bytesRead, *remoteFileSize)
 
-		reqBody := &VirtualEnvironmentMultiPartData{
-			Boundary: m.Boundary(),
-			Reader:   fileReader,
-			Size:     &fileSize,
-		}
+		if err != nil {
+			volumeID := fmt.Sprintf("%s:%s/%s", d.DatastoreID, d.ContentType, d.FileName)
+			deleteErr := c.DeleteDatastoreFileWithContext(ctx, d.NodeName, d.DatastoreID, volumeID)
 
-		resBody := &VirtualEnvironmentDatastoreUploadResponseBody{}
-		err = c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/storage/%s/upload", url.PathEscape(d.NodeName), url.PathEscape(d.DatastoreID)), reqBody, resBody)
+			if deleteErr != nil {
+				log.Printf("[DEBUG] WARNING: Failed to delete the uploaded file \"%s\" after its verification failed: %s", d.FileName, deleteErr.Error())
+			}
 
-		if err != nil {
 			return nil, err
 		}
 
@@ -152,7 +240,7 @@ func (c *VirtualEnvironmentClient) UploadFileToDatastore(d *VirtualEnvironmentDa
 	default:
 		// We need to upload all other files using SFTP due to API limitations.
 		// Hopefully, this will not be required in future releases of Proxmox VE.
-		sshClient, err := c.OpenNodeShell(d.NodeName)
+		sshClient, err := c.OpenNodeShellWithContext(ctx, d.NodeName)
 
 		if err != nil {
 			return nil, err
@@ -160,66 +248,278 @@ func (c *VirtualEnvironmentClient) UploadFileToDatastore(d *VirtualEnvironmentDa
 
 		defer sshClient.Close()
 
-		sshSession, err := sshClient.NewSession()
+		stopWatching := closeOnDone(ctx, sshClient)
+		defer stopWatching()
+
+		remoteFileDir := getDatastoreContentDirectory(datastorePath, d.ContentType)
+		sftpClient, err := sftp.NewClient(sshClient)
 
 		if err != nil {
 			return nil, err
 		}
 
-		buf, err := sshSession.CombinedOutput(
-			fmt.Sprintf(`grep -Pzo ': %s\s+path\s+[^\s]+' /etc/pve/storage.cfg | grep -Pzo '/[^\s]*' | tr -d '\000'`, d.DatastoreID),
-		)
+		defer sftpClient.Close()
 
-		if err != nil {
-			sshSession.Close()
+		err = sftpClient.MkdirAll(remoteFileDir)
 
+		if err != nil {
 			return nil, err
 		}
 
-		sshSession.Close()
-
-		datastorePath := strings.Trim(string(buf), "\000")
+		remoteFile, err := sftpClient.Create(remoteFilePath)
 
-		if datastorePath == "" {
-			return nil, errors.New("Failed to determine the datastore path")
+		if err != nil {
+			return nil, err
 		}
 
-		remoteFileDir := datastorePath
-
-		switch d.ContentType {
-		default:
-			remoteFileDir += fmt.Sprintf("/%s", d.ContentType)
-		}
+		defer remoteFile.Close()
 
-		remoteFilePath := fmt.Sprintf("%s/%s", remoteFileDir, d.FileName)
-		sftpClient, err := sftp.NewClient(sshClient)
+		_, err = remoteFile.ReadFrom(progressReader)
 
 		if err != nil {
 			return nil, err
 		}
 
-		defer sftpClient.Close()
-
-		err = sftpClient.MkdirAll(remoteFileDir)
+		err = remoteFile.Close()
 
 		if err != nil {
 			return nil, err
 		}
 
-		remoteFile, err := sftpClient.Create(remoteFilePath)
+		remoteFileInfo, err := sftpClient.Stat(remoteFilePath)
 
 		if err != nil {
 			return nil, err
 		}
 
-		defer remoteFile.Close()
+		err = verifyDatastoreUploadSize(d, progressReader.bytesRead, remoteFileInfo.Size())
 
-		_, err = remoteFile.ReadFrom(d.FileReader)
+		if err == nil {
+			err = c.verifyDatastoreUploadChecksum(ctx, d, remoteFilePath)
+		}
 
 		if err != nil {
+			removeErr := sftpClient.Remove(remoteFilePath)
+
+			if removeErr != nil {
+				log.Printf("[DEBUG] WARNING: Failed to delete the uploaded file \"%s\" after its verification failed: %s", d.FileName, removeErr.Error())
+			}
+
 			return nil, err
 		}
 
 		return &VirtualEnvironmentDatastoreUploadResponseBody{}, nil
 	}
 }
+
+// newDatastoreUploadMultiPartData creates the multipart body for an upload request, which streams the file directly.
+// The body must be buffered in a temporary file, if neither the file size is known nor the server supports chunked transfers.
+// The returned function must be called to remove the temporary file.
+func (c *VirtualEnvironmentClient) newDatastoreUploadMultiPartData(ctx context.Context, d *VirtualEnvironmentDatastoreUploadRequestBody) (*VirtualEnvironmentMultiPartData, func(), error) {
+	buf := &bytes.Buffer{}
+	m := multipart.NewWriter(buf)
+
+	err := m.WriteField("content", d.ContentType)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = m.CreateFormFile("filename", d.FileName)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := make([]byte, buf.Len())
+	copy(header, buf.Bytes())
+
+	buf.Reset()
+
+	err = m.Close()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trailer := buf.Bytes()
+	reader := io.MultiReader(bytes.NewReader(header), d.FileReader, bytes.NewReader(trailer))
+
+	if d.FileSize != nil {
+		size := int64(len(header)) + *d.FileSize + int64(len(trailer))
+
+		return &VirtualEnvironmentMultiPartData{
+			Boundary: m.Boundary(),
+			Reader:   reader,
+			Size:     &size,
+		}, func() {}, nil
+	}
+
+	version, err := c.VersionWithContext(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if version.supportsChunkedTransfers() {
+		return &VirtualEnvironmentMultiPartData{
+			Boundary: m.Boundary(),
+			Reader:   reader,
+		}, func() {}, nil
+	}
+
+	// We need to store the multipart content in a temporary file to avoid using high amounts of memory.
+	// This is necessary due to Proxmox VE not supporting chunked transfers in v6.1 and earlier versions.
+	log.Printf("[DEBUG] Buffering multipart upload of file \"%s\" because Proxmox VE %s does not support chunked transfers", d.FileName, version.Version)
+
+	tempMultipartFile, err := ioutil.TempFile("", "multipart")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tempMultipartFileName := tempMultipartFile.Name()
+	removeTempMultipartFile := func() {
+		tempMultipartFile.Close()
+		os.Remove(tempMultipartFileName)
+	}
+
+	size, err := io.Copy(tempMultipartFile, reader)
+
+	if err != nil {
+		removeTempMultipartFile()
+
+		return nil, nil, err
+	}
+
+	_, err = tempMultipartFile.Seek(0, io.SeekStart)
+
+	if err != nil {
+		removeTempMultipartFile()
+
+		return nil, nil, err
+	}
+
+	return &VirtualEnvironmentMultiPartData{
+		Boundary: m.Boundary(),
+		Reader:   tempMultipartFile,
+		Size:     &size,
+	}, removeTempMultipartFile, nil
+}
+
+// getDatastoreFileSize retrieves the size of an uploaded file from the content list of a datastore.
+func (c *VirtualEnvironmentClient) getDatastoreFileSize(ctx context.Context, d *VirtualEnvironmentDatastoreUploadRequestBody) (*int64, error) {
+	files, err := c.ListDatastoreFilesWithContext(ctx, d.NodeName, d.DatastoreID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	volumeID := fmt.Sprintf("%s:%s/%s", d.DatastoreID, d.ContentType, d.FileName)
+
+	for _, file := range files {
+		if file.VolumeID == volumeID {
+			fileSize := int64(file.FileSize)
+
+			return &fileSize, nil
+		}
+	}
+
+	return nil, fmt.Errorf("The uploaded file \"%s\" was not found in datastore \"%s\"", d.FileName, d.DatastoreID)
+}
+
+// verifyDatastoreUploadChecksum compares the checksum of a file, which has been uploaded using SFTP, with the specified checksum.
+func (c *VirtualEnvironmentClient) verifyDatastoreUploadChecksum(ctx context.Context, d *VirtualEnvironmentDatastoreUploadRequestBody, remoteFilePath string) error {
+	if d.Checksum == "" {
+		return nil
+	}
+
+	checksumAlgorithm := strings.ToLower(d.ChecksumAlgorithm)
+
+	if checksumAlgorithm == "" {
+		checksumAlgorithm = "sha256"
+	}
+
+	switch checksumAlgorithm {
+	case "md5", "sha1", "sha224", "sha256", "sha384", "sha512":
+	default:
+		return fmt.Errorf("Unsupported checksum algorithm \"%s\"", d.ChecksumAlgorithm)
+	}
+
+	results, err := c.RunNodeCommandsWithContext(ctx, d.NodeName, &VirtualEnvironmentNodeCommandPlan{
+		Steps: []*VirtualEnvironmentNodeCommand{
+			{
+				Command: fmt.Sprintf("%ssum %s", checksumAlgorithm, quoteNodeCommandArgument(remoteFilePath)),
+				Name:    fmt.Sprintf("Calculate the %s checksum of file \"%s\"", strings.ToUpper(checksumAlgorithm), d.FileName),
+			},
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	remoteChecksum := strings.Fields(results[0].Stdout)
+
+	if len(remoteChecksum) == 0 || !strings.EqualFold(remoteChecksum[0], d.Checksum) {
+		return fmt.Errorf("The %s checksum of the uploaded file \"%s\" does not match checksum \"%s\"", strings.ToUpper(checksumAlgorithm), d.FileName, d.Checksum)
+	}
+
+	log.Printf("[DEBUG] Verified the %s checksum of the uploaded file \"%s\"", strings.ToUpper(checksumAlgorithm), d.FileName)
+
+	return nil
+}
+
+// verifyDatastoreUploadSize compares the size of an uploaded file with the size of the source.
+func verifyDatastoreUploadSize(d *VirtualEnvironmentDatastoreUploadRequestBody, localFileSize int64, remoteFileSize int64) error {
+	if remoteFileSize != localFileSize {
+		return fmt.Errorf("The size of the uploaded file \"%s\" (%d bytes) does not match the size of the source (%d bytes)", d.FileName, remoteFileSize, localFileSize)
+	}
+
+	return nil
+}
+
+// getDatastoreContentDirectory returns the directory, which contains the files of a content type in a datastore.
+func getDatastoreContentDirectory(datastorePath, contentType string) string {
+	switch contentType {
+	case "iso":
+		return fmt.Sprintf("%s/template/iso", datastorePath)
+	case "vztmpl":
+		return fmt.Sprintf("%s/template/cache", datastorePath)
+	default:
+		return fmt.Sprintf("%s/%s", datastorePath, contentType)
+	}
+}
+
+// hasContentType determines whether a datastore is configured to store a content type.
+func (d *VirtualEnvironmentDatastoreGetResponseData) hasContentType(contentType string) bool {
+	if d.ContentTypes == nil {
+		return false
+	}
+
+	for _, v := range *d.ContentTypes {
+		if strings.TrimSpace(v) == contentType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Read reads from the underlying reader and periodically logs the progress.
+func (r *datastoreUploadProgressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.bytesRead += int64(n)
+
+	if err == io.EOF || time.Since(r.lastReport) >= datastoreUploadProgressInterval {
+		r.lastReport = time.Now()
+
+		if r.fileSize != nil && *r.fileSize > 0 {
+			log.Printf("[DEBUG] Uploaded %d of %d bytes (%.1f%%) of file \"%s\"", r.bytesRead, *r.fileSize, float64(r.bytesRead)*100/float64(*r.fileSize), r.fileName)
+		} else {
+			log.Printf("[DEBUG] Uploaded %d bytes of file \"%s\"", r.bytesRead, r.fileName)
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,284 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testNewDatastoreServer creates a test server, which returns the configuration of the datastores "local" and "local-lvm".
+func testNewDatastoreServer() *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api2/json/storage/local":
+			w.Write([]byte(`{"data":{"content":"iso,vztmpl,backup","digest":"abc","path":"/var/lib/vz/","storage":"local","type":"dir"}}`))
+		case "/api2/json/storage/local-lvm":
+			w.Write([]byte(`{"data":{"content":"rootdir,images","digest":"abc","storage":"local-lvm","thinpool":"data","type":"lvmthin","vgname":"pve"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// TestVirtualEnvironmentClientGetDatastorePath tests whether the path of a datastore is retrieved from the storage API.
+func TestVirtualEnvironmentClientGetDatastorePath(t *testing.T) {
+	server := testNewDatastoreServer()
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+
+	datastorePath, err := c.GetDatastorePath("local")
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve the datastore path: %s", err.Error())
+	}
+
+	if *datastorePath != "/var/lib/vz" {
+		t.Fatalf("Expected datastore path \"/var/lib/vz\" - got: \"%s\"", *datastorePath)
+	}
+
+	_, err = c.GetDatastorePath("local-lvm")
+
+	if err == nil || !strings.Contains(err.Error(), "not backed by a directory") {
+		t.Fatalf("Expected an error for a datastore which is not backed by a directory - got: %v", err)
+	}
+}
+
+// TestVirtualEnvironmentClientUploadFileToDatastoreRejectsUnsupportedDatastores tests whether uploads are rejected before any data is transferred.
+func TestVirtualEnvironmentClientUploadFileToDatastoreRejectsUnsupportedDatastores(t *testing.T) {
+	server := testNewDatastoreServer()
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+
+	tests := []struct {
+		contentType string
+		datastoreID string
+		expected    string
+	}{
+		{"iso", "local-lvm", "is not backed by a directory"},
+		{"snippets", "local", "does not support content type \"snippets\""},
+	}
+
+	for _, test := range tests {
+		_, err := c.UploadFileToDatastore(&VirtualEnvironmentDatastoreUploadRequestBody{
+			ContentType: test.contentType,
+			DatastoreID: test.datastoreID,
+			FileName:    "test.img",
+			FileReader:  strings.NewReader("test"),
+			NodeName:    "pve",
+		})
+
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Fatalf("Expected an error containing \"%s\" for datastore \"%s\" - got: %v", test.expected, test.datastoreID, err)
+		}
+	}
+}
+
+// TestVirtualEnvironmentClientUploadFileToDatastoreStreams tests whether uploads are streamed and only buffered for old versions of Proxmox VE.
+func TestVirtualEnvironmentClientUploadFileToDatastoreStreams(t *testing.T) {
+	fileData := strings.Repeat("0123456789", 1000)
+	fileSize := int64(len(fileData))
+
+	tests := []struct {
+		fileSize *int64
+		version  string
+		chunked  bool
+	}{
+		{&fileSize, "6.1-3", false},
+		{nil, "6.1-3", false},
+		{nil, "6.2-4", true},
+	}
+
+	for i, test := range tests {
+		var received string
+		var receivedChunked bool
+
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api2/json/storage/local":
+				w.Write([]byte(`{"data":{"content":"iso","path":"/var/lib/vz","storage":"local","type":"dir"}}`))
+			case "/api2/json/version":
+				w.Write([]byte(`{"data":{"release":"6","repoid":"abc","version":"` + test.version + `"}}`))
+			case "/api2/json/nodes/pve/storage/local/upload":
+				receivedChunked = r.ContentLength < 0
+
+				file, _, err := r.FormFile("filename")
+
+				if err == nil {
+					data, _ := ioutil.ReadAll(file)
+					received = string(data)
+				}
+
+				w.Write([]byte(`{"data":"UPID:pve:00001234:00005678:5E000000:imgcopy::root@pam:"}`))
+			case "/api2/json/nodes/pve/storage/local/content":
+				w.Write([]byte(`{"data":[{"content":"iso","format":"iso","size":` + strconv.Itoa(len(received)) + `,"volid":"local:iso/test.iso"}]}`))
+			default:
+				if strings.HasPrefix(r.URL.Path, "/api2/json/nodes/pve/tasks/") {
+					w.Write([]byte(`{"data":{"exitstatus":"OK","node":"pve","status":"stopped","type":"imgcopy","upid":"UPID:pve:00001234:00005678:5E000000:imgcopy::root@pam:","user":"root@pam"}}`))
+					return
+				}
+
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		c := testNewVirtualEnvironmentClient(t, server)
+
+		_, err := c.UploadFileToDatastore(&VirtualEnvironmentDatastoreUploadRequestBody{
+			ContentType: "iso",
+			DatastoreID: "local",
+			FileName:    "test.iso",
+			FileReader:  io.LimitReader(strings.NewReader(fileData), fileSize),
+			FileSize:    test.fileSize,
+			NodeName:    "pve",
+		})
+
+		server.Close()
+
+		if err != nil {
+			t.Fatalf("Failed to upload the file for test %d: %s", i, err.Error())
+		}
+
+		if received != fileData {
+			t.Fatalf("Expected the server to receive %d bytes for test %d - got: %d", len(fileData), i, len(received))
+		}
+
+		if receivedChunked != test.chunked {
+			t.Fatalf("Expected chunked transfer encoding to be %t for test %d - got: %t", test.chunked, i, receivedChunked)
+		}
+	}
+}
+
+// TestVirtualEnvironmentClientUploadFileToDatastoreVerifiesSize tests whether an upload fails and the uploaded file is deleted,
+// if the size of the uploaded file does not match, and whether API uploads succeed without shell access to verify the checksum.
+func TestVirtualEnvironmentClientUploadFileToDatastoreVerifiesSize(t *testing.T) {
+	deleted := false
+	remoteFileSize := 2
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api2/json/storage/local":
+			w.Write([]byte(`{"data":{"content":"iso","path":"/var/lib/vz","storage":"local","type":"dir"}}`))
+		case "/api2/json/nodes/pve/storage/local/upload":
+			ioutil.ReadAll(r.Body)
+			w.Write([]byte(`{"data":null}`))
+		case "/api2/json/nodes/pve/storage/local/content":
+			w.Write([]byte(`{"data":[{"content":"iso","format":"iso","size":` + strconv.Itoa(remoteFileSize) + `,"volid":"local:iso/test.iso"}]}`))
+		case "/api2/json/nodes/pve/storage/local/content/local:iso/test.iso":
+			deleted = r.Method == http.MethodDelete
+			w.Write([]byte(`{"data":null}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	fileSize := int64(4)
+
+	_, err := c.UploadFileToDatastore(&VirtualEnvironmentDatastoreUploadRequestBody{
+		ContentType: "iso",
+		DatastoreID: "local",
+		FileName:    "test.iso",
+		FileReader:  strings.NewReader("test"),
+		FileSize:    &fileSize,
+		NodeName:    "pve",
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "(2 bytes) does not match the size of the source (4 bytes)") {
+		t.Fatalf("Expected a size mismatch error - got: %v", err)
+	}
+
+	if !deleted {
+		t.Fatalf("Expected the uploaded file to be deleted after the verification failed")
+	}
+
+	remoteFileSize = 4
+
+	_, err = c.UploadFileToDatastore(&VirtualEnvironmentDatastoreUploadRequestBody{
+		Checksum:    "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		ContentType: "iso",
+		DatastoreID: "local",
+		FileName:    "test.iso",
+		FileReader:  strings.NewReader("test"),
+		FileSize:    &fileSize,
+		NodeName:    "pve",
+	})
+
+	if err != nil {
+		t.Fatalf("Expected an API upload with a checksum to succeed without shell access - got: %s", err.Error())
+	}
+}
+
+// TestVirtualEnvironmentClientDownloadFileToDatastore tests whether a node is instructed to download a file.
+func TestVirtualEnvironmentClientDownloadFileToDatastore(t *testing.T) {
+	var received map[string]string
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api2/json/nodes/pve/storage/local/download-url" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		r.ParseForm()
+
+		received = map[string]string{}
+
+		for k := range r.PostForm {
+			received[k] = r.PostForm.Get(k)
+		}
+
+		w.Write([]byte(`{"data":"UPID:pve:00001234:00005678:5E000000:download:test.iso:root@pam:"}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+
+	checksum := "abc"
+	checksumAlgorithm := "sha512"
+	decompressionAlgorithm := "zst"
+	verifyCertificates := CustomBool(false)
+
+	taskID, err := c.DownloadFileToDatastore("pve", "local", &VirtualEnvironmentDatastoreDownloadURLRequestBody{
+		Checksum:               &checksum,
+		ChecksumAlgorithm:      &checksumAlgorithm,
+		ContentType:            "iso",
+		DecompressionAlgorithm: &decompressionAlgorithm,
+		FileName:               "test.iso",
+		URL:                    "https://example.com/test.iso.zst",
+		VerifyCertificates:     &verifyCertificates,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to download the file: %s", err.Error())
+	}
+
+	if !strings.HasPrefix(*taskID, "UPID:pve:") {
+		t.Fatalf("Expected a task identifier - got: \"%s\"", *taskID)
+	}
+
+	expected := map[string]string{
+		"checksum":            "abc",
+		"checksum-algorithm":  "sha512",
+		"compression":         "zst",
+		"content":             "iso",
+		"filename":            "test.iso",
+		"url":                 "https://example.com/test.iso.zst",
+		"verify-certificates": "0",
+	}
+
+	for k, v := range expected {
+		if received[k] != v {
+			t.Fatalf("Expected parameter \"%s\" to be \"%s\" - got: \"%s\"", k, v, received[k])
+		}
+	}
+}
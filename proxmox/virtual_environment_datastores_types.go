@@ -6,8 +6,20 @@ package proxmox
 
 import (
 	"io"
+	"time"
 )
 
+// VirtualEnvironmentDatastoreDownloadURLRequestBody contains the body for a datastore download URL request.
+type VirtualEnvironmentDatastoreDownloadURLRequestBody struct {
+	Checksum               *string     `json:"checksum,omitempty" url:"checksum,omitempty"`
+	ChecksumAlgorithm      *string     `json:"checksum-algorithm,omitempty" url:"checksum-algorithm,omitempty"`
+	ContentType            string      `json:"content" url:"content"`
+	DecompressionAlgorithm *string     `json:"compression,omitempty" url:"compression,omitempty"`
+	FileName               string      `json:"filename" url:"filename"`
+	URL                    string      `json:"url" url:"url"`
+	VerifyCertificates     *CustomBool `json:"verify-certificates,omitempty" url:"verify-certificates,omitempty,int"`
+}
+
 // VirtualEnvironmentDatastoreFileListResponseBody contains the body from a datastore content list response.
 type VirtualEnvironmentDatastoreFileListResponseBody struct {
 	Data []*VirtualEnvironmentDatastoreFileListResponseData `json:"data,omitempty"`
@@ -24,6 +36,23 @@ type VirtualEnvironmentDatastoreFileListResponseData struct {
 	VolumeID       string  `json:"volid"`
 }
 
+// VirtualEnvironmentDatastoreGetResponseBody contains the body from a datastore get response.
+type VirtualEnvironmentDatastoreGetResponseBody struct {
+	Data *VirtualEnvironmentDatastoreGetResponseData `json:"data,omitempty"`
+}
+
+// VirtualEnvironmentDatastoreGetResponseData contains the data from a datastore get response.
+type VirtualEnvironmentDatastoreGetResponseData struct {
+	ContentTypes *CustomCommaSeparatedList `json:"content,omitempty"`
+	Digest       *string                   `json:"digest,omitempty"`
+	Disabled     *CustomBool               `json:"disable,omitempty"`
+	ID           string                    `json:"storage"`
+	Nodes        *CustomCommaSeparatedList `json:"nodes,omitempty"`
+	Path         *string                   `json:"path,omitempty"`
+	Shared       *CustomBool               `json:"shared,omitempty"`
+	Type         string                    `json:"type"`
+}
+
 // VirtualEnvironmentDatastoreListRequestBody contains the body for a datastore list request.
 type VirtualEnvironmentDatastoreListRequestBody struct {
 	ContentTypes CustomCommaSeparatedList `json:"content,omitempty" url:"content,omitempty,comma"`
@@ -53,15 +82,28 @@ type VirtualEnvironmentDatastoreListResponseData struct {
 }
 
 // VirtualEnvironmentDatastoreUploadRequestBody contains the body for a datastore upload request.
+// Files uploaded using SFTP are verified against the checksum, if one is specified. The checksum algorithm defaults to SHA256.
 type VirtualEnvironmentDatastoreUploadRequestBody struct {
-	ContentType string    `json:"content,omitempty"`
-	DatastoreID string    `json:"storage,omitempty"`
-	FileName    string    `json:"filename,omitempty"`
-	FileReader  io.Reader `json:"-"`
-	NodeName    string    `json:"node,omitempty"`
+	Checksum          string    `json:"-"`
+	ChecksumAlgorithm string    `json:"-"`
+	ContentType       string    `json:"content,omitempty"`
+	DatastoreID       string    `json:"storage,omitempty"`
+	FileName          string    `json:"filename,omitempty"`
+	FileReader        io.Reader `json:"-"`
+	FileSize          *int64    `json:"-"`
+	NodeName          string    `json:"node,omitempty"`
 }
 
 // VirtualEnvironmentDatastoreUploadResponseBody contains the body from a datastore upload response.
 type VirtualEnvironmentDatastoreUploadResponseBody struct {
 	UploadID *string `json:"data,omitempty"`
 }
+
+// datastoreUploadProgressReader reports the progress of an upload while the file is being read.
+type datastoreUploadProgressReader struct {
+	bytesRead  int64
+	fileName   string
+	fileSize   *int64
+	lastReport time.Time
+	reader     io.Reader
+}
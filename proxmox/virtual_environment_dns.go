@@ -5,6 +5,7 @@
 package proxmox
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -12,8 +13,13 @@ import (
 
 // GetDNS retrieves the DNS configuration for a node.
 func (c *VirtualEnvironmentClient) GetDNS(nodeName string) (*VirtualEnvironmentDNSGetResponseData, error) {
+	return c.GetDNSWithContext(context.Background(), nodeName)
+}
+
+// GetDNSWithContext is like GetDNS but uses the specified context.
+func (c *VirtualEnvironmentClient) GetDNSWithContext(ctx context.Context, nodeName string) (*VirtualEnvironmentDNSGetResponseData, error) {
 	resBody := &VirtualEnvironmentDNSGetResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("nodes/%s/dns", url.PathEscape(nodeName)), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/dns", url.PathEscape(nodeName)), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -28,5 +34,10 @@ func (c *VirtualEnvironmentClient) GetDNS(nodeName string) (*VirtualEnvironmentD
 
 // UpdateDNS updates the DNS configuration for a node.
 func (c *VirtualEnvironmentClient) UpdateDNS(nodeName string, d *VirtualEnvironmentDNSUpdateRequestBody) error {
-	return c.DoRequest(hmPUT, fmt.Sprintf("nodes/%s/dns", url.PathEscape(nodeName)), d, nil)
+	return c.UpdateDNSWithContext(context.Background(), nodeName, d)
+}
+
+// UpdateDNSWithContext is like UpdateDNS but uses the specified context.
+func (c *VirtualEnvironmentClient) UpdateDNSWithContext(ctx context.Context, nodeName string, d *VirtualEnvironmentDNSUpdateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPUT, fmt.Sprintf("nodes/%s/dns", url.PathEscape(nodeName)), d, nil)
 }
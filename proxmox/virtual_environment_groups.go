@@ -5,6 +5,7 @@
 package proxmox
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -13,18 +14,33 @@ import (
 
 // CreateGroup creates an access group.
 func (c *VirtualEnvironmentClient) CreateGroup(d *VirtualEnvironmentGroupCreateRequestBody) error {
-	return c.DoRequest(hmPOST, "access/groups", d, nil)
+	return c.CreateGroupWithContext(context.Background(), d)
+}
+
+// CreateGroupWithContext is like CreateGroup but uses the specified context.
+func (c *VirtualEnvironmentClient) CreateGroupWithContext(ctx context.Context, d *VirtualEnvironmentGroupCreateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPOST, "access/groups", d, nil)
 }
 
 // DeleteGroup deletes an access group.
 func (c *VirtualEnvironmentClient) DeleteGroup(id string) error {
-	return c.DoRequest(hmDELETE, fmt.Sprintf("access/groups/%s", url.PathEscape(id)), nil, nil)
+	return c.DeleteGroupWithContext(context.Background(), id)
+}
+
+// DeleteGroupWithContext is like DeleteGroup but uses the specified context.
+func (c *VirtualEnvironmentClient) DeleteGroupWithContext(ctx context.Context, id string) error {
+	return c.DoRequestWithContext(ctx, hmDELETE, fmt.Sprintf("access/groups/%s", url.PathEscape(id)), nil, nil)
 }
 
 // GetGroup retrieves an access group.
 func (c *VirtualEnvironmentClient) GetGroup(id string) (*VirtualEnvironmentGroupGetResponseData, error) {
+	return c.GetGroupWithContext(context.Background(), id)
+}
+
+// GetGroupWithContext is like GetGroup but uses the specified context.
+func (c *VirtualEnvironmentClient) GetGroupWithContext(ctx context.Context, id string) (*VirtualEnvironmentGroupGetResponseData, error) {
 	resBody := &VirtualEnvironmentGroupGetResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("access/groups/%s", url.PathEscape(id)), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("access/groups/%s", url.PathEscape(id)), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -41,8 +57,13 @@ func (c *VirtualEnvironmentClient) GetGroup(id string) (*VirtualEnvironmentGroup
 
 // ListGroups retrieves a list of access groups.
 func (c *VirtualEnvironmentClient) ListGroups() ([]*VirtualEnvironmentGroupListResponseData, error) {
+	return c.ListGroupsWithContext(context.Background())
+}
+
+// ListGroupsWithContext is like ListGroups but uses the specified context.
+func (c *VirtualEnvironmentClient) ListGroupsWithContext(ctx context.Context) ([]*VirtualEnvironmentGroupListResponseData, error) {
 	resBody := &VirtualEnvironmentGroupListResponseBody{}
-	err := c.DoRequest(hmGET, "access/groups", nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, "access/groups", nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -61,5 +82,10 @@ func (c *VirtualEnvironmentClient) ListGroups() ([]*VirtualEnvironmentGroupListR
 
 // UpdateGroup updates an access group.
 func (c *VirtualEnvironmentClient) UpdateGroup(id string, d *VirtualEnvironmentGroupUpdateRequestBody) error {
-	return c.DoRequest(hmPUT, fmt.Sprintf("access/groups/%s", url.PathEscape(id)), d, nil)
+	return c.UpdateGroupWithContext(context.Background(), id, d)
+}
+
+// UpdateGroupWithContext is like UpdateGroup but uses the specified context.
+func (c *VirtualEnvironmentClient) UpdateGroupWithContext(ctx context.Context, id string, d *VirtualEnvironmentGroupUpdateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPUT, fmt.Sprintf("access/groups/%s", url.PathEscape(id)), d, nil)
 }
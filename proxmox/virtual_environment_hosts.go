@@ -5,6 +5,7 @@
 package proxmox
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -12,8 +13,13 @@ import (
 
 // GetHosts retrieves the Hosts configuration for a node.
 func (c *VirtualEnvironmentClient) GetHosts(nodeName string) (*VirtualEnvironmentHostsGetResponseData, error) {
+	return c.GetHostsWithContext(context.Background(), nodeName)
+}
+
+// GetHostsWithContext is like GetHosts but uses the specified context.
+func (c *VirtualEnvironmentClient) GetHostsWithContext(ctx context.Context, nodeName string) (*VirtualEnvironmentHostsGetResponseData, error) {
 	resBody := &VirtualEnvironmentHostsGetResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("nodes/%s/hosts", url.PathEscape(nodeName)), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/hosts", url.PathEscape(nodeName)), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -28,5 +34,10 @@ func (c *VirtualEnvironmentClient) GetHosts(nodeName string) (*VirtualEnvironmen
 
 // UpdateHosts updates the Hosts configuration for a node.
 func (c *VirtualEnvironmentClient) UpdateHosts(nodeName string, d *VirtualEnvironmentHostsUpdateRequestBody) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/hosts", url.PathEscape(nodeName)), d, nil)
+	return c.UpdateHostsWithContext(context.Background(), nodeName, d)
+}
+
+// UpdateHostsWithContext is like UpdateHosts but uses the specified context.
+func (c *VirtualEnvironmentClient) UpdateHostsWithContext(ctx context.Context, nodeName string, d *VirtualEnvironmentHostsUpdateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/hosts", url.PathEscape(nodeName)), d, nil)
 }
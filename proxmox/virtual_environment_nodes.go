@@ -5,77 +5,82 @@
 package proxmox
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 )
 
-// ExecuteNodeCommands executes commands on a given node.
-func (c *VirtualEnvironmentClient) ExecuteNodeCommands(nodeName string, commands []string) error {
-	sshClient, err := c.OpenNodeShell(nodeName)
-
-	if err != nil {
-		return err
-	}
-
-	defer sshClient.Close()
-
-	sshSession, err := sshClient.NewSession()
-
-	if err != nil {
-		return err
-	}
-
-	defer sshSession.Close()
+const (
+	nodeCommandCleanupTimeout = 5 * time.Minute
+)
 
-	output, err := sshSession.CombinedOutput(
-		fmt.Sprintf(
-			"/bin/bash -c '%s'",
-			strings.ReplaceAll(strings.Join(commands, " && "), "'", "'\"'\"'"),
-		),
-	)
+// GetNodeIP retrieves the IP address of a node.
+// The address is taken from NodeAddresses, if present. Otherwise, the address of the interface with the default gateway
+// is preferred over the address of the node in the cluster status and the addresses of the remaining interfaces.
+func (c *VirtualEnvironmentClient) GetNodeIP(nodeName string) (*string, error) {
+	return c.GetNodeIPWithContext(context.Background(), nodeName)
+}
 
-	if err != nil {
-		return errors.New(string(output))
+// GetNodeIPWithContext is like GetNodeIP but uses the specified context.
+func (c *VirtualEnvironmentClient) GetNodeIPWithContext(ctx context.Context, nodeName string) (*string, error) {
+	if nodeAddress, ok := c.NodeAddresses[nodeName]; ok && nodeAddress != "" {
+		return &nodeAddress, nil
 	}
 
-	return nil
-}
-
-// GetNodeIP retrieves the IP address of a node.
-func (c *VirtualEnvironmentClient) GetNodeIP(nodeName string) (*string, error) {
-	networkDevices, err := c.ListNodeNetworkDevices(nodeName)
+	networkDevices, err := c.ListNodeNetworkDevicesWithContext(ctx, nodeName)
 
 	if err != nil {
 		return nil, err
 	}
 
-	nodeAddress := ""
-
 	for _, d := range networkDevices {
-		if d.Address != nil {
-			nodeAddress = *d.Address
-			break
+		if d.Address != nil && d.Gateway != nil && *d.Gateway != "" {
+			return getNodeAddressWithoutPrefix(*d.Address), nil
 		}
 	}
 
-	if nodeAddress == "" {
-		return nil, fmt.Errorf("Failed to determine the IP address of node \"%s\"", nodeName)
+	// The cluster status is only available to users with audit privileges, which is why errors are ignored.
+	clusterStatus, err := c.GetClusterStatusWithContext(ctx)
+
+	if err == nil {
+		for _, s := range clusterStatus {
+			if s.Type == "node" && s.Name == nodeName && s.IP != nil && *s.IP != "" {
+				return s.IP, nil
+			}
+		}
+	} else if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
 
-	nodeAddressParts := strings.Split(nodeAddress, "/")
+	for _, d := range networkDevices {
+		if d.Address != nil && *d.Address != "" {
+			return getNodeAddressWithoutPrefix(*d.Address), nil
+		}
+	}
 
-	return &nodeAddressParts[0], nil
+	return nil, fmt.Errorf("Failed to determine the IP address of node \"%s\"", nodeName)
 }
 
 // ListNodeNetworkDevices retrieves a list of network devices for a specific nodes.
 func (c *VirtualEnvironmentClient) ListNodeNetworkDevices(nodeName string) ([]*VirtualEnvironmentNodeNetworkDeviceListResponseData, error) {
+	return c.ListNodeNetworkDevicesWithContext(context.Background(), nodeName)
+}
+
+// ListNodeNetworkDevicesWithContext is like ListNodeNetworkDevices but uses the specified context.
+func (c *VirtualEnvironmentClient) ListNodeNetworkDevicesWithContext(ctx context.Context, nodeName string) ([]*VirtualEnvironmentNodeNetworkDeviceListResponseData, error) {
 	resBody := &VirtualEnvironmentNodeNetworkDeviceListResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("nodes/%s/network", url.PathEscape(nodeName)), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/network", url.PathEscape(nodeName)), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -94,8 +99,13 @@ func (c *VirtualEnvironmentClient) ListNodeNetworkDevices(nodeName string) ([]*V
 
 // ListNodes retrieves a list of nodes.
 func (c *VirtualEnvironmentClient) ListNodes() ([]*VirtualEnvironmentNodeListResponseData, error) {
+	return c.ListNodesWithContext(context.Background())
+}
+
+// ListNodesWithContext is like ListNodes but uses the specified context.
+func (c *VirtualEnvironmentClient) ListNodesWithContext(ctx context.Context) ([]*VirtualEnvironmentNodeListResponseData, error) {
 	resBody := &VirtualEnvironmentNodeListResponseBody{}
-	err := c.DoRequest(hmGET, "nodes", nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, "nodes", nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -112,27 +122,262 @@ func (c *VirtualEnvironmentClient) ListNodes() ([]*VirtualEnvironmentNodeListRes
 	return resBody.Data, nil
 }
 
+// RunNodeCommands executes the steps of a command plan on a node, one at a time.
+// The execution stops at the first failed step, after which the cleanup steps are executed regardless of the outcome.
+// The results of the executed steps, including the cleanup steps, are returned along with a *NodeCommandError, if a step has failed.
+func (c *VirtualEnvironmentClient) RunNodeCommands(nodeName string, plan *VirtualEnvironmentNodeCommandPlan) ([]*VirtualEnvironmentNodeCommandResult, error) {
+	return c.RunNodeCommandsWithContext(context.Background(), nodeName, plan)
+}
+
+// RunNodeCommandsWithContext is like RunNodeCommands but uses the specified context.
+func (c *VirtualEnvironmentClient) RunNodeCommandsWithContext(ctx context.Context, nodeName string, plan *VirtualEnvironmentNodeCommandPlan) ([]*VirtualEnvironmentNodeCommandResult, error) {
+	stepsCtx := ctx
+
+	if plan.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		stepsCtx, cancel = context.WithTimeout(ctx, time.Duration(plan.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	results, err := c.runNodeCommands(stepsCtx, nodeName, plan.Steps, true)
+
+	if len(plan.Cleanup) == 0 {
+		return results, err
+	}
+
+	// The cleanup steps must also run when the steps have timed out or the operation has been cancelled.
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), nodeCommandCleanupTimeout)
+	defer cancel()
+
+	cleanupResults, cleanupErr := c.runNodeCommands(cleanupCtx, nodeName, plan.Cleanup, false)
+	results = append(results, cleanupResults...)
+
+	if err != nil {
+		if cleanupErr != nil {
+			log.Printf("[DEBUG] WARNING: %s", cleanupErr.Error())
+		}
+
+		return results, err
+	}
+
+	return results, cleanupErr
+}
+
 // OpenNodeShell establishes a new SSH connection to a node.
 func (c *VirtualEnvironmentClient) OpenNodeShell(nodeName string) (*ssh.Client, error) {
-	nodeAddress, err := c.GetNodeIP(nodeName)
+	return c.OpenNodeShellWithContext(context.Background(), nodeName)
+}
+
+// OpenNodeShellWithContext is like OpenNodeShell but uses the specified context.
+func (c *VirtualEnvironmentClient) OpenNodeShellWithContext(ctx context.Context, nodeName string) (*ssh.Client, error) {
+	nodeAddress, err := c.GetNodeIPWithContext(ctx, nodeName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sshPort := strconv.Itoa(c.getSSHPort())
+	sshAddress := net.JoinHostPort(*nodeAddress, sshPort)
+	sshHostAddresses := []string{sshAddress}
+
+	if *nodeAddress != nodeName {
+		sshHostAddresses = append(sshHostAddresses, net.JoinHostPort(nodeName, sshPort))
+	}
+
+	sshHostKeyAlgorithms, err := c.sshHostKeyVerifier.hostKeyAlgorithms(sshHostAddresses)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sshAuthMethods, closeAgent, err := c.getSSHAuthMethods(ctx)
 
 	if err != nil {
 		return nil, err
 	}
 
-	ur := strings.Split(c.Username, "@")
+	defer closeAgent()
 
 	sshConfig := &ssh.ClientConfig{
-		User:            ur[0],
-		Auth:            []ssh.AuthMethod{ssh.Password(c.Password)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		User:            c.getSSHUsername(),
+		Auth:            sshAuthMethods,
+		HostKeyCallback: c.sshHostKeyVerifier.callback(sshHostAddresses),
+	}
+
+	// The key exchange must use the algorithms of the known host keys, as other keys cannot be verified.
+	if len(sshHostKeyAlgorithms) > 0 {
+		sshConfig.HostKeyAlgorithms = sshHostKeyAlgorithms
 	}
 
-	sshClient, err := ssh.Dial("tcp", *nodeAddress+":22", sshConfig)
+	release, err := acquireSemaphore(ctx, c.sshSemaphore)
 
 	if err != nil {
 		return nil, err
 	}
 
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", sshAddress)
+
+	if err != nil {
+		release()
+
+		return nil, err
+	}
+
+	sshConn, sshChannels, sshRequests, err := ssh.NewClientConn(conn, sshAddress, sshConfig)
+
+	if err != nil {
+		conn.Close()
+		release()
+
+		return nil, err
+	}
+
+	sshClient := ssh.NewClient(sshConn, sshChannels, sshRequests)
+
+	// The session slot is released once the caller closes the connection.
+	go func() {
+		sshClient.Wait()
+		release()
+	}()
+
 	return sshClient, nil
 }
+
+// runNodeCommands executes commands on a node using a single SSH connection.
+// The first error is returned once all commands have been executed, unless the execution must stop at the first error.
+func (c *VirtualEnvironmentClient) runNodeCommands(ctx context.Context, nodeName string, commands []*VirtualEnvironmentNodeCommand, stopOnError bool) ([]*VirtualEnvironmentNodeCommandResult, error) {
+	results := []*VirtualEnvironmentNodeCommandResult{}
+
+	if len(commands) == 0 {
+		return results, nil
+	}
+
+	sshClient, err := c.OpenNodeShellWithContext(ctx, nodeName)
+
+	if err != nil {
+		return results, err
+	}
+
+	defer sshClient.Close()
+
+	stopWatching := closeOnDone(ctx, sshClient)
+	defer stopWatching()
+
+	var firstErr error
+
+	for _, command := range commands {
+		log.Printf("[DEBUG] Executing step \"%s\" on node \"%s\"", command.Name, nodeName)
+
+		result, err := runNodeCommand(sshClient, command)
+
+		if result != nil {
+			results = append(results, result)
+		}
+
+		if err == nil {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+
+		cmdErr := &NodeCommandError{
+			Err:      err,
+			NodeName: nodeName,
+			Result:   result,
+		}
+
+		if cmdErr.Result == nil {
+			cmdErr.Result = &VirtualEnvironmentNodeCommandResult{
+				Command:  command.Command,
+				ExitCode: -1,
+				Name:     command.Name,
+			}
+		}
+
+		log.Printf("[DEBUG] WARNING: %s", cmdErr.Error())
+
+		if stopOnError || ctx.Err() != nil {
+			return results, cmdErr
+		}
+
+		if firstErr == nil {
+			firstErr = cmdErr
+		}
+	}
+
+	return results, firstErr
+}
+
+// runNodeCommand executes a single command in a new SSH session.
+// The result is nil, if the session could not be established.
+func runNodeCommand(sshClient *ssh.Client, command *VirtualEnvironmentNodeCommand) (*VirtualEnvironmentNodeCommandResult, error) {
+	sshSession, err := sshClient.NewSession()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer sshSession.Close()
+
+	stderr := &bytes.Buffer{}
+	stdout := &bytes.Buffer{}
+
+	sshSession.Stderr = stderr
+	sshSession.Stdout = stdout
+
+	err = sshSession.Run(fmt.Sprintf("/bin/bash -c %s", quoteNodeCommandArgument(command.Command)))
+
+	result := &VirtualEnvironmentNodeCommandResult{
+		Command:  command.Command,
+		ExitCode: 0,
+		Name:     command.Name,
+		Stderr:   stderr.String(),
+		Stdout:   stdout.String(),
+	}
+
+	if err != nil {
+		result.ExitCode = -1
+
+		exitErr := &ssh.ExitError{}
+
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitStatus()
+		}
+	}
+
+	return result, err
+}
+
+// quoteNodeCommandArgument quotes an argument for a POSIX shell.
+func quoteNodeCommandArgument(argument string) string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(argument, "'", "'\"'\"'"))
+}
+
+// getNodeAddressWithoutPrefix removes the prefix length from an address in CIDR notation.
+func getNodeAddressWithoutPrefix(address string) *string {
+	addressParts := strings.Split(address, "/")
+
+	return &addressParts[0]
+}
+
+// closeOnDone closes a resource once the context is done, which aborts any blocking operations.
+// The returned function must be called to stop watching the context.
+func closeOnDone(ctx context.Context, closer io.Closer) func() {
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			closer.Close()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,304 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testNodeCommandHandler returns the exit code and the output of a command, which has been executed on the test SSH server.
+type testNodeCommandHandler func(command string) (exitCode int, stdout string, stderr string)
+
+// testNewNodeCommandClient starts an SSH server, which executes commands using a handler, and creates a client for the node "pve".
+func testNewNodeCommandClient(t *testing.T, handler testNodeCommandHandler) (*VirtualEnvironmentClient, func()) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err.Error())
+	}
+
+	hostKey, err := ssh.NewSignerFromKey(privateKey)
+
+	if err != nil {
+		t.Fatalf("Failed to convert key: %s", err.Error())
+	}
+
+	sshConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	sshConfig.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+
+			if err != nil {
+				return
+			}
+
+			go testServeNodeCommands(conn, sshConfig, handler)
+		}
+	}()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	c.NodeAddresses = map[string]string{"pve": "127.0.0.1"}
+
+	err = c.SetSSHHostKeyPolicy(&VirtualEnvironmentSSHHostKeyPolicy{Insecure: true})
+
+	if err != nil {
+		t.Fatalf("Failed to set SSH host key policy: %s", err.Error())
+	}
+
+	err = c.SetSSHSettings(&VirtualEnvironmentSSHSettings{
+		Password: "secret",
+		Port:     listener.Addr().(*net.TCPAddr).Port,
+		Username: "root",
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to set SSH settings: %s", err.Error())
+	}
+
+	return c, func() {
+		listener.Close()
+		server.Close()
+	}
+}
+
+// testServeNodeCommands handles the exec requests of an SSH connection.
+func testServeNodeCommands(conn net.Conn, sshConfig *ssh.ServerConfig, handler testNodeCommandHandler) {
+	_, channels, requests, err := ssh.NewServerConn(conn, sshConfig)
+
+	if err != nil {
+		return
+	}
+
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer channel.Close()
+
+			for req := range channelRequests {
+				if req.Type != "exec" || len(req.Payload) < 4 {
+					req.Reply(false, nil)
+					continue
+				}
+
+				req.Reply(true, nil)
+
+				exitCode, stdout, stderr := handler(string(req.Payload[4:]))
+
+				channel.Write([]byte(stdout))
+				channel.Stderr().Write([]byte(stderr))
+
+				exitStatus := make([]byte, 4)
+				binary.BigEndian.PutUint32(exitStatus, uint32(exitCode))
+
+				channel.SendRequest("exit-status", false, exitStatus)
+
+				return
+			}
+		}()
+	}
+}
+
+// TestVirtualEnvironmentClientGetNodeIP tests whether the IP address of a node is determined in the right order.
+func TestVirtualEnvironmentClientGetNodeIP(t *testing.T) {
+	tests := []struct {
+		nodeAddresses map[string]string
+		network       string
+		clusterStatus string
+		expected      string
+	}{
+		{
+			map[string]string{"pve": "192.168.0.2"},
+			`{"data":[{"iface":"vmbr0","address":"10.0.0.2","gateway":"10.0.0.1","priority":1,"type":"bridge"}]}`,
+			`{"data":[]}`,
+			"192.168.0.2",
+		},
+		{
+			nil,
+			`{"data":[{"iface":"vmbr1","address":"172.16.0.2/24","priority":1,"type":"bridge"},{"iface":"vmbr0","address":"10.0.0.2","gateway":"10.0.0.1","priority":2,"type":"bridge"}]}`,
+			`{"data":[{"id":"node/pve","ip":"172.16.1.2","name":"pve","type":"node"}]}`,
+			"10.0.0.2",
+		},
+		{
+			map[string]string{"other": "192.168.0.3"},
+			`{"data":[{"iface":"vmbr1","address":"172.16.0.2/24","priority":1,"type":"bridge"}]}`,
+			`{"data":[{"id":"cluster","name":"cluster","type":"cluster"},{"id":"node/pve","ip":"172.16.1.2","name":"pve","type":"node"}]}`,
+			"172.16.1.2",
+		},
+		{
+			nil,
+			`{"data":[{"iface":"vmbr1","address":"172.16.0.2/24","priority":1,"type":"bridge"}]}`,
+			`{"data":[]}`,
+			"172.16.0.2",
+		},
+	}
+
+	for i, test := range tests {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api2/json/nodes/pve/network":
+				w.Write([]byte(test.network))
+			case "/api2/json/cluster/status":
+				w.Write([]byte(test.clusterStatus))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		c := testNewVirtualEnvironmentClient(t, server)
+		c.NodeAddresses = test.nodeAddresses
+
+		nodeAddress, err := c.GetNodeIP("pve")
+
+		server.Close()
+
+		if err != nil {
+			t.Fatalf("Failed to determine the IP address for test %d: %s", i, err.Error())
+		}
+
+		if *nodeAddress != test.expected {
+			t.Fatalf("Expected IP address \"%s\" for test %d - got: \"%s\"", test.expected, i, *nodeAddress)
+		}
+	}
+}
+
+// TestVirtualEnvironmentClientRunNodeCommands tests whether the execution stops at the first failed step and the cleanup steps are executed.
+func TestVirtualEnvironmentClientRunNodeCommands(t *testing.T) {
+	executed := []string{}
+	executedMutex := sync.Mutex{}
+
+	c, closeServer := testNewNodeCommandClient(t, func(command string) (int, string, string) {
+		executedMutex.Lock()
+		executed = append(executed, command)
+		executedMutex.Unlock()
+
+		if strings.Contains(command, "step-2") {
+			return 3, "partial output", "step 2 failed"
+		}
+
+		return 0, "ok", ""
+	})
+
+	defer closeServer()
+
+	results, err := c.RunNodeCommands("pve", &VirtualEnvironmentNodeCommandPlan{
+		Cleanup: []*VirtualEnvironmentNodeCommand{
+			{Command: "echo 'cleanup'", Name: "Cleanup"},
+		},
+		Steps: []*VirtualEnvironmentNodeCommand{
+			{Command: "echo step-1", Name: "Step 1"},
+			{Command: "echo step-2", Name: "Step 2"},
+			{Command: "echo step-3", Name: "Step 3"},
+		},
+	})
+
+	cmdErr := &NodeCommandError{}
+
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("Expected a node command error - got: %v", err)
+	}
+
+	if cmdErr.Result.Name != "Step 2" || cmdErr.Result.ExitCode != 3 || cmdErr.Result.Stderr != "step 2 failed" {
+		t.Fatalf("Expected the error to report step 2 with exit code 3 - got: %s", cmdErr.Error())
+	}
+
+	if !strings.Contains(cmdErr.Error(), "\"Step 2\"") || !strings.Contains(cmdErr.Error(), "step 2 failed") {
+		t.Fatalf("Expected the error message to name the failed step - got: %s", cmdErr.Error())
+	}
+
+	if len(results) != 3 || results[0].Stdout != "ok" || results[2].Name != "Cleanup" {
+		t.Fatalf("Expected the results of steps 1 and 2 and the cleanup step - got: %d results", len(results))
+	}
+
+	expected := []string{
+		"/bin/bash -c 'echo step-1'",
+		"/bin/bash -c 'echo step-2'",
+		"/bin/bash -c 'echo '\"'\"'cleanup'\"'\"''",
+	}
+
+	if strings.Join(executed, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected the commands %v to be executed - got: %v", expected, executed)
+	}
+}
+
+// TestVirtualEnvironmentClientRunNodeCommandsTimeout tests whether the cleanup steps are executed after a timeout.
+func TestVirtualEnvironmentClientRunNodeCommandsTimeout(t *testing.T) {
+	cleanedUp := make(chan struct{}, 1)
+	unblock := make(chan struct{})
+
+	defer close(unblock)
+
+	c, closeServer := testNewNodeCommandClient(t, func(command string) (int, string, string) {
+		if strings.Contains(command, "sleep") {
+			<-unblock
+		} else if strings.Contains(command, "rm") {
+			cleanedUp <- struct{}{}
+		}
+
+		return 0, "", ""
+	})
+
+	defer closeServer()
+
+	_, err := c.RunNodeCommands("pve", &VirtualEnvironmentNodeCommandPlan{
+		Cleanup: []*VirtualEnvironmentNodeCommand{
+			{Command: "rm -f /tmp/test", Name: "Cleanup"},
+		},
+		Steps: []*VirtualEnvironmentNodeCommand{
+			{Command: "sleep 60", Name: "Sleep"},
+		},
+		Timeout: 1,
+	})
+
+	cmdErr := &NodeCommandError{}
+
+	if !errors.As(err, &cmdErr) || cmdErr.Result.Name != "Sleep" {
+		t.Fatalf("Expected a node command error for the step \"Sleep\" - got: %v", err)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the error to be caused by the timeout - got: %s", err.Error())
+	}
+
+	select {
+	case <-cleanedUp:
+	default:
+		t.Fatalf("Expected the cleanup step to be executed")
+	}
+}
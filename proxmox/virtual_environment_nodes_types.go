@@ -6,12 +6,45 @@ package proxmox
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/url"
+	"strings"
 )
 
 // CustomNodeCommands contains an array of commands to execute.
 type CustomNodeCommands []string
 
+// NodeCommandError contains the details of a failed step, which has been executed on a node.
+type NodeCommandError struct {
+	Err      error
+	NodeName string
+	Result   *VirtualEnvironmentNodeCommandResult
+}
+
+// VirtualEnvironmentNodeCommand contains a named step, which can be executed on a node.
+type VirtualEnvironmentNodeCommand struct {
+	Command string
+	Name    string
+}
+
+// VirtualEnvironmentNodeCommandPlan contains the steps to execute on a node, and the cleanup steps which always follow them.
+// The timeout is specified in seconds and does not apply to the cleanup steps.
+type VirtualEnvironmentNodeCommandPlan struct {
+	Cleanup []*VirtualEnvironmentNodeCommand
+	Steps   []*VirtualEnvironmentNodeCommand
+	Timeout int
+}
+
+// VirtualEnvironmentNodeCommandResult contains the exit code and the output of a step, which has been executed on a node.
+// The exit code is -1, if the step did not report one.
+type VirtualEnvironmentNodeCommandResult struct {
+	Command  string
+	ExitCode int
+	Name     string
+	Stderr   string
+	Stdout   string
+}
+
 // VirtualEnvironmentNodeExecuteRequestBody contains the data for a node execute request.
 type VirtualEnvironmentNodeExecuteRequestBody struct {
 	Commands CustomNodeCommands `json:"commands" url:"commands"`
@@ -72,3 +105,27 @@ func (r CustomNodeCommands) EncodeValues(key string, v *url.Values) error {
 
 	return nil
 }
+
+// Error returns a message, which names the failed step and includes its output.
+func (e *NodeCommandError) Error() string {
+	if e.Result.ExitCode < 0 {
+		return fmt.Sprintf("Failed to execute step \"%s\" on node \"%s\" - Reason: %s", e.Result.Name, e.NodeName, e.Err.Error())
+	}
+
+	output := strings.TrimSpace(e.Result.Stderr)
+
+	if output == "" {
+		output = strings.TrimSpace(e.Result.Stdout)
+	}
+
+	if output == "" {
+		output = e.Err.Error()
+	}
+
+	return fmt.Sprintf("Failed to execute step \"%s\" on node \"%s\" (exit code: %d) - Reason: %s", e.Result.Name, e.NodeName, e.Result.ExitCode, output)
+}
+
+// Unwrap returns the underlying error.
+func (e *NodeCommandError) Unwrap() error {
+	return e.Err
+}
@@ -5,6 +5,7 @@
 package proxmox
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -13,18 +14,33 @@ import (
 
 // CreatePool creates an pool.
 func (c *VirtualEnvironmentClient) CreatePool(d *VirtualEnvironmentPoolCreateRequestBody) error {
-	return c.DoRequest(hmPOST, "pools", d, nil)
+	return c.CreatePoolWithContext(context.Background(), d)
+}
+
+// CreatePoolWithContext is like CreatePool but uses the specified context.
+func (c *VirtualEnvironmentClient) CreatePoolWithContext(ctx context.Context, d *VirtualEnvironmentPoolCreateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPOST, "pools", d, nil)
 }
 
 // DeletePool deletes an pool.
 func (c *VirtualEnvironmentClient) DeletePool(id string) error {
-	return c.DoRequest(hmDELETE, fmt.Sprintf("pools/%s", url.PathEscape(id)), nil, nil)
+	return c.DeletePoolWithContext(context.Background(), id)
+}
+
+// DeletePoolWithContext is like DeletePool but uses the specified context.
+func (c *VirtualEnvironmentClient) DeletePoolWithContext(ctx context.Context, id string) error {
+	return c.DoRequestWithContext(ctx, hmDELETE, fmt.Sprintf("pools/%s", url.PathEscape(id)), nil, nil)
 }
 
 // GetPool retrieves an pool.
 func (c *VirtualEnvironmentClient) GetPool(id string) (*VirtualEnvironmentPoolGetResponseData, error) {
+	return c.GetPoolWithContext(context.Background(), id)
+}
+
+// GetPoolWithContext is like GetPool but uses the specified context.
+func (c *VirtualEnvironmentClient) GetPoolWithContext(ctx context.Context, id string) (*VirtualEnvironmentPoolGetResponseData, error) {
 	resBody := &VirtualEnvironmentPoolGetResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("pools/%s", url.PathEscape(id)), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("pools/%s", url.PathEscape(id)), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -43,8 +59,13 @@ func (c *VirtualEnvironmentClient) GetPool(id string) (*VirtualEnvironmentPoolGe
 
 // ListPools retrieves a list of pools.
 func (c *VirtualEnvironmentClient) ListPools() ([]*VirtualEnvironmentPoolListResponseData, error) {
+	return c.ListPoolsWithContext(context.Background())
+}
+
+// ListPoolsWithContext is like ListPools but uses the specified context.
+func (c *VirtualEnvironmentClient) ListPoolsWithContext(ctx context.Context) ([]*VirtualEnvironmentPoolListResponseData, error) {
 	resBody := &VirtualEnvironmentPoolListResponseBody{}
-	err := c.DoRequest(hmGET, "pools", nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, "pools", nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -63,5 +84,10 @@ func (c *VirtualEnvironmentClient) ListPools() ([]*VirtualEnvironmentPoolListRes
 
 // UpdatePool updates an pool.
 func (c *VirtualEnvironmentClient) UpdatePool(id string, d *VirtualEnvironmentPoolUpdateRequestBody) error {
-	return c.DoRequest(hmPUT, fmt.Sprintf("pools/%s", url.PathEscape(id)), d, nil)
+	return c.UpdatePoolWithContext(context.Background(), id, d)
+}
+
+// UpdatePoolWithContext is like UpdatePool but uses the specified context.
+func (c *VirtualEnvironmentClient) UpdatePoolWithContext(ctx context.Context, id string, d *VirtualEnvironmentPoolUpdateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPUT, fmt.Sprintf("pools/%s", url.PathEscape(id)), d, nil)
 }
@@ -5,6 +5,7 @@
 package proxmox
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -13,18 +14,33 @@ import (
 
 // CreateRole creates an access role.
 func (c *VirtualEnvironmentClient) CreateRole(d *VirtualEnvironmentRoleCreateRequestBody) error {
-	return c.DoRequest(hmPOST, "access/roles", d, nil)
+	return c.CreateRoleWithContext(context.Background(), d)
+}
+
+// CreateRoleWithContext is like CreateRole but uses the specified context.
+func (c *VirtualEnvironmentClient) CreateRoleWithContext(ctx context.Context, d *VirtualEnvironmentRoleCreateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPOST, "access/roles", d, nil)
 }
 
 // DeleteRole deletes an access role.
 func (c *VirtualEnvironmentClient) DeleteRole(id string) error {
-	return c.DoRequest(hmDELETE, fmt.Sprintf("access/roles/%s", url.PathEscape(id)), nil, nil)
+	return c.DeleteRoleWithContext(context.Background(), id)
+}
+
+// DeleteRoleWithContext is like DeleteRole but uses the specified context.
+func (c *VirtualEnvironmentClient) DeleteRoleWithContext(ctx context.Context, id string) error {
+	return c.DoRequestWithContext(ctx, hmDELETE, fmt.Sprintf("access/roles/%s", url.PathEscape(id)), nil, nil)
 }
 
 // GetRole retrieves an access role.
 func (c *VirtualEnvironmentClient) GetRole(id string) (*CustomPrivileges, error) {
+	return c.GetRoleWithContext(context.Background(), id)
+}
+
+// GetRoleWithContext is like GetRole but uses the specified context.
+func (c *VirtualEnvironmentClient) GetRoleWithContext(ctx context.Context, id string) (*CustomPrivileges, error) {
 	resBody := &VirtualEnvironmentRoleGetResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("access/roles/%s", url.PathEscape(id)), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("access/roles/%s", url.PathEscape(id)), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -41,8 +57,13 @@ func (c *VirtualEnvironmentClient) GetRole(id string) (*CustomPrivileges, error)
 
 // ListRoles retrieves a list of access roles.
 func (c *VirtualEnvironmentClient) ListRoles() ([]*VirtualEnvironmentRoleListResponseData, error) {
+	return c.ListRolesWithContext(context.Background())
+}
+
+// ListRolesWithContext is like ListRoles but uses the specified context.
+func (c *VirtualEnvironmentClient) ListRolesWithContext(ctx context.Context) ([]*VirtualEnvironmentRoleListResponseData, error) {
 	resBody := &VirtualEnvironmentRoleListResponseBody{}
-	err := c.DoRequest(hmGET, "access/roles", nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, "access/roles", nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -67,5 +88,10 @@ func (c *VirtualEnvironmentClient) ListRoles() ([]*VirtualEnvironmentRoleListRes
 
 // UpdateRole updates an access role.
 func (c *VirtualEnvironmentClient) UpdateRole(id string, d *VirtualEnvironmentRoleUpdateRequestBody) error {
-	return c.DoRequest(hmPUT, fmt.Sprintf("access/roles/%s", url.PathEscape(id)), d, nil)
+	return c.UpdateRoleWithContext(context.Background(), id, d)
+}
+
+// UpdateRoleWithContext is like UpdateRole but uses the specified context.
+func (c *VirtualEnvironmentClient) UpdateRoleWithContext(ctx context.Context, id string, d *VirtualEnvironmentRoleUpdateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPUT, fmt.Sprintf("access/roles/%s", url.PathEscape(id)), d, nil)
 }
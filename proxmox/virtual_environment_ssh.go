@@ -0,0 +1,385 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SetSSHHostKeyPolicy sets the policy, which OpenNodeShell uses to verify the host keys of the nodes.
+func (c *VirtualEnvironmentClient) SetSSHHostKeyPolicy(policy *VirtualEnvironmentSSHHostKeyPolicy) error {
+	verifier, err := newSSHHostKeyVerifier(policy)
+
+	if err != nil {
+		return err
+	}
+
+	c.sshHostKeyVerifier = verifier
+
+	return nil
+}
+
+// SetSSHSettings sets the username, port and credentials, which OpenNodeShell uses to connect to the nodes.
+func (c *VirtualEnvironmentClient) SetSSHSettings(settings *VirtualEnvironmentSSHSettings) error {
+	if settings.Port < 0 || settings.Port > 65535 {
+		return fmt.Errorf("You must specify a valid SSH port (valid: 1-65535)")
+	}
+
+	var signer ssh.Signer
+
+	if len(settings.PrivateKey) > 0 {
+		var err error
+
+		if settings.PrivateKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(settings.PrivateKey, []byte(settings.PrivateKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(settings.PrivateKey)
+		}
+
+		if err != nil {
+			return fmt.Errorf("Failed to parse the SSH private key - Reason: %s", err.Error())
+		}
+	}
+
+	c.sshSettings = settings
+	c.sshSigner = signer
+
+	return nil
+}
+
+// getSSHAuthMethods returns the methods for authenticating SSH connections to the nodes.
+// The returned function must be called to close the connection to the SSH agent, once the handshake has completed.
+func (c *VirtualEnvironmentClient) getSSHAuthMethods(ctx context.Context) ([]ssh.AuthMethod, func(), error) {
+	authMethods := []ssh.AuthMethod{}
+	closeAgent := func() {}
+
+	if c.sshSigner != nil {
+		authMethods = append(authMethods, ssh.PublicKeys(c.sshSigner))
+	}
+
+	if c.sshSettings.Agent {
+		agentSocket := c.sshSettings.AgentSocket
+
+		if agentSocket == "" {
+			agentSocket = os.Getenv("SSH_AUTH_SOCK")
+		}
+
+		if agentSocket == "" {
+			return nil, nil, errors.New("Unable to connect to the SSH agent because no socket has been specified (SSH_AUTH_SOCK is not set)")
+		}
+
+		dialer := &net.Dialer{}
+		agentConn, err := dialer.DialContext(ctx, "unix", agentSocket)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to connect to the SSH agent - Reason: %s", err.Error())
+		}
+
+		authMethods = append(authMethods, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+		closeAgent = func() {
+			agentConn.Close()
+		}
+	}
+
+	password := c.sshSettings.Password
+
+	if password == "" {
+		password = c.Password
+	}
+
+	if password != "" {
+		authMethods = append(authMethods, ssh.Password(password))
+	}
+
+	if len(authMethods) == 0 {
+		return nil, nil, errors.New("Unable to establish an SSH connection to the node because no SSH credentials have been specified (API tokens cannot be used for SSH authentication)")
+	}
+
+	return authMethods, closeAgent, nil
+}
+
+// getSSHPort returns the port of the SSH servers on the nodes.
+func (c *VirtualEnvironmentClient) getSSHPort() int {
+	if c.sshSettings.Port == 0 {
+		return 22
+	}
+
+	return c.sshSettings.Port
+}
+
+// getSSHUsername returns the username for SSH connections to the nodes, which defaults to the user part of the API username.
+func (c *VirtualEnvironmentClient) getSSHUsername() string {
+	if c.sshSettings.Username != "" {
+		return c.sshSettings.Username
+	}
+
+	return strings.Split(c.Username, "@")[0]
+}
+
+// newSSHHostKeyVerifier creates a host key verifier, which enforces a policy.
+func newSSHHostKeyVerifier(policy *VirtualEnvironmentSSHHostKeyPolicy) (*sshHostKeyVerifier, error) {
+	// Pinned host keys must not end up in the known_hosts file of the user, unless it has been requested explicitly.
+	if policy.TrustOnFirstUse && !policy.Insecure && policy.KnownHostsFile == "" {
+		return nil, errors.New("You must specify a known_hosts file, when trust on first use is enabled")
+	}
+
+	verifier := &sshHostKeyVerifier{
+		hostKeys:        []*sshHostKey{},
+		insecure:        policy.Insecure,
+		knownHostsFile:  policy.KnownHostsFile,
+		trustOnFirstUse: policy.TrustOnFirstUse,
+	}
+
+	if verifier.knownHostsFile == "" {
+		homeDir, err := os.UserHomeDir()
+
+		if err == nil {
+			verifier.knownHostsFile = filepath.Join(homeDir, ".ssh", "known_hosts")
+		}
+	}
+
+	for _, v := range policy.HostKeys {
+		hostKey, err := parseSSHHostKey(v)
+
+		if err != nil {
+			return nil, err
+		}
+
+		verifier.hostKeys = append(verifier.hostKeys, hostKey)
+	}
+
+	return verifier, nil
+}
+
+// parseSSHHostKey parses a host key in either the known_hosts or the authorized_keys format.
+// The known_hosts format must be tried first, as the host names would otherwise be interpreted as key options.
+func parseSSHHostKey(hostKey string) (*sshHostKey, error) {
+	_, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(hostKey))
+
+	if err == nil {
+		addresses := make([]string, len(hosts))
+
+		for i, host := range hosts {
+			addresses[i] = knownhosts.Normalize(host)
+		}
+
+		return &sshHostKey{
+			addresses: addresses,
+			key:       key,
+		}, nil
+	}
+
+	key, _, _, _, err = ssh.ParseAuthorizedKey([]byte(hostKey))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the SSH host key \"%s\" (valid: [host] type key)", hostKey)
+	}
+
+	return &sshHostKey{
+		addresses: []string{},
+		key:       key,
+	}, nil
+}
+
+// callback returns a host key callback for a node, which can be reached using any of the specified addresses.
+func (v *sshHostKeyVerifier) callback(addresses []string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if v.insecure {
+			return nil
+		}
+
+		v.mutex.Lock()
+		defer v.mutex.Unlock()
+
+		knownKeys, err := v.knownKeys(addresses)
+
+		if err != nil {
+			return err
+		}
+
+		for _, knownKey := range knownKeys {
+			if bytes.Equal(knownKey.Marshal(), key.Marshal()) {
+				return nil
+			}
+		}
+
+		fingerprint := ssh.FingerprintSHA256(key)
+
+		if len(knownKeys) > 0 {
+			return fmt.Errorf(
+				"The SSH host key for %s (%s) does not match the trusted host key - The node may have been reinstalled or the connection may have been intercepted",
+				strings.Join(addresses, ", "),
+				fingerprint,
+			)
+		}
+
+		if !v.trustOnFirstUse {
+			return fmt.Errorf(
+				"The SSH host key for %s (%s) is unknown - Add it to the known_hosts file or the trusted host keys, or enable trust on first use",
+				strings.Join(addresses, ", "),
+				fingerprint,
+			)
+		}
+
+		log.Printf("[DEBUG] WARNING: Trusting SSH host key for %s (%s) on first use", strings.Join(addresses, ", "), fingerprint)
+
+		v.hostKeys = append(v.hostKeys, &sshHostKey{
+			addresses: addresses,
+			key:       key,
+		})
+
+		return v.pin(addresses, key)
+	}
+}
+
+// hostKeyAlgorithms returns the algorithms of the known host keys, which must be negotiated during the key exchange.
+func (v *sshHostKeyVerifier) hostKeyAlgorithms(addresses []string) ([]string, error) {
+	if v.insecure {
+		return nil, nil
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	knownKeys, err := v.knownKeys(addresses)
+
+	if err != nil {
+		return nil, err
+	}
+
+	algorithms := []string{}
+
+	for _, knownKey := range knownKeys {
+		algorithm := knownKey.Type()
+		duplicate := false
+
+		for _, a := range algorithms {
+			if a == algorithm {
+				duplicate = true
+				break
+			}
+		}
+
+		if !duplicate {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+
+	return algorithms, nil
+}
+
+// knownKeys returns the trusted host keys for a node. The caller must hold the mutex.
+func (v *sshHostKeyVerifier) knownKeys(addresses []string) ([]ssh.PublicKey, error) {
+	knownKeys := []ssh.PublicKey{}
+
+	for _, hostKey := range v.hostKeys {
+		if hostKey.matches(addresses) {
+			knownKeys = append(knownKeys, hostKey.key)
+		}
+	}
+
+	if v.knownHostsFile == "" {
+		return knownKeys, nil
+	}
+
+	_, err := os.Stat(v.knownHostsFile)
+
+	if os.IsNotExist(err) {
+		return knownKeys, nil
+	}
+
+	knownHostsCallback, err := knownhosts.New(v.knownHostsFile)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the SSH known_hosts file \"%s\" - Reason: %s", v.knownHostsFile, err.Error())
+	}
+
+	remote := &net.TCPAddr{IP: net.IPv4zero, Port: 22}
+
+	for _, address := range addresses {
+		err = knownHostsCallback(address, remote, sshProbeKey{})
+
+		keyErr := &knownhosts.KeyError{}
+
+		if errors.As(err, &keyErr) {
+			for _, knownKey := range keyErr.Want {
+				knownKeys = append(knownKeys, knownKey.Key)
+			}
+		}
+	}
+
+	return knownKeys, nil
+}
+
+// pin appends a host key to the known_hosts file. The caller must hold the mutex.
+func (v *sshHostKeyVerifier) pin(addresses []string, key ssh.PublicKey) error {
+	if v.knownHostsFile == "" {
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(v.knownHostsFile), 0700)
+
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(v.knownHostsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = fmt.Fprintln(file, knownhosts.Line(addresses, key))
+
+	return err
+}
+
+// matches determines whether a host key applies to any of the specified addresses.
+func (k *sshHostKey) matches(addresses []string) bool {
+	if len(k.addresses) == 0 {
+		return true
+	}
+
+	for _, address := range addresses {
+		normalizedAddress := knownhosts.Normalize(address)
+
+		for _, a := range k.addresses {
+			if a == normalizedAddress {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Marshal returns an empty key.
+func (k sshProbeKey) Marshal() []byte {
+	return []byte{}
+}
+
+// Type returns a key type, which is not used by any real key.
+func (k sshProbeKey) Type() string {
+	return "probe"
+}
+
+// Verify always fails, as the key cannot be used for authentication.
+func (k sshProbeKey) Verify(data []byte, sig *ssh.Signature) error {
+	return errors.New("The probe key cannot be used for verification")
+}
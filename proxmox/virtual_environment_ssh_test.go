@@ -0,0 +1,346 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testGenerateSSHHostKey generates a random ed25519 host key.
+func testGenerateSSHHostKey(t *testing.T) ssh.PublicKey {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err.Error())
+	}
+
+	key, err := ssh.NewPublicKey(publicKey)
+
+	if err != nil {
+		t.Fatalf("Failed to convert key: %s", err.Error())
+	}
+
+	return key
+}
+
+// testVerifySSHHostKey verifies a host key for the node "pve" with the address 10.0.0.2.
+func testVerifySSHHostKey(t *testing.T, verifier *sshHostKeyVerifier, key ssh.PublicKey) error {
+	addresses := []string{"10.0.0.2:22", "pve:22"}
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 22}
+
+	return verifier.callback(addresses)(addresses[0], remote, key)
+}
+
+// TestSSHHostKeyVerifierHostKeys tests whether inline host keys are trusted.
+func TestSSHHostKeyVerifierHostKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proxmox")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	trustedKey := testGenerateSSHHostKey(t)
+	otherKey := testGenerateSSHHostKey(t)
+
+	tests := []struct {
+		hostKey  string
+		key      ssh.PublicKey
+		expected bool
+	}{
+		{string(ssh.MarshalAuthorizedKey(trustedKey)), trustedKey, true},
+		{string(ssh.MarshalAuthorizedKey(trustedKey)), otherKey, false},
+		{knownhosts.Line([]string{"pve"}, trustedKey), trustedKey, true},
+		{knownhosts.Line([]string{"10.0.0.2"}, trustedKey), trustedKey, true},
+		{knownhosts.Line([]string{"10.0.0.3"}, trustedKey), trustedKey, false},
+	}
+
+	for i, test := range tests {
+		verifier, err := newSSHHostKeyVerifier(&VirtualEnvironmentSSHHostKeyPolicy{
+			HostKeys:       []string{test.hostKey},
+			KnownHostsFile: filepath.Join(dir, "known_hosts"),
+		})
+
+		if err != nil {
+			t.Fatalf("Failed to create verifier for test %d: %s", i, err.Error())
+		}
+
+		err = testVerifySSHHostKey(t, verifier, test.key)
+
+		if (err == nil) != test.expected {
+			t.Fatalf("Expected trust to be %t for test %d - got: %v", test.expected, i, err)
+		}
+	}
+}
+
+// TestSSHHostKeyVerifierKnownHostsFile tests whether host keys are verified against a known_hosts file.
+func TestSSHHostKeyVerifierKnownHostsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proxmox")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	trustedKey := testGenerateSSHHostKey(t)
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+
+	err = ioutil.WriteFile(knownHostsFile, []byte(knownhosts.Line([]string{"pve"}, trustedKey)+"\n"), 0600)
+
+	if err != nil {
+		t.Fatalf("Failed to write known_hosts file: %s", err.Error())
+	}
+
+	verifier, err := newSSHHostKeyVerifier(&VirtualEnvironmentSSHHostKeyPolicy{
+		KnownHostsFile: knownHostsFile,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %s", err.Error())
+	}
+
+	err = testVerifySSHHostKey(t, verifier, trustedKey)
+
+	if err != nil {
+		t.Fatalf("Expected the host key to be trusted - got: %s", err.Error())
+	}
+
+	err = testVerifySSHHostKey(t, verifier, testGenerateSSHHostKey(t))
+
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("Expected a host key mismatch - got: %v", err)
+	}
+}
+
+// TestSSHHostKeyVerifierTrustOnFirstUse tests whether unknown host keys are pinned, when trust on first use is enabled.
+func TestSSHHostKeyVerifierTrustOnFirstUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proxmox")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	_, err = newSSHHostKeyVerifier(&VirtualEnvironmentSSHHostKeyPolicy{
+		TrustOnFirstUse: true,
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "known_hosts file") {
+		t.Fatalf("Expected trust on first use to require a known_hosts file - got: %v", err)
+	}
+
+	knownHostsFile := filepath.Join(dir, "ssh", "known_hosts")
+	firstKey := testGenerateSSHHostKey(t)
+
+	verifier, err := newSSHHostKeyVerifier(&VirtualEnvironmentSSHHostKeyPolicy{
+		KnownHostsFile: knownHostsFile,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %s", err.Error())
+	}
+
+	err = testVerifySSHHostKey(t, verifier, firstKey)
+
+	if err == nil || !strings.Contains(err.Error(), "is unknown") {
+		t.Fatalf("Expected the host key to be unknown - got: %v", err)
+	}
+
+	verifier.trustOnFirstUse = true
+
+	err = testVerifySSHHostKey(t, verifier, firstKey)
+
+	if err != nil {
+		t.Fatalf("Expected the host key to be trusted on first use - got: %s", err.Error())
+	}
+
+	// A new verifier must pick up the pinned key from the known_hosts file.
+	verifier, err = newSSHHostKeyVerifier(&VirtualEnvironmentSSHHostKeyPolicy{
+		KnownHostsFile:  knownHostsFile,
+		TrustOnFirstUse: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %s", err.Error())
+	}
+
+	err = testVerifySSHHostKey(t, verifier, firstKey)
+
+	if err != nil {
+		t.Fatalf("Expected the pinned host key to be trusted - got: %s", err.Error())
+	}
+
+	err = testVerifySSHHostKey(t, verifier, testGenerateSSHHostKey(t))
+
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("Expected a host key mismatch - got: %v", err)
+	}
+}
+
+// testGenerateSSHPrivateKey generates a random RSA private key in the PEM format, which is encrypted, if a passphrase is specified.
+func testGenerateSSHPrivateKey(t *testing.T, passphrase string) []byte {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err.Error())
+	}
+
+	block := &pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}
+
+	if passphrase != "" {
+		block, err = x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte(passphrase), x509.PEMCipherAES256)
+
+		if err != nil {
+			t.Fatalf("Failed to encrypt key: %s", err.Error())
+		}
+	}
+
+	return pem.EncodeToMemory(block)
+}
+
+// TestVirtualEnvironmentClientSetSSHSettings tests whether private keys are parsed and the defaults are applied.
+func TestVirtualEnvironmentClientSetSSHSettings(t *testing.T) {
+	c, err := NewVirtualEnvironmentClient("https://10.0.0.2:8006", "test@pve", "password", "", false)
+
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+
+	if c.getSSHUsername() != "test" || c.getSSHPort() != 22 {
+		t.Fatalf("Expected the default SSH settings to be \"test\" and 22 - got: \"%s\" and %d", c.getSSHUsername(), c.getSSHPort())
+	}
+
+	tests := []struct {
+		settings *VirtualEnvironmentSSHSettings
+		expected bool
+	}{
+		{&VirtualEnvironmentSSHSettings{PrivateKey: testGenerateSSHPrivateKey(t, "")}, true},
+		{&VirtualEnvironmentSSHSettings{PrivateKey: testGenerateSSHPrivateKey(t, "secret"), PrivateKeyPassphrase: "secret"}, true},
+		{&VirtualEnvironmentSSHSettings{PrivateKey: testGenerateSSHPrivateKey(t, "secret"), PrivateKeyPassphrase: "wrong"}, false},
+		{&VirtualEnvironmentSSHSettings{PrivateKey: testGenerateSSHPrivateKey(t, "secret")}, false},
+		{&VirtualEnvironmentSSHSettings{PrivateKey: []byte("invalid")}, false},
+		{&VirtualEnvironmentSSHSettings{Port: 65536}, false},
+	}
+
+	for i, test := range tests {
+		err = c.SetSSHSettings(test.settings)
+
+		if (err == nil) != test.expected {
+			t.Fatalf("Expected success to be %t for test %d - got: %v", test.expected, i, err)
+		}
+	}
+
+	err = c.SetSSHSettings(&VirtualEnvironmentSSHSettings{
+		Port:       2222,
+		PrivateKey: testGenerateSSHPrivateKey(t, ""),
+		Username:   "root",
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to set SSH settings: %s", err.Error())
+	}
+
+	if c.getSSHUsername() != "root" || c.getSSHPort() != 2222 {
+		t.Fatalf("Expected the SSH settings to be \"root\" and 2222 - got: \"%s\" and %d", c.getSSHUsername(), c.getSSHPort())
+	}
+
+	authMethods, closeAgent, err := c.getSSHAuthMethods(context.Background())
+
+	if err != nil {
+		t.Fatalf("Failed to get SSH authentication methods: %s", err.Error())
+	}
+
+	closeAgent()
+
+	if len(authMethods) != 2 {
+		t.Fatalf("Expected 2 SSH authentication methods (key and password) - got: %d", len(authMethods))
+	}
+}
+
+// TestVirtualEnvironmentClientGetSSHAuthMethodsAgent tests whether the SSH agent is used for authentication.
+func TestVirtualEnvironmentClientGetSSHAuthMethodsAgent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proxmox")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	agentSocket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", agentSocket)
+
+	if err != nil {
+		t.Fatalf("Failed to listen on agent socket: %s", err.Error())
+	}
+
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+
+			if err != nil {
+				return
+			}
+
+			go agent.ServeAgent(agent.NewKeyring(), conn)
+		}
+	}()
+
+	c, err := NewVirtualEnvironmentClient("https://10.0.0.2:8006", "", "", "root@pam!test=secret", false)
+
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+
+	_, _, err = c.getSSHAuthMethods(context.Background())
+
+	if err == nil {
+		t.Fatalf("Expected an error, as no SSH credentials have been specified")
+	}
+
+	err = c.SetSSHSettings(&VirtualEnvironmentSSHSettings{
+		Agent:       true,
+		AgentSocket: agentSocket,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to set SSH settings: %s", err.Error())
+	}
+
+	authMethods, closeAgent, err := c.getSSHAuthMethods(context.Background())
+
+	if err != nil {
+		t.Fatalf("Failed to get SSH authentication methods: %s", err.Error())
+	}
+
+	closeAgent()
+
+	if len(authMethods) != 1 {
+		t.Fatalf("Expected 1 SSH authentication method - got: %d", len(authMethods))
+	}
+}
@@ -0,0 +1,49 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// VirtualEnvironmentSSHHostKeyPolicy controls how OpenNodeShell verifies the host keys of the nodes.
+type VirtualEnvironmentSSHHostKeyPolicy struct {
+	HostKeys        []string
+	Insecure        bool
+	KnownHostsFile  string
+	TrustOnFirstUse bool
+}
+
+// VirtualEnvironmentSSHSettings contains the username, port and credentials, which OpenNodeShell uses to connect to the nodes.
+type VirtualEnvironmentSSHSettings struct {
+	Agent                bool
+	AgentSocket          string
+	Password             string
+	Port                 int
+	PrivateKey           []byte
+	PrivateKeyPassphrase string
+	Username             string
+}
+
+// sshHostKey contains a trusted host key, which applies to any host, if no addresses are specified.
+type sshHostKey struct {
+	addresses []string
+	key       ssh.PublicKey
+}
+
+// sshHostKeyVerifier verifies host keys against the trusted host keys and a known_hosts file.
+type sshHostKeyVerifier struct {
+	hostKeys        []*sshHostKey
+	insecure        bool
+	knownHostsFile  string
+	mutex           sync.Mutex
+	trustOnFirstUse bool
+}
+
+// sshProbeKey is a public key, which never matches a known host key.
+// It is used to retrieve the known host keys for a host from a known_hosts file.
+type sshProbeKey struct{}
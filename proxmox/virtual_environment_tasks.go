@@ -0,0 +1,167 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	taskLogTailLines = 20
+)
+
+// GetTaskStatus retrieves the status of a task.
+func (c *VirtualEnvironmentClient) GetTaskStatus(nodeName, upid string) (*VirtualEnvironmentTaskGetStatusResponseData, error) {
+	return c.GetTaskStatusWithContext(context.Background(), nodeName, upid)
+}
+
+// GetTaskStatusWithContext is like GetTaskStatus but uses the specified context.
+func (c *VirtualEnvironmentClient) GetTaskStatusWithContext(ctx context.Context, nodeName, upid string) (*VirtualEnvironmentTaskGetStatusResponseData, error) {
+	resBody := &VirtualEnvironmentTaskGetStatusResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/tasks/%s/status", url.PathEscape(nodeName), url.PathEscape(upid)), nil, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a data object in the response")
+	}
+
+	return resBody.Data, nil
+}
+
+// ListTaskLog retrieves the log lines for a task.
+func (c *VirtualEnvironmentClient) ListTaskLog(nodeName, upid string, d *VirtualEnvironmentTaskLogListRequestBody) (*VirtualEnvironmentTaskLogListResponseBody, error) {
+	return c.ListTaskLogWithContext(context.Background(), nodeName, upid, d)
+}
+
+// ListTaskLogWithContext is like ListTaskLog but uses the specified context.
+func (c *VirtualEnvironmentClient) ListTaskLogWithContext(ctx context.Context, nodeName, upid string, d *VirtualEnvironmentTaskLogListRequestBody) (*VirtualEnvironmentTaskLogListResponseBody, error) {
+	resBody := &VirtualEnvironmentTaskLogListResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/tasks/%s/log", url.PathEscape(nodeName), url.PathEscape(upid)), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a data object in the response")
+	}
+
+	return resBody, nil
+}
+
+// WaitForTask waits for a task to finish and returns an error, if the task did not succeed.
+func (c *VirtualEnvironmentClient) WaitForTask(nodeName, upid string, timeout int, delay int) error {
+	return c.WaitForTaskWithContext(context.Background(), nodeName, upid, timeout, delay)
+}
+
+// WaitForTaskWithContext is like WaitForTask but uses the specified context.
+func (c *VirtualEnvironmentClient) WaitForTaskWithContext(ctx context.Context, nodeName, upid string, timeout int, delay int) error {
+	timeDelay := int64(delay)
+	timeMax := float64(timeout)
+	timeStart := time.Now()
+	timeElapsed := timeStart.Sub(timeStart)
+
+	for timeElapsed.Seconds() < timeMax {
+		if int64(timeElapsed.Seconds())%timeDelay == 0 {
+			data, err := c.GetTaskStatusWithContext(ctx, nodeName, upid)
+
+			if err != nil {
+				return err
+			}
+
+			if data.Status != "running" {
+				if data.ExitStatus != nil && *data.ExitStatus == "OK" {
+					return nil
+				}
+
+				// Tasks, which succeeded with warnings, report the number of warnings instead of "OK".
+				if data.ExitStatus != nil && strings.HasPrefix(*data.ExitStatus, "WARNINGS:") {
+					log.Printf(
+						"[DEBUG] WARNING: Task \"%s\" succeeded with %s - Log:\n%s",
+						upid,
+						strings.ToLower(*data.ExitStatus),
+						c.getTaskLogTail(ctx, nodeName, upid),
+					)
+
+					return nil
+				}
+
+				exitStatus := "unknown"
+
+				if data.ExitStatus != nil {
+					exitStatus = *data.ExitStatus
+				}
+
+				return fmt.Errorf(
+					"Task \"%s\" failed with exit status \"%s\" - Log:\n%s",
+					upid,
+					exitStatus,
+					c.getTaskLogTail(ctx, nodeName, upid),
+				)
+			}
+
+			err = sleepWithContext(ctx, 1*time.Second)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		err := sleepWithContext(ctx, 200*time.Millisecond)
+
+		if err != nil {
+			return err
+		}
+
+		timeElapsed = time.Now().Sub(timeStart)
+	}
+
+	return fmt.Errorf("Timeout while waiting for task \"%s\" to finish", upid)
+}
+
+// getTaskLogTail retrieves the last lines of a task log, which usually contain the reason for a failure.
+func (c *VirtualEnvironmentClient) getTaskLogTail(ctx context.Context, nodeName, upid string) string {
+	limit := 1
+	logData, err := c.ListTaskLogWithContext(ctx, nodeName, upid, &VirtualEnvironmentTaskLogListRequestBody{
+		Limit: &limit,
+	})
+
+	if err != nil || logData.Total == nil {
+		return "(unavailable)"
+	}
+
+	limit = taskLogTailLines
+	start := *logData.Total - taskLogTailLines
+
+	if start < 0 {
+		start = 0
+	}
+
+	logData, err = c.ListTaskLogWithContext(ctx, nodeName, upid, &VirtualEnvironmentTaskLogListRequestBody{
+		Limit: &limit,
+		Start: &start,
+	})
+
+	if err != nil {
+		return "(unavailable)"
+	}
+
+	lines := make([]string, len(logData.Data))
+
+	for i, v := range logData.Data {
+		lines[i] = v.Text
+	}
+
+	return strings.Join(lines, "\n")
+}
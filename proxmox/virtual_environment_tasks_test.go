@@ -0,0 +1,71 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestVirtualEnvironmentClientWaitForTaskFailure tests whether a failed task is reported along with the tail of its log.
+func TestVirtualEnvironmentClientWaitForTaskFailure(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/log") {
+			w.Write([]byte(`{"data":[{"n":1,"t":"trying to acquire lock..."},{"n":2,"t":"storage 'missing' does not exist"}],"total":2}`))
+			return
+		}
+
+		w.Write([]byte(`{"data":{"exitstatus":"storage 'missing' does not exist","status":"stopped","upid":"UPID:pve:1"}}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	err := c.WaitForTask("pve", "UPID:pve:1", 10, 1)
+
+	if err == nil {
+		t.Fatalf("Expected the task to fail")
+	}
+
+	if !strings.Contains(err.Error(), "storage 'missing' does not exist") || !strings.Contains(err.Error(), "trying to acquire lock...") {
+		t.Fatalf("Expected the error to include the exit status and log - got: %s", err.Error())
+	}
+}
+
+// TestVirtualEnvironmentClientWaitForTaskSuccess tests whether a successful task is reported without an error.
+func TestVirtualEnvironmentClientWaitForTaskSuccess(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"exitstatus":"OK","status":"stopped","upid":"UPID:pve:1"}}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	err := c.WaitForTask("pve", "UPID:pve:1", 10, 1)
+
+	if err != nil {
+		t.Fatalf("Expected the task to succeed - got: %s", err.Error())
+	}
+}
+
+// TestVirtualEnvironmentClientWaitForTaskWarnings tests whether a task, which succeeded with warnings, is reported without an error.
+func TestVirtualEnvironmentClientWaitForTaskWarnings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/log") {
+			w.Write([]byte(`{"data":[{"n":1,"t":"WARN: no efidisk configured! Using temporary efivars disk."}],"total":1}`))
+			return
+		}
+
+		w.Write([]byte(`{"data":{"exitstatus":"WARNINGS: 1","status":"stopped","upid":"UPID:pve:1"}}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	err := c.WaitForTask("pve", "UPID:pve:1", 10, 1)
+
+	if err != nil {
+		t.Fatalf("Expected the task to succeed with warnings - got: %s", err.Error())
+	}
+}
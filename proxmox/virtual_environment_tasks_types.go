@@ -0,0 +1,46 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+// VirtualEnvironmentTaskGetStatusResponseBody contains the body from a task status response.
+type VirtualEnvironmentTaskGetStatusResponseBody struct {
+	Data *VirtualEnvironmentTaskGetStatusResponseData `json:"data,omitempty"`
+}
+
+// VirtualEnvironmentTaskGetStatusResponseData contains the data from a task status response.
+type VirtualEnvironmentTaskGetStatusResponseData struct {
+	ExitStatus *string `json:"exitstatus,omitempty"`
+	ID         *string `json:"id,omitempty"`
+	NodeName   string  `json:"node"`
+	PID        *int    `json:"pid,omitempty"`
+	StartTime  *int    `json:"starttime,omitempty"`
+	Status     string  `json:"status"`
+	Type       string  `json:"type"`
+	UPID       string  `json:"upid"`
+	User       string  `json:"user"`
+}
+
+// VirtualEnvironmentTaskIDResponseBody contains the body from a response which starts a task.
+type VirtualEnvironmentTaskIDResponseBody struct {
+	Data *string `json:"data,omitempty"`
+}
+
+// VirtualEnvironmentTaskLogListRequestBody contains the body for a task log list request.
+type VirtualEnvironmentTaskLogListRequestBody struct {
+	Limit *int `json:"limit,omitempty" url:"limit,omitempty"`
+	Start *int `json:"start,omitempty" url:"start,omitempty"`
+}
+
+// VirtualEnvironmentTaskLogListResponseBody contains the body from a task log list response.
+type VirtualEnvironmentTaskLogListResponseBody struct {
+	Data  []*VirtualEnvironmentTaskLogListResponseData `json:"data,omitempty"`
+	Total *int                                         `json:"total,omitempty"`
+}
+
+// VirtualEnvironmentTaskLogListResponseData contains the data from a task log list response.
+type VirtualEnvironmentTaskLogListResponseData struct {
+	LineNumber int    `json:"n"`
+	Text       string `json:"t"`
+}
@@ -5,6 +5,7 @@
 package proxmox
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -14,28 +15,48 @@ import (
 
 // ChangeUserPassword changes a user's password.
 func (c *VirtualEnvironmentClient) ChangeUserPassword(id, password string) error {
+	return c.ChangeUserPasswordWithContext(context.Background(), id, password)
+}
+
+// ChangeUserPasswordWithContext is like ChangeUserPassword but uses the specified context.
+func (c *VirtualEnvironmentClient) ChangeUserPasswordWithContext(ctx context.Context, id, password string) error {
 	d := VirtualEnvironmentUserChangePasswordRequestBody{
 		ID:       id,
 		Password: password,
 	}
 
-	return c.DoRequest(hmPUT, "access/password", d, nil)
+	return c.DoRequestWithContext(ctx, hmPUT, "access/password", d, nil)
 }
 
 // CreateUser creates an user.
 func (c *VirtualEnvironmentClient) CreateUser(d *VirtualEnvironmentUserCreateRequestBody) error {
-	return c.DoRequest(hmPOST, "access/users", d, nil)
+	return c.CreateUserWithContext(context.Background(), d)
+}
+
+// CreateUserWithContext is like CreateUser but uses the specified context.
+func (c *VirtualEnvironmentClient) CreateUserWithContext(ctx context.Context, d *VirtualEnvironmentUserCreateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPOST, "access/users", d, nil)
 }
 
 // DeleteUser deletes an user.
 func (c *VirtualEnvironmentClient) DeleteUser(id string) error {
-	return c.DoRequest(hmDELETE, fmt.Sprintf("access/users/%s", url.PathEscape(id)), nil, nil)
+	return c.DeleteUserWithContext(context.Background(), id)
+}
+
+// DeleteUserWithContext is like DeleteUser but uses the specified context.
+func (c *VirtualEnvironmentClient) DeleteUserWithContext(ctx context.Context, id string) error {
+	return c.DoRequestWithContext(ctx, hmDELETE, fmt.Sprintf("access/users/%s", url.PathEscape(id)), nil, nil)
 }
 
 // GetUser retrieves an user.
 func (c *VirtualEnvironmentClient) GetUser(id string) (*VirtualEnvironmentUserGetResponseData, error) {
+	return c.GetUserWithContext(context.Background(), id)
+}
+
+// GetUserWithContext is like GetUser but uses the specified context.
+func (c *VirtualEnvironmentClient) GetUserWithContext(ctx context.Context, id string) (*VirtualEnvironmentUserGetResponseData, error) {
 	resBody := &VirtualEnvironmentUserGetResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("access/users/%s", url.PathEscape(id)), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("access/users/%s", url.PathEscape(id)), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -59,8 +80,13 @@ func (c *VirtualEnvironmentClient) GetUser(id string) (*VirtualEnvironmentUserGe
 
 // ListUsers retrieves a list of users.
 func (c *VirtualEnvironmentClient) ListUsers() ([]*VirtualEnvironmentUserListResponseData, error) {
+	return c.ListUsersWithContext(context.Background())
+}
+
+// ListUsersWithContext is like ListUsers but uses the specified context.
+func (c *VirtualEnvironmentClient) ListUsersWithContext(ctx context.Context) ([]*VirtualEnvironmentUserListResponseData, error) {
 	resBody := &VirtualEnvironmentUserListResponseBody{}
-	err := c.DoRequest(hmGET, "access/users", nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, "access/users", nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -90,5 +116,10 @@ func (c *VirtualEnvironmentClient) ListUsers() ([]*VirtualEnvironmentUserListRes
 
 // UpdateUser updates an user.
 func (c *VirtualEnvironmentClient) UpdateUser(id string, d *VirtualEnvironmentUserUpdateRequestBody) error {
-	return c.DoRequest(hmPUT, fmt.Sprintf("access/users/%s", url.PathEscape(id)), d, nil)
+	return c.UpdateUserWithContext(context.Background(), id, d)
+}
+
+// UpdateUserWithContext is like UpdateUser but uses the specified context.
+func (c *VirtualEnvironmentClient) UpdateUserWithContext(ctx context.Context, id string, d *VirtualEnvironmentUserUpdateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPUT, fmt.Sprintf("access/users/%s", url.PathEscape(id)), d, nil)
 }
@@ -5,13 +5,23 @@
 package proxmox
 
 import (
+	"context"
 	"errors"
+	"regexp"
+	"strconv"
 )
 
+var versionRegexp = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
 // Version retrieves the version information.
 func (c *VirtualEnvironmentClient) Version() (*VirtualEnvironmentVersionResponseData, error) {
+	return c.VersionWithContext(context.Background())
+}
+
+// VersionWithContext is like Version but uses the specified context.
+func (c *VirtualEnvironmentClient) VersionWithContext(ctx context.Context) (*VirtualEnvironmentVersionResponseData, error) {
 	resBody := &VirtualEnvironmentVersionResponseBody{}
-	err := c.DoRequest(hmGET, "version", nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, "version", nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -23,3 +33,18 @@ func (c *VirtualEnvironmentClient) Version() (*VirtualEnvironmentVersionResponse
 
 	return resBody.Data, nil
 }
+
+// supportsChunkedTransfers determines whether the server accepts requests with chunked transfer encoding, which requires Proxmox VE 6.2 or newer.
+// Unknown version formats are assumed to belong to newer releases.
+func (d *VirtualEnvironmentVersionResponseData) supportsChunkedTransfers() bool {
+	matches := versionRegexp.FindStringSubmatch(d.Version)
+
+	if matches == nil {
+		return true
+	}
+
+	major, _ := strconv.Atoi(matches[1])
+	minor, _ := strconv.Atoi(matches[2])
+
+	return major > 6 || (major == 6 && minor >= 2)
+}
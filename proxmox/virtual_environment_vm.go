@@ -5,43 +5,94 @@
 package proxmox
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"strings"
-	"sync"
 	"time"
 )
 
 const (
-	getVMIDStep = 1
+	// MaximumVMID contains the highest VM identifier, which is accepted by Proxmox VE.
+	MaximumVMID = 999999999
+	// MinimumVMID contains the lowest VM identifier, which can be allocated by GetVMID.
+	MinimumVMID = 100
 )
 
-var (
-	getVMIDCounter      = -1
-	getVMIDCounterMutex = &sync.Mutex{}
-)
+// CloneVM clones a virtual machine and returns the task identifier.
+func (c *VirtualEnvironmentClient) CloneVM(nodeName string, vmID int, d *VirtualEnvironmentVMCloneRequestBody) (*string, error) {
+	return c.CloneVMWithContext(context.Background(), nodeName, vmID, d)
+}
+
+// CloneVMWithContext is like CloneVM but uses the specified context.
+func (c *VirtualEnvironmentClient) CloneVMWithContext(ctx context.Context, nodeName string, vmID int, d *VirtualEnvironmentVMCloneRequestBody) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/clone", url.PathEscape(nodeName), vmID), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
+}
+
+// CreateVM creates a virtual machine and returns the task identifier.
+func (c *VirtualEnvironmentClient) CreateVM(nodeName string, d *VirtualEnvironmentVMCreateRequestBody) (*string, error) {
+	return c.CreateVMWithContext(context.Background(), nodeName, d)
+}
+
+// CreateVMWithContext is like CreateVM but uses the specified context.
+func (c *VirtualEnvironmentClient) CreateVMWithContext(ctx context.Context, nodeName string, d *VirtualEnvironmentVMCreateRequestBody) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/qemu", url.PathEscape(nodeName)), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
 
-// CloneVM clones a virtual machine.
-func (c *VirtualEnvironmentClient) CloneVM(nodeName string, vmID int, d *VirtualEnvironmentVMCloneRequestBody) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/clone", url.PathEscape(nodeName), vmID), d, nil)
+	return resBody.Data, nil
 }
 
-// CreateVM creates a virtual machine.
-func (c *VirtualEnvironmentClient) CreateVM(nodeName string, d *VirtualEnvironmentVMCreateRequestBody) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/qemu", url.PathEscape(nodeName)), d, nil)
+// DeleteVM deletes a virtual machine and returns the task identifier.
+func (c *VirtualEnvironmentClient) DeleteVM(nodeName string, vmID int) (*string, error) {
+	return c.DeleteVMWithContext(context.Background(), nodeName, vmID)
 }
 
-// DeleteVM deletes a virtual machine.
-func (c *VirtualEnvironmentClient) DeleteVM(nodeName string, vmID int) error {
-	return c.DoRequest(hmDELETE, fmt.Sprintf("nodes/%s/qemu/%d", url.PathEscape(nodeName), vmID), nil, nil)
+// DeleteVMWithContext is like DeleteVM but uses the specified context.
+func (c *VirtualEnvironmentClient) DeleteVMWithContext(ctx context.Context, nodeName string, vmID int) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmDELETE, fmt.Sprintf("nodes/%s/qemu/%d", url.PathEscape(nodeName), vmID), nil, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
 }
 
 // GetVM retrieves a virtual machine.
 func (c *VirtualEnvironmentClient) GetVM(nodeName string, vmID int) (*VirtualEnvironmentVMGetResponseData, error) {
+	return c.GetVMWithContext(context.Background(), nodeName, vmID)
+}
+
+// GetVMWithContext is like GetVM but uses the specified context.
+func (c *VirtualEnvironmentClient) GetVMWithContext(ctx context.Context, nodeName string, vmID int) (*VirtualEnvironmentVMGetResponseData, error) {
 	resBody := &VirtualEnvironmentVMGetResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("nodes/%s/qemu/%d/config", url.PathEscape(nodeName), vmID), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/qemu/%d/config", url.PathEscape(nodeName), vmID), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -54,54 +105,105 @@ func (c *VirtualEnvironmentClient) GetVM(nodeName string, vmID int) (*VirtualEnv
 	return resBody.Data, nil
 }
 
-// GetVMID retrieves the next available VM identifier.
+// GetVMID allocates the next available VM identifier within the configured range.
+// An identifier is only returned once it has been verified to be free, and it is never returned again by the same client.
+// Other processes may still claim the identifier before it is used, which is reported as an error that IsAlreadyExists detects.
 func (c *VirtualEnvironmentClient) GetVMID() (*int, error) {
-	getVMIDCounterMutex.Lock()
-	defer getVMIDCounterMutex.Unlock()
+	return c.GetVMIDWithContext(context.Background())
+}
+
+// GetVMIDWithContext is like GetVMID but uses the specified context.
+func (c *VirtualEnvironmentClient) GetVMIDWithContext(ctx context.Context) (*int, error) {
+	c.vmIDMutex.Lock()
+	defer c.vmIDMutex.Unlock()
+
+	vmIDMin, vmIDMax := c.getVMIDRange()
+	nextVMID, err := c.GetClusterNextIDWithContext(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The identifiers below the next free identifier are all in use, which is why the search starts from there.
+	vmID := vmIDMin
+
+	if *nextVMID > vmID {
+		vmID = *nextVMID
+	}
+
+	for ; vmID <= vmIDMax; vmID++ {
+		if c.vmIDReservations[vmID] {
+			continue
+		}
 
-	if getVMIDCounter < 0 {
-		nextVMID, err := c.GetClusterNextID(nil)
+		_, err := c.GetClusterNextIDWithContext(ctx, &vmID)
 
 		if err != nil {
+			if IsAlreadyExists(err) {
+				continue
+			}
+
 			return nil, err
 		}
 
-		if nextVMID == nil {
-			return nil, errors.New("Unable to retrieve the next available VM identifier")
+		if c.vmIDReservations == nil {
+			c.vmIDReservations = map[int]bool{}
 		}
 
-		getVMIDCounter = *nextVMID + getVMIDStep
+		c.vmIDReservations[vmID] = true
 
-		log.Printf("[DEBUG] Determined next available VM identifier to be %d", *nextVMID)
+		log.Printf("[DEBUG] Determined next available VM identifier to be %d", vmID)
+
+		allocatedVMID := vmID
 
-		return nextVMID, nil
+		return &allocatedVMID, nil
 	}
 
-	vmID := getVMIDCounter
+	return nil, fmt.Errorf("Unable to determine the next available VM identifier (range: %d-%d)", vmIDMin, vmIDMax)
+}
 
-	for vmID <= 2147483637 {
-		_, err := c.GetClusterNextID(&vmID)
+// SetVMIDRange restricts the VM identifiers, which are allocated by GetVMID, to a range.
+// A value of zero selects the default lower or upper bound.
+func (c *VirtualEnvironmentClient) SetVMIDRange(min, max int) error {
+	if min == 0 {
+		min = MinimumVMID
+	}
 
-		if err != nil {
-			vmID += getVMIDStep
+	if max == 0 {
+		max = MaximumVMID
+	}
 
-			continue
-		}
+	if min < MinimumVMID || max > MaximumVMID || min > max {
+		return fmt.Errorf("You must specify a valid VM identifier range (valid: %d-%d)", MinimumVMID, MaximumVMID)
+	}
 
-		getVMIDCounter = vmID + getVMIDStep
+	c.vmIDMutex.Lock()
+	defer c.vmIDMutex.Unlock()
 
-		log.Printf("[DEBUG] Determined next available VM identifier to be %d", vmID)
+	c.vmIDMax = max
+	c.vmIDMin = min
 
-		return &vmID, nil
+	return nil
+}
+
+// getVMIDRange returns the range of VM identifiers, which can be allocated. The caller must hold the mutex.
+func (c *VirtualEnvironmentClient) getVMIDRange() (int, int) {
+	if c.vmIDMin == 0 || c.vmIDMax == 0 {
+		return MinimumVMID, MaximumVMID
 	}
 
-	return nil, errors.New("Unable to determine the next available VM identifier")
+	return c.vmIDMin, c.vmIDMax
 }
 
 // GetVMNetworkInterfacesFromAgent retrieves the network interfaces reported by the QEMU agent.
 func (c *VirtualEnvironmentClient) GetVMNetworkInterfacesFromAgent(nodeName string, vmID int) (*VirtualEnvironmentVMGetQEMUNetworkInterfacesResponseData, error) {
+	return c.GetVMNetworkInterfacesFromAgentWithContext(context.Background(), nodeName, vmID)
+}
+
+// GetVMNetworkInterfacesFromAgentWithContext is like GetVMNetworkInterfacesFromAgent but uses the specified context.
+func (c *VirtualEnvironmentClient) GetVMNetworkInterfacesFromAgentWithContext(ctx context.Context, nodeName string, vmID int) (*VirtualEnvironmentVMGetQEMUNetworkInterfacesResponseData, error) {
 	resBody := &VirtualEnvironmentVMGetQEMUNetworkInterfacesResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("nodes/%s/qemu/%d/agent/network-get-interfaces", url.PathEscape(nodeName), vmID), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/qemu/%d/agent/network-get-interfaces", url.PathEscape(nodeName), vmID), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -116,8 +218,13 @@ func (c *VirtualEnvironmentClient) GetVMNetworkInterfacesFromAgent(nodeName stri
 
 // GetVMStatus retrieves the status for a virtual machine.
 func (c *VirtualEnvironmentClient) GetVMStatus(nodeName string, vmID int) (*VirtualEnvironmentVMGetStatusResponseData, error) {
+	return c.GetVMStatusWithContext(context.Background(), nodeName, vmID)
+}
+
+// GetVMStatusWithContext is like GetVMStatus but uses the specified context.
+func (c *VirtualEnvironmentClient) GetVMStatusWithContext(ctx context.Context, nodeName string, vmID int) (*VirtualEnvironmentVMGetStatusResponseData, error) {
 	resBody := &VirtualEnvironmentVMGetStatusResponseBody{}
-	err := c.DoRequest(hmGET, fmt.Sprintf("nodes/%s/qemu/%d/status/current", url.PathEscape(nodeName), vmID), nil, resBody)
+	err := c.DoRequestWithContext(ctx, hmGET, fmt.Sprintf("nodes/%s/qemu/%d/status/current", url.PathEscape(nodeName), vmID), nil, resBody)
 
 	if err != nil {
 		return nil, err
@@ -132,41 +239,157 @@ func (c *VirtualEnvironmentClient) GetVMStatus(nodeName string, vmID int) (*Virt
 
 // ListVMs retrieves a list of virtual machines.
 func (c *VirtualEnvironmentClient) ListVMs() ([]*VirtualEnvironmentVMListResponseData, error) {
+	return c.ListVMsWithContext(context.Background())
+}
+
+// ListVMsWithContext is like ListVMs but uses the specified context.
+func (c *VirtualEnvironmentClient) ListVMsWithContext(ctx context.Context) ([]*VirtualEnvironmentVMListResponseData, error) {
 	return nil, errors.New("Not implemented")
 }
 
-// RebootVM reboots a virtual machine.
-func (c *VirtualEnvironmentClient) RebootVM(nodeName string, vmID int, d *VirtualEnvironmentVMRebootRequestBody) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/status/reboot", url.PathEscape(nodeName), vmID), d, nil)
+// MigrateVM migrates a virtual machine to another node and returns the task identifier.
+func (c *VirtualEnvironmentClient) MigrateVM(nodeName string, vmID int, d *VirtualEnvironmentVMMigrateRequestBody) (*string, error) {
+	return c.MigrateVMWithContext(context.Background(), nodeName, vmID, d)
+}
+
+// MigrateVMWithContext is like MigrateVM but uses the specified context.
+func (c *VirtualEnvironmentClient) MigrateVMWithContext(ctx context.Context, nodeName string, vmID int, d *VirtualEnvironmentVMMigrateRequestBody) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/migrate", url.PathEscape(nodeName), vmID), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
 }
 
-// ShutdownVM shuts down a virtual machine.
-func (c *VirtualEnvironmentClient) ShutdownVM(nodeName string, vmID int, d *VirtualEnvironmentVMShutdownRequestBody) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/status/shutdown", url.PathEscape(nodeName), vmID), d, nil)
+// RebootVM reboots a virtual machine and returns the task identifier.
+func (c *VirtualEnvironmentClient) RebootVM(nodeName string, vmID int, d *VirtualEnvironmentVMRebootRequestBody) (*string, error) {
+	return c.RebootVMWithContext(context.Background(), nodeName, vmID, d)
 }
 
-// StartVM starts a virtual machine.
-func (c *VirtualEnvironmentClient) StartVM(nodeName string, vmID int) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/status/start", url.PathEscape(nodeName), vmID), nil, nil)
+// RebootVMWithContext is like RebootVM but uses the specified context.
+func (c *VirtualEnvironmentClient) RebootVMWithContext(ctx context.Context, nodeName string, vmID int, d *VirtualEnvironmentVMRebootRequestBody) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/status/reboot", url.PathEscape(nodeName), vmID), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
 }
 
-// StopVM stops a virtual machine immediately.
-func (c *VirtualEnvironmentClient) StopVM(nodeName string, vmID int) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/status/stop", url.PathEscape(nodeName), vmID), nil, nil)
+// ShutdownVM shuts down a virtual machine and returns the task identifier.
+func (c *VirtualEnvironmentClient) ShutdownVM(nodeName string, vmID int, d *VirtualEnvironmentVMShutdownRequestBody) (*string, error) {
+	return c.ShutdownVMWithContext(context.Background(), nodeName, vmID, d)
+}
+
+// ShutdownVMWithContext is like ShutdownVM but uses the specified context.
+func (c *VirtualEnvironmentClient) ShutdownVMWithContext(ctx context.Context, nodeName string, vmID int, d *VirtualEnvironmentVMShutdownRequestBody) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/status/shutdown", url.PathEscape(nodeName), vmID), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
+}
+
+// StartVM starts a virtual machine and returns the task identifier.
+func (c *VirtualEnvironmentClient) StartVM(nodeName string, vmID int) (*string, error) {
+	return c.StartVMWithContext(context.Background(), nodeName, vmID)
+}
+
+// StartVMWithContext is like StartVM but uses the specified context.
+func (c *VirtualEnvironmentClient) StartVMWithContext(ctx context.Context, nodeName string, vmID int) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/status/start", url.PathEscape(nodeName), vmID), nil, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
+}
+
+// StopVM stops a virtual machine immediately and returns the task identifier.
+func (c *VirtualEnvironmentClient) StopVM(nodeName string, vmID int) (*string, error) {
+	return c.StopVMWithContext(context.Background(), nodeName, vmID)
+}
+
+// StopVMWithContext is like StopVM but uses the specified context.
+func (c *VirtualEnvironmentClient) StopVMWithContext(ctx context.Context, nodeName string, vmID int) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/status/stop", url.PathEscape(nodeName), vmID), nil, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
 }
 
 // UpdateVM updates a virtual machine.
 func (c *VirtualEnvironmentClient) UpdateVM(nodeName string, vmID int, d *VirtualEnvironmentVMUpdateRequestBody) error {
-	return c.DoRequest(hmPUT, fmt.Sprintf("nodes/%s/qemu/%d/config", url.PathEscape(nodeName), vmID), d, nil)
+	return c.UpdateVMWithContext(context.Background(), nodeName, vmID, d)
+}
+
+// UpdateVMWithContext is like UpdateVM but uses the specified context.
+func (c *VirtualEnvironmentClient) UpdateVMWithContext(ctx context.Context, nodeName string, vmID int, d *VirtualEnvironmentVMUpdateRequestBody) error {
+	return c.DoRequestWithContext(ctx, hmPUT, fmt.Sprintf("nodes/%s/qemu/%d/config", url.PathEscape(nodeName), vmID), d, nil)
 }
 
-// UpdateVMAsync updates a virtual machine asynchronously.
-func (c *VirtualEnvironmentClient) UpdateVMAsync(nodeName string, vmID int, d *VirtualEnvironmentVMUpdateRequestBody) error {
-	return c.DoRequest(hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/config", url.PathEscape(nodeName), vmID), d, nil)
+// UpdateVMAsync updates a virtual machine asynchronously and returns the task identifier.
+func (c *VirtualEnvironmentClient) UpdateVMAsync(nodeName string, vmID int, d *VirtualEnvironmentVMUpdateRequestBody) (*string, error) {
+	return c.UpdateVMAsyncWithContext(context.Background(), nodeName, vmID, d)
+}
+
+// UpdateVMAsyncWithContext is like UpdateVMAsync but uses the specified context.
+func (c *VirtualEnvironmentClient) UpdateVMAsyncWithContext(ctx context.Context, nodeName string, vmID int, d *VirtualEnvironmentVMUpdateRequestBody) (*string, error) {
+	resBody := &VirtualEnvironmentTaskIDResponseBody{}
+	err := c.DoRequestWithContext(ctx, hmPOST, fmt.Sprintf("nodes/%s/qemu/%d/config", url.PathEscape(nodeName), vmID), d, resBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resBody.Data == nil {
+		return nil, errors.New("The server did not include a task identifier in the response")
+	}
+
+	return resBody.Data, nil
 }
 
 // WaitForNetworkInterfacesFromVMAgent waits for a virtual machine's QEMU agent to publish the network interfaces.
 func (c *VirtualEnvironmentClient) WaitForNetworkInterfacesFromVMAgent(nodeName string, vmID int, timeout int, delay int, waitForIP bool) (*VirtualEnvironmentVMGetQEMUNetworkInterfacesResponseData, error) {
+	return c.WaitForNetworkInterfacesFromVMAgentWithContext(context.Background(), nodeName, vmID, timeout, delay, waitForIP)
+}
+
+// WaitForNetworkInterfacesFromVMAgentWithContext is like WaitForNetworkInterfacesFromVMAgent but uses the specified context.
+func (c *VirtualEnvironmentClient) WaitForNetworkInterfacesFromVMAgentWithContext(ctx context.Context, nodeName string, vmID int, timeout int, delay int, waitForIP bool) (*VirtualEnvironmentVMGetQEMUNetworkInterfacesResponseData, error) {
 	timeDelay := int64(delay)
 	timeMax := float64(timeout)
 	timeStart := time.Now()
@@ -174,7 +397,7 @@ func (c *VirtualEnvironmentClient) WaitForNetworkInterfacesFromVMAgent(nodeName
 
 	for timeElapsed.Seconds() < timeMax {
 		if int64(timeElapsed.Seconds())%timeDelay == 0 {
-			data, err := c.GetVMNetworkInterfacesFromAgent(nodeName, vmID)
+			data, err := c.GetVMNetworkInterfacesFromAgentWithContext(ctx, nodeName, vmID)
 
 			if err == nil && data != nil && data.Result != nil {
 				missingIP := false
@@ -193,10 +416,18 @@ func (c *VirtualEnvironmentClient) WaitForNetworkInterfacesFromVMAgent(nodeName
 				}
 			}
 
-			time.Sleep(1 * time.Second)
+			err = sleepWithContext(ctx, 1*time.Second)
+
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		time.Sleep(200 * time.Millisecond)
+		err := sleepWithContext(ctx, 200*time.Millisecond)
+
+		if err != nil {
+			return nil, err
+		}
 
 		timeElapsed = time.Now().Sub(timeStart)
 	}
@@ -206,6 +437,11 @@ func (c *VirtualEnvironmentClient) WaitForNetworkInterfacesFromVMAgent(nodeName
 
 // WaitForNoNetworkInterfacesFromVMAgent waits for a virtual machine's QEMU agent to unpublish the network interfaces.
 func (c *VirtualEnvironmentClient) WaitForNoNetworkInterfacesFromVMAgent(nodeName string, vmID int, timeout int, delay int) error {
+	return c.WaitForNoNetworkInterfacesFromVMAgentWithContext(context.Background(), nodeName, vmID, timeout, delay)
+}
+
+// WaitForNoNetworkInterfacesFromVMAgentWithContext is like WaitForNoNetworkInterfacesFromVMAgent but uses the specified context.
+func (c *VirtualEnvironmentClient) WaitForNoNetworkInterfacesFromVMAgentWithContext(ctx context.Context, nodeName string, vmID int, timeout int, delay int) error {
 	timeDelay := int64(delay)
 	timeMax := float64(timeout)
 	timeStart := time.Now()
@@ -213,16 +449,24 @@ func (c *VirtualEnvironmentClient) WaitForNoNetworkInterfacesFromVMAgent(nodeNam
 
 	for timeElapsed.Seconds() < timeMax {
 		if int64(timeElapsed.Seconds())%timeDelay == 0 {
-			_, err := c.GetVMNetworkInterfacesFromAgent(nodeName, vmID)
+			_, err := c.GetVMNetworkInterfacesFromAgentWithContext(ctx, nodeName, vmID)
 
 			if err != nil {
-				return nil
+				return ctx.Err()
 			}
 
-			time.Sleep(1 * time.Second)
+			err = sleepWithContext(ctx, 1*time.Second)
+
+			if err != nil {
+				return err
+			}
 		}
 
-		time.Sleep(200 * time.Millisecond)
+		err := sleepWithContext(ctx, 200*time.Millisecond)
+
+		if err != nil {
+			return err
+		}
 
 		timeElapsed = time.Now().Sub(timeStart)
 	}
@@ -232,6 +476,11 @@ func (c *VirtualEnvironmentClient) WaitForNoNetworkInterfacesFromVMAgent(nodeNam
 
 // WaitForVMConfigUnlock waits for a virtual machine configuration to become unlocked.
 func (c *VirtualEnvironmentClient) WaitForVMConfigUnlock(nodeName string, vmID int, timeout int, delay int, ignoreErrorResponse bool) error {
+	return c.WaitForVMConfigUnlockWithContext(context.Background(), nodeName, vmID, timeout, delay, ignoreErrorResponse)
+}
+
+// WaitForVMConfigUnlockWithContext is like WaitForVMConfigUnlock but uses the specified context.
+func (c *VirtualEnvironmentClient) WaitForVMConfigUnlockWithContext(ctx context.Context, nodeName string, vmID int, timeout int, delay int, ignoreErrorResponse bool) error {
 	timeDelay := int64(delay)
 	timeMax := float64(timeout)
 	timeStart := time.Now()
@@ -239,7 +488,7 @@ func (c *VirtualEnvironmentClient) WaitForVMConfigUnlock(nodeName string, vmID i
 
 	for timeElapsed.Seconds() < timeMax {
 		if int64(timeElapsed.Seconds())%timeDelay == 0 {
-			data, err := c.GetVMStatus(nodeName, vmID)
+			data, err := c.GetVMStatusWithContext(ctx, nodeName, vmID)
 
 			if err != nil {
 				if !ignoreErrorResponse {
@@ -249,10 +498,18 @@ func (c *VirtualEnvironmentClient) WaitForVMConfigUnlock(nodeName string, vmID i
 				return nil
 			}
 
-			time.Sleep(1 * time.Second)
+			err = sleepWithContext(ctx, 1*time.Second)
+
+			if err != nil {
+				return err
+			}
 		}
 
-		time.Sleep(200 * time.Millisecond)
+		err := sleepWithContext(ctx, 200*time.Millisecond)
+
+		if err != nil {
+			return err
+		}
 
 		timeElapsed = time.Now().Sub(timeStart)
 	}
@@ -262,6 +519,11 @@ func (c *VirtualEnvironmentClient) WaitForVMConfigUnlock(nodeName string, vmID i
 
 // WaitForVMState waits for a virtual machine to reach a specific state.
 func (c *VirtualEnvironmentClient) WaitForVMState(nodeName string, vmID int, state string, timeout int, delay int) error {
+	return c.WaitForVMStateWithContext(context.Background(), nodeName, vmID, state, timeout, delay)
+}
+
+// WaitForVMStateWithContext is like WaitForVMState but uses the specified context.
+func (c *VirtualEnvironmentClient) WaitForVMStateWithContext(ctx context.Context, nodeName string, vmID int, state string, timeout int, delay int) error {
 	state = strings.ToLower(state)
 
 	timeDelay := int64(delay)
@@ -271,7 +533,7 @@ func (c *VirtualEnvironmentClient) WaitForVMState(nodeName string, vmID int, sta
 
 	for timeElapsed.Seconds() < timeMax {
 		if int64(timeElapsed.Seconds())%timeDelay == 0 {
-			data, err := c.GetVMStatus(nodeName, vmID)
+			data, err := c.GetVMStatusWithContext(ctx, nodeName, vmID)
 
 			if err != nil {
 				return err
@@ -281,10 +543,18 @@ func (c *VirtualEnvironmentClient) WaitForVMState(nodeName string, vmID int, sta
 				return nil
 			}
 
-			time.Sleep(1 * time.Second)
+			err = sleepWithContext(ctx, 1*time.Second)
+
+			if err != nil {
+				return err
+			}
 		}
 
-		time.Sleep(200 * time.Millisecond)
+		err := sleepWithContext(ctx, 200*time.Millisecond)
+
+		if err != nil {
+			return err
+		}
 
 		timeElapsed = time.Now().Sub(timeStart)
 	}
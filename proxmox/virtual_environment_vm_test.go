@@ -0,0 +1,120 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestVirtualEnvironmentClientWaitForVMStateWithContextCancellation tests whether WaitForVMStateWithContext returns once the context is cancelled.
+func TestVirtualEnvironmentClientWaitForVMStateWithContextCancellation(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"status":"stopped"}}`))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	timeStart := time.Now()
+	err := c.WaitForVMStateWithContext(ctx, "pve", 100, "running", 60, 1)
+
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected a context error - got: %v", err)
+	}
+
+	if time.Since(timeStart) > 5*time.Second {
+		t.Fatalf("Expected the wait loop to return promptly after the context was cancelled")
+	}
+}
+
+// TestVirtualEnvironmentClientGetVMID tests whether VM identifiers are verified to be free, allocated within the range and never allocated twice.
+func TestVirtualEnvironmentClientGetVMID(t *testing.T) {
+	usedVMIDs := map[int]bool{100: true, 101: true, 1000: true, 1002: true}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api2/json/cluster/nextid" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		vmID, err := strconv.Atoi(r.URL.Query().Get("vmid"))
+
+		if err != nil {
+			w.Write([]byte(`{"data":"102"}`))
+			return
+		}
+
+		if usedVMIDs[vmID] {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf(`{"data":null,"errors":{"vmid":"VM %d already exists"}}`, vmID)))
+			return
+		}
+
+		w.Write([]byte(fmt.Sprintf(`{"data":"%d"}`, vmID)))
+	}))
+	defer server.Close()
+
+	c := testNewVirtualEnvironmentClient(t, server)
+
+	vmID, err := c.GetVMID()
+
+	if err != nil {
+		t.Fatalf("Failed to allocate a VM identifier: %s", err.Error())
+	}
+
+	if *vmID != 102 {
+		t.Fatalf("Expected VM identifier 102 - got: %d", *vmID)
+	}
+
+	err = c.SetVMIDRange(1000, 1003)
+
+	if err != nil {
+		t.Fatalf("Failed to set the VM identifier range: %s", err.Error())
+	}
+
+	for _, expected := range []int{1001, 1003} {
+		vmID, err := c.GetVMID()
+
+		if err != nil {
+			t.Fatalf("Failed to allocate a VM identifier: %s", err.Error())
+		}
+
+		if *vmID != expected {
+			t.Fatalf("Expected VM identifier %d - got: %d", expected, *vmID)
+		}
+	}
+
+	_, err = c.GetVMID()
+
+	if err == nil {
+		t.Fatalf("Expected an error, as the VM identifier range has been exhausted")
+	}
+
+	err = c.SetVMIDRange(1003, 1000)
+
+	if err == nil {
+		t.Fatalf("Expected an error for an invalid VM identifier range")
+	}
+
+	err = c.SetVMIDRange(0, MaximumVMID+1)
+
+	if err == nil {
+		t.Fatalf("Expected an error for a VM identifier range exceeding %d", MaximumVMID)
+	}
+
+	err = c.SetVMIDRange(MaximumVMID, MaximumVMID)
+
+	if err != nil {
+		t.Fatalf("Expected VM identifier %d to be valid - got: %s", MaximumVMID, err.Error())
+	}
+}
@@ -70,9 +70,11 @@ type CustomCPUEmulation struct {
 
 // CustomEFIDisk handles QEMU EFI disk parameters.
 type CustomEFIDisk struct {
-	DiskSize   *int    `json:"size,omitempty" url:"size,omitempty"`
-	FileVolume string  `json:"file" url:"file"`
-	Format     *string `json:"format,omitempty" url:"format,omitempty"`
+	DiskSize        *int        `json:"size,omitempty" url:"size,omitempty"`
+	FileVolume      string      `json:"file" url:"file"`
+	Format          *string     `json:"format,omitempty" url:"format,omitempty"`
+	PreEnrolledKeys *CustomBool `json:"pre-enrolled-keys,omitempty" url:"pre-enrolled-keys,omitempty,int"`
+	Type            *string     `json:"efitype,omitempty" url:"efitype,omitempty"`
 }
 
 // CustomNetworkDevice handles QEMU network device parameters.
@@ -105,8 +107,9 @@ type CustomNUMADevices []CustomNUMADevice
 
 // CustomPCIDevice handles QEMU host PCI device mapping parameters.
 type CustomPCIDevice struct {
-	DeviceIDs  []string    `json:"host" url:"host,semicolon"`
+	DeviceIDs  []string    `json:"host,omitempty" url:"host,omitempty,semicolon"`
 	DevicePath *string     `json:"mdev,omitempty" url:"mdev,omitempty"`
+	Mapping    *string     `json:"mapping,omitempty" url:"mapping,omitempty"`
 	PCIExpress *CustomBool `json:"pcie,omitempty" url:"pcie,omitempty,int"`
 	ROMBAR     *CustomBool `json:"rombar,omitempty" url:"rombar,omitempty,int"`
 	ROMFile    *string     `json:"romfile,omitempty" url:"romfile,omitempty"`
@@ -167,6 +170,12 @@ type CustomStorageDevice struct {
 // CustomStorageDevices handles QEMU SATA device parameters.
 type CustomStorageDevices []CustomStorageDevice
 
+// CustomTPMState handles QEMU TPM state parameters.
+type CustomTPMState struct {
+	FileVolume string  `json:"file" url:"file"`
+	Version    *string `json:"version,omitempty" url:"version,omitempty"`
+}
+
 // CustomUSBDevice handles QEMU USB device parameters.
 type CustomUSBDevice struct {
 	HostDevice string      `json:"host" url:"host"`
@@ -277,6 +286,7 @@ type VirtualEnvironmentVMCreateRequestBody struct {
 	Tags                 *string                      `json:"tags,omitempty" url:"tags,omitempty"`
 	Template             *CustomBool                  `json:"template,omitempty" url:"template,omitempty,int"`
 	TimeDriftFixEnabled  *CustomBool                  `json:"tdf,omitempty" url:"tdf,omitempty,int"`
+	TPMState             *CustomTPMState              `json:"tpmstate0,omitempty" url:"tpmstate0,omitempty"`
 	USBDevices           CustomUSBDevices             `json:"usb,omitempty" url:"usb,omitempty"`
 	VGADevice            *CustomVGADevice             `json:"vga,omitempty" url:"vga,omitempty"`
 	VirtualCPUCount      *int                         `json:"vcpus,omitempty" url:"vcpus,omitempty"`
@@ -397,7 +407,22 @@ type VirtualEnvironmentVMGetResponseData struct {
 	NUMAEnabled          *CustomBool                   `json:"numa,omitempty"`
 	OSType               *string                       `json:"ostype,omitempty"`
 	Overwrite            *CustomBool                   `json:"force,omitempty"`
-	PCIDevices           *CustomPCIDevices             `json:"hostpci,omitempty"`
+	PCIDevice0           *CustomPCIDevice              `json:"hostpci0,omitempty"`
+	PCIDevice1           *CustomPCIDevice              `json:"hostpci1,omitempty"`
+	PCIDevice2           *CustomPCIDevice              `json:"hostpci2,omitempty"`
+	PCIDevice3           *CustomPCIDevice              `json:"hostpci3,omitempty"`
+	PCIDevice4           *CustomPCIDevice              `json:"hostpci4,omitempty"`
+	PCIDevice5           *CustomPCIDevice              `json:"hostpci5,omitempty"`
+	PCIDevice6           *CustomPCIDevice              `json:"hostpci6,omitempty"`
+	PCIDevice7           *CustomPCIDevice              `json:"hostpci7,omitempty"`
+	PCIDevice8           *CustomPCIDevice              `json:"hostpci8,omitempty"`
+	PCIDevice9           *CustomPCIDevice              `json:"hostpci9,omitempty"`
+	PCIDevice10          *CustomPCIDevice              `json:"hostpci10,omitempty"`
+	PCIDevice11          *CustomPCIDevice              `json:"hostpci11,omitempty"`
+	PCIDevice12          *CustomPCIDevice              `json:"hostpci12,omitempty"`
+	PCIDevice13          *CustomPCIDevice              `json:"hostpci13,omitempty"`
+	PCIDevice14          *CustomPCIDevice              `json:"hostpci14,omitempty"`
+	PCIDevice15          *CustomPCIDevice              `json:"hostpci15,omitempty"`
 	PoolID               *string                       `json:"pool,omitempty" url:"pool,omitempty"`
 	Revert               *string                       `json:"revert,omitempty"`
 	SATADevice0          *CustomStorageDevice          `json:"sata0,omitempty"`
@@ -436,7 +461,21 @@ type VirtualEnvironmentVMGetResponseData struct {
 	Tags                 *string                       `json:"tags,omitempty"`
 	Template             *CustomBool                   `json:"template,omitempty"`
 	TimeDriftFixEnabled  *CustomBool                   `json:"tdf,omitempty"`
-	USBDevices           *CustomUSBDevices             `json:"usb,omitempty"`
+	TPMState             *CustomTPMState               `json:"tpmstate0,omitempty"`
+	USBDevice0           *CustomUSBDevice              `json:"usb0,omitempty"`
+	USBDevice1           *CustomUSBDevice              `json:"usb1,omitempty"`
+	USBDevice2           *CustomUSBDevice              `json:"usb2,omitempty"`
+	USBDevice3           *CustomUSBDevice              `json:"usb3,omitempty"`
+	USBDevice4           *CustomUSBDevice              `json:"usb4,omitempty"`
+	USBDevice5           *CustomUSBDevice              `json:"usb5,omitempty"`
+	USBDevice6           *CustomUSBDevice              `json:"usb6,omitempty"`
+	USBDevice7           *CustomUSBDevice              `json:"usb7,omitempty"`
+	USBDevice8           *CustomUSBDevice              `json:"usb8,omitempty"`
+	USBDevice9           *CustomUSBDevice              `json:"usb9,omitempty"`
+	USBDevice10          *CustomUSBDevice              `json:"usb10,omitempty"`
+	USBDevice11          *CustomUSBDevice              `json:"usb11,omitempty"`
+	USBDevice12          *CustomUSBDevice              `json:"usb12,omitempty"`
+	USBDevice13          *CustomUSBDevice              `json:"usb13,omitempty"`
 	VGADevice            *CustomVGADevice              `json:"vga,omitempty"`
 	VirtualCPUCount      *int                          `json:"vcpus,omitempty"`
 	VirtualIODevices     *CustomVirtualIODevices       `json:"virtio,omitempty"`
@@ -477,6 +516,14 @@ type VirtualEnvironmentVMListResponseData struct {
 	ACPI *CustomBool `json:"acpi,omitempty" url:"acpi,omitempty,int"`
 }
 
+// VirtualEnvironmentVMMigrateRequestBody contains the body for a VM migration request.
+type VirtualEnvironmentVMMigrateRequestBody struct {
+	OnlineMigration *CustomBool `json:"online,omitempty,int" url:"online,omitempty,int"`
+	TargetNode      string      `json:"target" url:"target"`
+	TargetStorage   *string     `json:"targetstorage,omitempty" url:"targetstorage,omitempty"`
+	WithLocalDisks  *CustomBool `json:"with-local-disks,omitempty,int" url:"with-local-disks,omitempty,int"`
+}
+
 // VirtualEnvironmentVMRebootRequestBody contains the body for a VM reboot request.
 type VirtualEnvironmentVMRebootRequestBody struct {
 	Timeout *int `json:"timeout,omitempty" url:"timeout,omitempty"`
@@ -658,6 +705,18 @@ func (r CustomEFIDisk) EncodeValues(key string, v *url.Values) error {
 		values = append(values, fmt.Sprintf("format=%s", *r.Format))
 	}
 
+	if r.Type != nil {
+		values = append(values, fmt.Sprintf("efitype=%s", *r.Type))
+	}
+
+	if r.PreEnrolledKeys != nil {
+		if *r.PreEnrolledKeys {
+			values = append(values, "pre-enrolled-keys=1")
+		} else {
+			values = append(values, "pre-enrolled-keys=0")
+		}
+	}
+
 	if r.DiskSize != nil {
 		values = append(values, fmt.Sprintf("size=%d", *r.DiskSize))
 	}
@@ -769,8 +828,14 @@ func (r CustomNUMADevices) EncodeValues(key string, v *url.Values) error {
 
 // EncodeValues converts a CustomPCIDevice struct to a URL vlaue.
 func (r CustomPCIDevice) EncodeValues(key string, v *url.Values) error {
-	values := []string{
-		fmt.Sprintf("host=%s", strings.Join(r.DeviceIDs, ";")),
+	values := []string{}
+
+	if len(r.DeviceIDs) > 0 {
+		values = append(values, fmt.Sprintf("host=%s", strings.Join(r.DeviceIDs, ";")))
+	}
+
+	if r.Mapping != nil {
+		values = append(values, fmt.Sprintf("mapping=%s", *r.Mapping))
 	}
 
 	if r.DevicePath != nil {
@@ -994,6 +1059,21 @@ func (r CustomStorageDevices) EncodeValues(key string, v *url.Values) error {
 	return nil
 }
 
+// EncodeValues converts a CustomTPMState struct to a URL vlaue.
+func (r CustomTPMState) EncodeValues(key string, v *url.Values) error {
+	values := []string{
+		fmt.Sprintf("file=%s", r.FileVolume),
+	}
+
+	if r.Version != nil {
+		values = append(values, fmt.Sprintf("version=%s", *r.Version))
+	}
+
+	v.Add(key, strings.Join(values, ","))
+
+	return nil
+}
+
 // EncodeValues converts a CustomUSBDevice struct to a URL vlaue.
 func (r CustomUSBDevice) EncodeValues(key string, v *url.Values) error {
 	values := []string{
@@ -1283,6 +1363,41 @@ func (r *CustomCPUEmulation) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// UnmarshalJSON converts a CustomEFIDisk string to an object.
+func (r *CustomEFIDisk) UnmarshalJSON(b []byte) error {
+	var s string
+
+	err := json.Unmarshal(b, &s)
+
+	if err != nil {
+		return err
+	}
+
+	pairs := strings.Split(s, ",")
+
+	for _, p := range pairs {
+		v := strings.Split(strings.TrimSpace(p), "=")
+
+		if len(v) == 1 {
+			r.FileVolume = v[0]
+		} else if len(v) == 2 {
+			switch v[0] {
+			case "efitype":
+				r.Type = &v[1]
+			case "file":
+				r.FileVolume = v[1]
+			case "format":
+				r.Format = &v[1]
+			case "pre-enrolled-keys":
+				bv := CustomBool(v[1] == "1")
+				r.PreEnrolledKeys = &bv
+			}
+		}
+	}
+
+	return nil
+}
+
 // UnmarshalJSON converts a CustomNetworkDevice string to an object.
 func (r *CustomNetworkDevice) UnmarshalJSON(b []byte) error {
 	var s string
@@ -1361,6 +1476,49 @@ func (r *CustomNetworkDevice) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// UnmarshalJSON converts a CustomPCIDevice string to an object.
+func (r *CustomPCIDevice) UnmarshalJSON(b []byte) error {
+	var s string
+
+	err := json.Unmarshal(b, &s)
+
+	if err != nil {
+		return err
+	}
+
+	pairs := strings.Split(s, ",")
+
+	for _, p := range pairs {
+		v := strings.Split(strings.TrimSpace(p), "=")
+
+		if len(v) == 1 {
+			r.DeviceIDs = strings.Split(v[0], ";")
+		} else if len(v) == 2 {
+			switch v[0] {
+			case "host":
+				r.DeviceIDs = strings.Split(v[1], ";")
+			case "mapping":
+				r.Mapping = &v[1]
+			case "mdev":
+				r.DevicePath = &v[1]
+			case "pcie":
+				bv := CustomBool(v[1] == "1")
+				r.PCIExpress = &bv
+			case "rombar":
+				bv := CustomBool(v[1] == "1")
+				r.ROMBAR = &bv
+			case "romfile":
+				r.ROMFile = &v[1]
+			case "x-vga":
+				bv := CustomBool(v[1] == "1")
+				r.XVGA = &bv
+			}
+		}
+	}
+
+	return nil
+}
+
 // UnmarshalJSON converts a CustomSharedMemory string to an object.
 func (r *CustomSharedMemory) UnmarshalJSON(b []byte) error {
 	var s string
@@ -1505,6 +1663,67 @@ func (r *CustomStorageDevice) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// UnmarshalJSON converts a CustomTPMState string to an object.
+func (r *CustomTPMState) UnmarshalJSON(b []byte) error {
+	var s string
+
+	err := json.Unmarshal(b, &s)
+
+	if err != nil {
+		return err
+	}
+
+	pairs := strings.Split(s, ",")
+
+	for _, p := range pairs {
+		v := strings.Split(strings.TrimSpace(p), "=")
+
+		if len(v) == 1 {
+			r.FileVolume = v[0]
+		} else if len(v) == 2 {
+			switch v[0] {
+			case "file":
+				r.FileVolume = v[1]
+			case "version":
+				r.Version = &v[1]
+			}
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalJSON converts a CustomUSBDevice string to an object.
+func (r *CustomUSBDevice) UnmarshalJSON(b []byte) error {
+	var s string
+
+	err := json.Unmarshal(b, &s)
+
+	if err != nil {
+		return err
+	}
+
+	pairs := strings.Split(s, ",")
+
+	for _, p := range pairs {
+		v := strings.Split(strings.TrimSpace(p), "=")
+
+		if len(v) == 1 {
+			r.HostDevice = v[0]
+		} else if len(v) == 2 {
+			switch v[0] {
+			case "host":
+				r.HostDevice = v[1]
+			case "usb3":
+				bv := CustomBool(v[1] == "1")
+				r.USB3 = &bv
+			}
+		}
+	}
+
+	return nil
+}
+
 // UnmarshalJSON converts a CustomVGADevice string to an object.
 func (r *CustomVGADevice) UnmarshalJSON(b []byte) error {
 	var s string
@@ -0,0 +1,502 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+// Package proxmoxtest implements an in-memory fake of the Proxmox Virtual Environment API, which enables the client
+// and the provider resources to be tested without a live cluster.
+package proxmoxtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danitso/terraform-provider-proxmox/proxmox"
+)
+
+var serverRoutes = newServerRoutes()
+
+// NewServer starts a server with a single node, a local directory datastore and the root account.
+// The server must be closed, once it is no longer needed.
+func NewServer() *Server {
+	s := &Server{
+		state: &State{
+			ACL:       []*ACLEntry{},
+			APITokens: map[string]string{},
+			Datastores: map[string]*Datastore{
+				"local": {
+					ContentTypes: []string{"backup", "images", "iso", "rootdir", "snippets", "vztmpl"},
+					Files:        map[string]*DatastoreFile{},
+					Path:         "/var/lib/vz",
+					Type:         "dir",
+				},
+			},
+			Groups: map[string]*Group{},
+			Guests: map[int]*Guest{},
+			Nodes: map[string]*Node{
+				DefaultNodeName: {
+					Address:         DefaultNodeAddress,
+					DNSSearchDomain: "example.com",
+					DNSServers:      []string{"1.1.1.1"},
+					Hosts:           fmt.Sprintf("127.0.0.1 localhost.localdomain localhost\n%s %s.example.com %s\n", DefaultNodeAddress, DefaultNodeName, DefaultNodeName),
+				},
+			},
+			Pools: map[string]*Pool{},
+			Roles: map[string]*Role{
+				"Administrator": {
+					Privileges: []string{"Datastore.Allocate", "Sys.Modify", "VM.Allocate"},
+					Special:    true,
+				},
+				"NoAccess": {
+					Privileges: []string{},
+					Special:    true,
+				},
+				"PVEAuditor": {
+					Privileges: []string{"Datastore.Audit", "Sys.Audit", "VM.Audit"},
+					Special:    true,
+				},
+			},
+			Tasks: map[string]*Task{},
+			Users: map[string]*User{
+				proxmox.DefaultRootAccount: {
+					Enabled:  true,
+					Groups:   []string{},
+					Password: DefaultPassword,
+				},
+			},
+			Version: DefaultVersion,
+		},
+		tickets: map[string]*serverTicket{},
+	}
+
+	s.server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Do calls the specified function with exclusive access to the state, which enables tests to seed objects and to
+// simulate changes made outside of Terraform.
+func (s *Server) Do(fn func(state *State)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fn(s.state)
+}
+
+// ExpireTickets invalidates all authentication tickets, which forces clients to re-authenticate.
+func (s *Server) ExpireTickets() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.tickets = map[string]*serverTicket{}
+}
+
+// NewClient creates a client, which authenticates against the server with the root account.
+func (s *Server) NewClient() (*proxmox.VirtualEnvironmentClient, error) {
+	return proxmox.NewVirtualEnvironmentClient(s.URL, proxmox.DefaultRootAccount, DefaultPassword, "", true)
+}
+
+// authenticate validates the ticket or the API token of a request and returns the authenticated user.
+func (s *Server) authenticate(r *http.Request) (string, error) {
+	authorization := r.Header.Get("Authorization")
+
+	if strings.HasPrefix(authorization, "PVEAPIToken=") {
+		token := strings.SplitN(strings.TrimPrefix(authorization, "PVEAPIToken="), "=", 2)
+
+		if len(token) == 2 {
+			if secret, ok := s.state.APITokens[token[0]]; ok && secret == token[1] {
+				return strings.SplitN(token[0], "!", 2)[0], nil
+			}
+		}
+
+		return "", newServerError(http.StatusUnauthorized, "invalid token value!")
+	}
+
+	cookie, err := r.Cookie("PVEAuthCookie")
+
+	if err != nil {
+		return "", newServerError(http.StatusUnauthorized, "no ticket")
+	}
+
+	ticket, ok := s.tickets[cookie.Value]
+
+	if !ok {
+		return "", newServerError(http.StatusUnauthorized, "invalid ticket")
+	}
+
+	if r.Method != http.MethodGet && r.Header.Get("CSRFPreventionToken") != ticket.csrfPreventionToken {
+		return "", newServerError(http.StatusUnauthorized, "Permission denied - invalid csrf token")
+	}
+
+	return ticket.username, nil
+}
+
+// serveHTTP authenticates a request and dispatches it to the matching handler.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	segments, err := splitRequestPath(r.URL.EscapedPath())
+
+	if err != nil {
+		writeResponse(w, nil, err)
+
+		return
+	}
+
+	// Multipart bodies are streamed by the upload handler, which is why they must not be parsed in advance.
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err = r.ParseForm()
+
+		if err != nil {
+			writeResponse(w, nil, newServerError(http.StatusBadRequest, err.Error()))
+
+			return
+		}
+	}
+
+	if r.Method == http.MethodPost && strings.Join(segments, "/") == "access/ticket" {
+		data, err := s.createTicket(&serverRequest{request: r})
+		writeResponse(w, data, err)
+
+		return
+	}
+
+	username, err := s.authenticate(r)
+
+	if err != nil {
+		writeResponse(w, nil, err)
+
+		return
+	}
+
+	for _, route := range serverRoutes {
+		params, ok := route.match(r.Method, segments)
+
+		if !ok {
+			continue
+		}
+
+		data, err := route.handler(s, &serverRequest{
+			params:   params,
+			request:  r,
+			username: username,
+		})
+
+		writeResponse(w, data, err)
+
+		return
+	}
+
+	writeResponse(w, nil, newServerError(http.StatusNotImplemented, fmt.Sprintf("Method '%s %s' not implemented", r.Method, r.URL.Path)))
+}
+
+// match determines whether a route matches a request and returns the path parameters.
+func (r *serverRoute) match(method string, segments []string) (map[string]string, bool) {
+	patternSegments := strings.Split(r.pattern, "/")
+
+	if r.method != method || len(patternSegments) != len(segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+
+	for k, v := range r.params {
+		params[k] = v
+	}
+
+	for i, p := range patternSegments {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			params[p[1:len(p)-1]] = segments[i]
+		} else if p != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// bool retrieves a boolean form value.
+func (r *serverRequest) bool(key string, defaultValue bool) bool {
+	v, ok := r.value(key)
+
+	if !ok {
+		return defaultValue
+	}
+
+	return v == "1" || v == "true"
+}
+
+// list retrieves a comma separated form value.
+func (r *serverRequest) list(key string) []string {
+	v, ok := r.value(key)
+
+	if !ok || v == "" {
+		return []string{}
+	}
+
+	return strings.Split(v, ",")
+}
+
+// value retrieves a form value and reports whether it has been specified.
+func (r *serverRequest) value(key string) (string, bool) {
+	values, ok := r.request.Form[key]
+
+	if !ok || len(values) == 0 {
+		return "", false
+	}
+
+	return values[0], true
+}
+
+// Error returns the error message.
+func (e *serverError) Error() string {
+	return e.message
+}
+
+// encodeValues converts raw values to JSON values, which can be decoded into the fields of the specified type.
+// Booleans and numbers are encoded as JSON numbers, just like the API does, while any other value is left as a string.
+func encodeValues(values map[string]string, v interface{}) map[string]interface{} {
+	fieldTypes := map[string]reflect.Type{}
+	t := reflect.TypeOf(v)
+
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		fieldType := t.Field(i).Type
+
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		fieldTypes[name] = fieldType
+	}
+
+	encodedValues := map[string]interface{}{}
+
+	for k, v := range values {
+		encodedValues[k] = v
+
+		fieldType, ok := fieldTypes[k]
+
+		if !ok {
+			continue
+		}
+
+		switch fieldType.Kind() {
+		case reflect.Bool:
+			if v == "1" || v == "true" {
+				encodedValues[k] = 1
+			} else {
+				encodedValues[k] = 0
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+				encodedValues[k] = i
+			}
+		case reflect.Float32, reflect.Float64:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				encodedValues[k] = f
+			}
+		}
+	}
+
+	return encodedValues
+}
+
+// newServerRoutes returns the routes, which are supported by the server.
+func newServerRoutes() []*serverRoute {
+	routes := []*serverRoute{
+		{(*Server).getACL, http.MethodGet, "access/acl", nil},
+		{(*Server).updateACL, http.MethodPut, "access/acl", nil},
+		{(*Server).listGroups, http.MethodGet, "access/groups", nil},
+		{(*Server).createGroup, http.MethodPost, "access/groups", nil},
+		{(*Server).deleteGroup, http.MethodDelete, "access/groups/{id}", nil},
+		{(*Server).getGroup, http.MethodGet, "access/groups/{id}", nil},
+		{(*Server).updateGroup, http.MethodPut, "access/groups/{id}", nil},
+		{(*Server).updatePassword, http.MethodPut, "access/password", nil},
+		{(*Server).listRoles, http.MethodGet, "access/roles", nil},
+		{(*Server).createRole, http.MethodPost, "access/roles", nil},
+		{(*Server).deleteRole, http.MethodDelete, "access/roles/{id}", nil},
+		{(*Server).getRole, http.MethodGet, "access/roles/{id}", nil},
+		{(*Server).updateRole, http.MethodPut, "access/roles/{id}", nil},
+		{(*Server).listUsers, http.MethodGet, "access/users", nil},
+		{(*Server).createUser, http.MethodPost, "access/users", nil},
+		{(*Server).deleteUser, http.MethodDelete, "access/users/{id}", nil},
+		{(*Server).getUser, http.MethodGet, "access/users/{id}", nil},
+		{(*Server).updateUser, http.MethodPut, "access/users/{id}", nil},
+		{(*Server).getClusterNextID, http.MethodGet, "cluster/nextid", nil},
+		{(*Server).getClusterStatus, http.MethodGet, "cluster/status", nil},
+		{(*Server).listNodes, http.MethodGet, "nodes", nil},
+		{(*Server).deleteCertificate, http.MethodDelete, "nodes/{node}/certificates/custom", nil},
+		{(*Server).updateCertificate, http.MethodPost, "nodes/{node}/certificates/custom", nil},
+		{(*Server).getCertificates, http.MethodGet, "nodes/{node}/certificates/info", nil},
+		{(*Server).getDNS, http.MethodGet, "nodes/{node}/dns", nil},
+		{(*Server).updateDNS, http.MethodPut, "nodes/{node}/dns", nil},
+		{(*Server).getHosts, http.MethodGet, "nodes/{node}/hosts", nil},
+		{(*Server).updateHosts, http.MethodPost, "nodes/{node}/hosts", nil},
+		{(*Server).listNetworkDevices, http.MethodGet, "nodes/{node}/network", nil},
+		{(*Server).listDatastores, http.MethodGet, "nodes/{node}/storage", nil},
+		{(*Server).listDatastoreFiles, http.MethodGet, "nodes/{node}/storage/{storage}/content", nil},
+		{(*Server).deleteDatastoreFile, http.MethodDelete, "nodes/{node}/storage/{storage}/content/{volume}", nil},
+		{(*Server).downloadDatastoreFile, http.MethodPost, "nodes/{node}/storage/{storage}/download-url", nil},
+		{(*Server).uploadDatastoreFile, http.MethodPost, "nodes/{node}/storage/{storage}/upload", nil},
+		{(*Server).listTaskLog, http.MethodGet, "nodes/{node}/tasks/{upid}/log", nil},
+		{(*Server).getTaskStatus, http.MethodGet, "nodes/{node}/tasks/{upid}/status", nil},
+		{(*Server).listPools, http.MethodGet, "pools", nil},
+		{(*Server).createPool, http.MethodPost, "pools", nil},
+		{(*Server).deletePool, http.MethodDelete, "pools/{id}", nil},
+		{(*Server).getPool, http.MethodGet, "pools/{id}", nil},
+		{(*Server).updatePool, http.MethodPut, "pools/{id}", nil},
+		{(*Server).getDatastore, http.MethodGet, "storage/{storage}", nil},
+		{(*Server).getVersion, http.MethodGet, "version", nil},
+		{(*Server).getGuestNetworkInterfaces, http.MethodGet, "nodes/{node}/qemu/{vmid}/agent/network-get-interfaces", map[string]string{"type": guestTypeVM}},
+	}
+
+	for _, guestType := range []string{guestTypeContainer, guestTypeVM} {
+		params := map[string]string{"type": guestType}
+
+		routes = append(
+			routes,
+			&serverRoute{(*Server).createGuest, http.MethodPost, fmt.Sprintf("nodes/{node}/%s", guestType), params},
+			&serverRoute{(*Server).deleteGuest, http.MethodDelete, fmt.Sprintf("nodes/{node}/%s/{vmid}", guestType), params},
+			&serverRoute{(*Server).cloneGuest, http.MethodPost, fmt.Sprintf("nodes/{node}/%s/{vmid}/clone", guestType), params},
+			&serverRoute{(*Server).migrateGuest, http.MethodPost, fmt.Sprintf("nodes/{node}/%s/{vmid}/migrate", guestType), params},
+			&serverRoute{(*Server).getGuestConfig, http.MethodGet, fmt.Sprintf("nodes/{node}/%s/{vmid}/config", guestType), params},
+			&serverRoute{(*Server).updateGuestConfig, http.MethodPost, fmt.Sprintf("nodes/{node}/%s/{vmid}/config", guestType), params},
+			&serverRoute{(*Server).updateGuestConfig, http.MethodPut, fmt.Sprintf("nodes/{node}/%s/{vmid}/config", guestType), params},
+			&serverRoute{(*Server).getGuestStatus, http.MethodGet, fmt.Sprintf("nodes/{node}/%s/{vmid}/status/current", guestType), params},
+			&serverRoute{(*Server).updateGuestStatus, http.MethodPost, fmt.Sprintf("nodes/{node}/%s/{vmid}/status/{action}", guestType), params},
+		)
+	}
+
+	return routes
+}
+
+// newServerError creates a new error with the specified status code and message.
+func newServerError(statusCode int, message string) *serverError {
+	return &serverError{
+		errors:     map[string]string{},
+		message:    message,
+		statusCode: statusCode,
+	}
+}
+
+// newServerParameterError creates a new error, which reports an invalid parameter.
+func newServerParameterError(key, message string) *serverError {
+	return &serverError{
+		errors:     map[string]string{key: message},
+		message:    "Parameter verification failed.",
+		statusCode: http.StatusBadRequest,
+	}
+}
+
+// sortedKeys returns the keys of a map in ascending order.
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// splitRequestPath splits the path of a request into unescaped segments relative to the JSON API.
+func splitRequestPath(escapedPath string) ([]string, error) {
+	if !strings.HasPrefix(escapedPath, "/api2/json/") {
+		return nil, newServerError(http.StatusNotFound, "Not Found")
+	}
+
+	segments := strings.Split(strings.TrimSuffix(strings.TrimPrefix(escapedPath, "/api2/json/"), "/"), "/")
+
+	for i, v := range segments {
+		unescapedValue, err := url.PathUnescape(v)
+
+		if err != nil {
+			return nil, newServerError(http.StatusBadRequest, err.Error())
+		}
+
+		segments[i] = unescapedValue
+	}
+
+	return segments, nil
+}
+
+// writeError writes an error to a response.
+// The message is used as the reason phrase of the status line, just like the API does, which requires the connection
+// to be hijacked, as the standard library only supports the default reason phrases.
+func writeError(w http.ResponseWriter, err error) {
+	sErr, ok := err.(*serverError)
+
+	if !ok {
+		sErr = newServerError(http.StatusInternalServerError, err.Error())
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"data":   nil,
+		"errors": sErr.errors,
+	})
+
+	hijacker, ok := w.(http.Hijacker)
+
+	if !ok {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		w.WriteHeader(sErr.statusCode)
+		w.Write(body)
+
+		return
+	}
+
+	conn, buffer, hErr := hijacker.Hijack()
+
+	if hErr != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	reason := strings.NewReplacer("\r", " ", "\n", " ").Replace(sErr.message)
+
+	fmt.Fprintf(buffer, "HTTP/1.1 %d %s\r\n", sErr.statusCode, reason)
+	fmt.Fprintf(buffer, "Connection: close\r\n")
+	fmt.Fprintf(buffer, "Content-Length: %d\r\n", len(body))
+	fmt.Fprintf(buffer, "Content-Type: application/json;charset=UTF-8\r\n\r\n")
+
+	buffer.Write(body)
+	buffer.Flush()
+}
+
+// writeResponse writes the data or the error to a response.
+func writeResponse(w http.ResponseWriter, data interface{}, err error) {
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+
+	if body, ok := data.(serverResponseBody); ok {
+		json.NewEncoder(w).Encode(body)
+
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": data,
+	})
+}
@@ -0,0 +1,650 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmoxtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danitso/terraform-provider-proxmox/proxmox"
+)
+
+// createGroup handles POST access/groups.
+func (s *Server) createGroup(r *serverRequest) (interface{}, error) {
+	id, _ := r.value("groupid")
+
+	if id == "" {
+		return nil, newServerParameterError("groupid", "property is missing and it is not optional")
+	}
+
+	if _, ok := s.state.Groups[id]; ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("create group failed: group '%s' already exists", id))
+	}
+
+	comment, _ := r.value("comment")
+
+	s.state.Groups[id] = &Group{
+		Comment: comment,
+	}
+
+	return nil, nil
+}
+
+// createPool handles POST pools.
+func (s *Server) createPool(r *serverRequest) (interface{}, error) {
+	id, _ := r.value("poolid")
+
+	if id == "" {
+		return nil, newServerParameterError("poolid", "property is missing and it is not optional")
+	}
+
+	if _, ok := s.state.Pools[id]; ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("create pool failed: pool '%s' already exists", id))
+	}
+
+	comment, _ := r.value("comment")
+
+	s.state.Pools[id] = &Pool{
+		Comment: comment,
+	}
+
+	return nil, nil
+}
+
+// createRole handles POST access/roles.
+func (s *Server) createRole(r *serverRequest) (interface{}, error) {
+	id, _ := r.value("roleid")
+
+	if id == "" {
+		return nil, newServerParameterError("roleid", "property is missing and it is not optional")
+	}
+
+	if _, ok := s.state.Roles[id]; ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("create role failed: role '%s' already exists", id))
+	}
+
+	s.state.Roles[id] = &Role{
+		Privileges: r.list("privs"),
+	}
+
+	return nil, nil
+}
+
+// createTicket handles POST access/ticket.
+func (s *Server) createTicket(r *serverRequest) (interface{}, error) {
+	username, _ := r.value("username")
+	password, _ := r.value("password")
+	user, ok := s.state.Users[username]
+
+	if !ok || !user.Enabled || user.Password != password {
+		return nil, newServerError(http.StatusUnauthorized, "authentication failure")
+	}
+
+	ticket := &serverTicket{
+		csrfPreventionToken: fmt.Sprintf("%X:%s", time.Now().Unix(), randomHex(16)),
+		username:            username,
+	}
+
+	ticketID := fmt.Sprintf("PVE:%s:%X::%s", username, time.Now().Unix(), randomHex(32))
+	s.tickets[ticketID] = ticket
+
+	return &proxmox.VirtualEnvironmentAuthenticationResponseData{
+		CSRFPreventionToken: &ticket.csrfPreventionToken,
+		Ticket:              &ticketID,
+		Username:            username,
+	}, nil
+}
+
+// createUser handles POST access/users.
+func (s *Server) createUser(r *serverRequest) (interface{}, error) {
+	id, _ := r.value("userid")
+
+	if !strings.Contains(id, "@") {
+		return nil, newServerParameterError("userid", "value does not look like a valid user ID")
+	}
+
+	if _, ok := s.state.Users[id]; ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("create user failed: user '%s' already exists", id))
+	}
+
+	groups := r.list("groups")
+
+	err := s.validateGroups(groups)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user := &User{
+		Enabled: r.bool("enable", true),
+		Groups:  groups,
+	}
+
+	user.Comment, _ = r.value("comment")
+	user.Email, _ = r.value("email")
+	user.FirstName, _ = r.value("firstname")
+	user.Keys, _ = r.value("keys")
+	user.LastName, _ = r.value("lastname")
+	user.Password, _ = r.value("password")
+
+	if v, ok := r.value("expire"); ok {
+		user.ExpirationDate, _ = strconv.ParseInt(v, 10, 64)
+	}
+
+	s.state.Users[id] = user
+
+	return nil, nil
+}
+
+// deleteGroup handles DELETE access/groups/{id}.
+func (s *Server) deleteGroup(r *serverRequest) (interface{}, error) {
+	id := r.params["id"]
+
+	if _, ok := s.state.Groups[id]; !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("group '%s' does not exist", id))
+	}
+
+	delete(s.state.Groups, id)
+
+	for _, user := range s.state.Users {
+		user.Groups = removeString(user.Groups, id)
+	}
+
+	s.removeACLEntries(func(e *ACLEntry) bool {
+		return e.Type == "group" && e.UserOrGroupID == id
+	})
+
+	return nil, nil
+}
+
+// deletePool handles DELETE pools/{id}.
+func (s *Server) deletePool(r *serverRequest) (interface{}, error) {
+	id := r.params["id"]
+
+	if _, ok := s.state.Pools[id]; !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("pool '%s' does not exist", id))
+	}
+
+	for _, guest := range s.state.Guests {
+		if guest.PoolID == id {
+			return nil, newServerError(http.StatusInternalServerError, "delete pool failed: pool is not empty.")
+		}
+	}
+
+	delete(s.state.Pools, id)
+
+	return nil, nil
+}
+
+// deleteRole handles DELETE access/roles/{id}.
+func (s *Server) deleteRole(r *serverRequest) (interface{}, error) {
+	id := r.params["id"]
+	role, ok := s.state.Roles[id]
+
+	if !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("role '%s' does not exist", id))
+	}
+
+	if role.Special {
+		return nil, newServerError(http.StatusInternalServerError, "cannot delete role - special role")
+	}
+
+	delete(s.state.Roles, id)
+
+	s.removeACLEntries(func(e *ACLEntry) bool {
+		return e.RoleID == id
+	})
+
+	return nil, nil
+}
+
+// deleteUser handles DELETE access/users/{id}.
+func (s *Server) deleteUser(r *serverRequest) (interface{}, error) {
+	id := r.params["id"]
+
+	if _, ok := s.state.Users[id]; !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("user '%s' does not exist", id))
+	}
+
+	delete(s.state.Users, id)
+
+	s.removeACLEntries(func(e *ACLEntry) bool {
+		return e.Type == "user" && e.UserOrGroupID == id
+	})
+
+	return nil, nil
+}
+
+// getACL handles GET access/acl.
+func (s *Server) getACL(r *serverRequest) (interface{}, error) {
+	data := []*proxmox.VirtualEnvironmentACLGetResponseData{}
+
+	for _, e := range s.state.ACL {
+		propagate := proxmox.CustomBool(e.Propagate)
+
+		data = append(data, &proxmox.VirtualEnvironmentACLGetResponseData{
+			Path:          e.Path,
+			Propagate:     &propagate,
+			RoleID:        e.RoleID,
+			Type:          e.Type,
+			UserOrGroupID: e.UserOrGroupID,
+		})
+	}
+
+	return data, nil
+}
+
+// getGroup handles GET access/groups/{id}.
+func (s *Server) getGroup(r *serverRequest) (interface{}, error) {
+	id := r.params["id"]
+	group, ok := s.state.Groups[id]
+
+	if !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("group '%s' does not exist", id))
+	}
+
+	members := []string{}
+
+	for _, userID := range sortedKeys(s.state.Users) {
+		for _, g := range s.state.Users[userID].Groups {
+			if g == id {
+				members = append(members, userID)
+			}
+		}
+	}
+
+	return &proxmox.VirtualEnvironmentGroupGetResponseData{
+		Comment: optionalString(group.Comment),
+		Members: members,
+	}, nil
+}
+
+// getPool handles GET pools/{id}.
+func (s *Server) getPool(r *serverRequest) (interface{}, error) {
+	id := r.params["id"]
+	pool, ok := s.state.Pools[id]
+
+	if !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("pool '%s' does not exist", id))
+	}
+
+	members := []proxmox.VirtualEnvironmentPoolGetResponseMembers{}
+
+	for _, vmID := range sortedGuestIDs(s.state.Guests) {
+		guest := s.state.Guests[vmID]
+
+		if guest.PoolID == id {
+			memberVMID := vmID
+
+			members = append(members, proxmox.VirtualEnvironmentPoolGetResponseMembers{
+				ID:   fmt.Sprintf("%s/%d", guest.Type, vmID),
+				Node: guest.NodeName,
+				Type: guest.Type,
+				VMID: &memberVMID,
+			})
+		}
+	}
+
+	return &proxmox.VirtualEnvironmentPoolGetResponseData{
+		Comment: optionalString(pool.Comment),
+		Members: members,
+	}, nil
+}
+
+// getRole handles GET access/roles/{id}.
+func (s *Server) getRole(r *serverRequest) (interface{}, error) {
+	id := r.params["id"]
+	role, ok := s.state.Roles[id]
+
+	if !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("role '%s' does not exist", id))
+	}
+
+	privileges := map[string]int{}
+
+	for _, p := range role.Privileges {
+		privileges[p] = 1
+	}
+
+	return privileges, nil
+}
+
+// getUser handles GET access/users/{id}.
+func (s *Server) getUser(r *serverRequest) (interface{}, error) {
+	id := r.params["id"]
+	user, ok := s.state.Users[id]
+
+	if !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("user '%s' does not exist", id))
+	}
+
+	return user.toListResponseData(id), nil
+}
+
+// listGroups handles GET access/groups.
+func (s *Server) listGroups(r *serverRequest) (interface{}, error) {
+	data := []*proxmox.VirtualEnvironmentGroupListResponseData{}
+
+	for _, id := range sortedKeys(s.state.Groups) {
+		data = append(data, &proxmox.VirtualEnvironmentGroupListResponseData{
+			Comment: optionalString(s.state.Groups[id].Comment),
+			ID:      id,
+		})
+	}
+
+	return data, nil
+}
+
+// listPools handles GET pools.
+func (s *Server) listPools(r *serverRequest) (interface{}, error) {
+	data := []*proxmox.VirtualEnvironmentPoolListResponseData{}
+
+	for _, id := range sortedKeys(s.state.Pools) {
+		data = append(data, &proxmox.VirtualEnvironmentPoolListResponseData{
+			Comment: optionalString(s.state.Pools[id].Comment),
+			ID:      id,
+		})
+	}
+
+	return data, nil
+}
+
+// listRoles handles GET access/roles.
+func (s *Server) listRoles(r *serverRequest) (interface{}, error) {
+	data := []map[string]interface{}{}
+
+	for _, id := range sortedKeys(s.state.Roles) {
+		role := s.state.Roles[id]
+		special := 0
+
+		if role.Special {
+			special = 1
+		}
+
+		data = append(data, map[string]interface{}{
+			"privs":   strings.Join(role.Privileges, ","),
+			"roleid":  id,
+			"special": special,
+		})
+	}
+
+	return data, nil
+}
+
+// listUsers handles GET access/users.
+func (s *Server) listUsers(r *serverRequest) (interface{}, error) {
+	data := []*proxmox.VirtualEnvironmentUserListResponseData{}
+
+	for _, id := range sortedKeys(s.state.Users) {
+		data = append(data, s.state.Users[id].toListResponseData(id))
+	}
+
+	return data, nil
+}
+
+// removeACLEntries removes the access control list entries, which match the specified function.
+func (s *Server) removeACLEntries(fn func(e *ACLEntry) bool) {
+	acl := []*ACLEntry{}
+
+	for _, e := range s.state.ACL {
+		if !fn(e) {
+			acl = append(acl, e)
+		}
+	}
+
+	s.state.ACL = acl
+}
+
+// updateACL handles PUT access/acl.
+func (s *Server) updateACL(r *serverRequest) (interface{}, error) {
+	path, _ := r.value("path")
+
+	if path == "" {
+		return nil, newServerParameterError("path", "property is missing and it is not optional")
+	}
+
+	roles := r.list("roles")
+
+	for _, roleID := range roles {
+		if _, ok := s.state.Roles[roleID]; !ok {
+			return nil, newServerParameterError("roles", fmt.Sprintf("role '%s' does not exist", roleID))
+		}
+	}
+
+	subjects := map[string][]string{
+		"group": r.list("groups"),
+		"user":  r.list("users"),
+	}
+
+	err := s.validateGroups(subjects["group"])
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, userID := range subjects["user"] {
+		if _, ok := s.state.Users[userID]; !ok {
+			return nil, newServerParameterError("users", fmt.Sprintf("user '%s' does not exist", userID))
+		}
+	}
+
+	deleteEntries := r.bool("delete", false)
+	propagate := r.bool("propagate", true)
+
+	for _, subjectType := range sortedKeys(subjects) {
+		for _, subjectID := range subjects[subjectType] {
+			for _, roleID := range roles {
+				s.removeACLEntries(func(e *ACLEntry) bool {
+					return e.Path == path && e.RoleID == roleID && e.Type == subjectType && e.UserOrGroupID == subjectID
+				})
+
+				if !deleteEntries {
+					s.state.ACL = append(s.state.ACL, &ACLEntry{
+						Path:          path,
+						Propagate:     propagate,
+						RoleID:        roleID,
+						Type:          subjectType,
+						UserOrGroupID: subjectID,
+					})
+				}
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// updateGroup handles PUT access/groups/{id}.
+func (s *Server) updateGroup(r *serverRequest) (interface{}, error) {
+	id := r.params["id"]
+	group, ok := s.state.Groups[id]
+
+	if !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("group '%s' does not exist", id))
+	}
+
+	if comment, ok := r.value("comment"); ok {
+		group.Comment = comment
+	}
+
+	return nil, nil
+}
+
+// updatePassword handles PUT access/password.
+func (s *Server) updatePassword(r *serverRequest) (interface{}, error) {
+	id, _ := r.value("userid")
+	user, ok := s.state.Users[id]
+
+	if !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("user '%s' does not exist", id))
+	}
+
+	user.Password, _ = r.value("password")
+
+	return nil, nil
+}
+
+// updatePool handles PUT pools/{id}.
+func (s *Server) updatePool(r *serverRequest) (interface{}, error) {
+	id := r.params["id"]
+	pool, ok := s.state.Pools[id]
+
+	if !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("pool '%s' does not exist", id))
+	}
+
+	if comment, ok := r.value("comment"); ok {
+		pool.Comment = comment
+	}
+
+	return nil, nil
+}
+
+// updateRole handles PUT access/roles/{id}.
+func (s *Server) updateRole(r *serverRequest) (interface{}, error) {
+	id := r.params["id"]
+	role, ok := s.state.Roles[id]
+
+	if !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("role '%s' does not exist", id))
+	}
+
+	if r.bool("append", false) {
+		role.Privileges = append(role.Privileges, r.list("privs")...)
+	} else {
+		role.Privileges = r.list("privs")
+	}
+
+	return nil, nil
+}
+
+// updateUser handles PUT access/users/{id}.
+func (s *Server) updateUser(r *serverRequest) (interface{}, error) {
+	id := r.params["id"]
+	user, ok := s.state.Users[id]
+
+	if !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("user '%s' does not exist", id))
+	}
+
+	if _, ok := r.value("groups"); ok {
+		groups := r.list("groups")
+
+		err := s.validateGroups(groups)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if r.bool("append", false) {
+			for _, g := range groups {
+				user.Groups = append(removeString(user.Groups, g), g)
+			}
+		} else {
+			user.Groups = groups
+		}
+	}
+
+	user.Enabled = r.bool("enable", user.Enabled)
+
+	if v, ok := r.value("comment"); ok {
+		user.Comment = v
+	}
+
+	if v, ok := r.value("email"); ok {
+		user.Email = v
+	}
+
+	if v, ok := r.value("expire"); ok {
+		user.ExpirationDate, _ = strconv.ParseInt(v, 10, 64)
+	}
+
+	if v, ok := r.value("firstname"); ok {
+		user.FirstName = v
+	}
+
+	if v, ok := r.value("keys"); ok {
+		user.Keys = v
+	}
+
+	if v, ok := r.value("lastname"); ok {
+		user.LastName = v
+	}
+
+	return nil, nil
+}
+
+// validateGroups ensures that the specified groups exist.
+func (s *Server) validateGroups(groups []string) error {
+	for _, g := range groups {
+		if _, ok := s.state.Groups[g]; !ok {
+			return newServerParameterError("groups", fmt.Sprintf("group '%s' does not exist", g))
+		}
+	}
+
+	return nil
+}
+
+// toListResponseData converts a user to the data from a user list response.
+func (u *User) toListResponseData(id string) *proxmox.VirtualEnvironmentUserListResponseData {
+	enabled := proxmox.CustomBool(u.Enabled)
+	groups := append([]string{}, u.Groups...)
+
+	sort.Strings(groups)
+
+	data := &proxmox.VirtualEnvironmentUserListResponseData{
+		Comment:   optionalString(u.Comment),
+		Email:     optionalString(u.Email),
+		Enabled:   &enabled,
+		FirstName: optionalString(u.FirstName),
+		Groups:    &groups,
+		ID:        id,
+		Keys:      optionalString(u.Keys),
+		LastName:  optionalString(u.LastName),
+	}
+
+	if u.ExpirationDate > 0 {
+		expirationDate := proxmox.CustomTimestamp(time.Unix(u.ExpirationDate, 0))
+		data.ExpirationDate = &expirationDate
+	}
+
+	return data
+}
+
+// optionalString returns a pointer to a string, unless it is empty.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+// randomHex returns a random hexadecimal string with the specified number of bytes.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// removeString removes all occurrences of a string from a slice.
+func removeString(values []string, s string) []string {
+	result := []string{}
+
+	for _, v := range values {
+		if v != s {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,594 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmoxtest
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danitso/terraform-provider-proxmox/proxmox"
+)
+
+var (
+	guestCloneNameParameters = map[string]string{guestTypeContainer: "hostname", guestTypeVM: "name"}
+	guestConfigResponseTypes = map[string]interface{}{guestTypeContainer: proxmox.VirtualEnvironmentContainerGetResponseData{}, guestTypeVM: proxmox.VirtualEnvironmentVMGetResponseData{}}
+	guestControlParameters   = map[string][]string{
+		guestTypeContainer: {"delete", "digest", "force", "ignore-unpack-errors", "ostemplate", "password", "pool", "restore", "ssh-public-keys", "start", "storage", "unique", "vmid"},
+		guestTypeVM:        {"archive", "background_delay", "delete", "digest", "force", "pool", "revert", "skiplock", "start", "storage", "unique", "vmid"},
+	}
+	guestDiskAllocationRegexp  = regexp.MustCompile(`^([^:,]+):(\d+(?:\.\d+)?)((?:,.*)?)$`)
+	guestDiskKeyRegexp         = regexp.MustCompile(`^(efidisk|ide|mp|rootfs|sata|scsi|tpmstate|unused|virtio)\d*$`)
+	guestDiskVolumeIDRegexp    = regexp.MustCompile(`vm-(\d+)-disk-(\d+)`)
+	guestNetworkAddressRegexp  = regexp.MustCompile(`(?i)^([0-9a-f]{2}:){5}[0-9a-f]{2}$`)
+	guestNetworkDeviceRegexp   = regexp.MustCompile(`^net\d+$`)
+	guestNetworkModelRegexp    = regexp.MustCompile(`^(e1000|rtl8139|virtio|vmxnet3)$`)
+	guestNotRunningErrorFormat = "%s %d not running"
+	guestStatusResponseTypes   = map[string]interface{}{guestTypeContainer: proxmox.VirtualEnvironmentContainerGetStatusResponseData{}, guestTypeVM: proxmox.VirtualEnvironmentVMGetStatusResponseData{}}
+)
+
+// cloneGuest handles POST nodes/{node}/{type}/{vmid}/clone.
+func (s *Server) cloneGuest(r *serverRequest) (interface{}, error) {
+	vmID, guest, err := s.getGuest(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	newVMIDString, _ := r.value("newid")
+	newVMID, err := strconv.Atoi(newVMIDString)
+
+	if err != nil || newVMID < minimumVMID {
+		return nil, newServerParameterError("newid", fmt.Sprintf("invalid format - value must be at least %d", minimumVMID))
+	}
+
+	if _, ok := s.state.Guests[newVMID]; ok {
+		return nil, newServerParameterError("newid", fmt.Sprintf("unable to create %s %d: config file already exists", guest.description(), newVMID))
+	}
+
+	nodeName := guest.NodeName
+
+	if target, ok := r.value("target"); ok && target != "" {
+		if _, ok := s.state.Nodes[target]; !ok {
+			return nil, newServerParameterError("target", fmt.Sprintf("no such node '%s'", target))
+		}
+
+		nodeName = target
+	}
+
+	poolID, _ := r.value("pool")
+
+	if poolID != "" {
+		if _, ok := s.state.Pools[poolID]; !ok {
+			return nil, newServerParameterError("pool", fmt.Sprintf("pool '%s' does not exist", poolID))
+		}
+	}
+
+	clone := &Guest{
+		Config:   map[string]string{},
+		NodeName: nodeName,
+		PoolID:   poolID,
+		Status:   guestStatusStopped,
+		Type:     guest.Type,
+	}
+
+	for k, v := range guest.Config {
+		if guestDiskKeyRegexp.MatchString(k) {
+			v = guestDiskVolumeIDRegexp.ReplaceAllString(v, fmt.Sprintf("vm-%d-disk-$2", newVMID))
+		}
+
+		clone.Config[k] = v
+	}
+
+	delete(clone.Config, "template")
+
+	if name, ok := r.value(guestCloneNameParameters[guest.Type]); ok {
+		clone.Config[guestCloneNameParameters[guest.Type]] = name
+	}
+
+	if description, ok := r.value("description"); ok {
+		clone.Config["description"] = description
+	}
+
+	s.state.Guests[newVMID] = clone
+
+	return s.createTask(guest.NodeName, fmt.Sprintf("%sclone", guest.taskPrefix()), strconv.Itoa(vmID), r.username), nil
+}
+
+// createGuest handles POST nodes/{node}/{type}.
+func (s *Server) createGuest(r *serverRequest) (interface{}, error) {
+	_, err := s.getNode(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	guestType := r.params["type"]
+	vmIDString, _ := r.value("vmid")
+	vmID, err := strconv.Atoi(vmIDString)
+
+	if err != nil || vmID < minimumVMID {
+		return nil, newServerParameterError("vmid", fmt.Sprintf("invalid format - value must be at least %d", minimumVMID))
+	}
+
+	guest := &Guest{
+		Config:   map[string]string{},
+		NodeName: r.params["node"],
+		Status:   guestStatusStopped,
+		Type:     guestType,
+	}
+
+	if _, ok := s.state.Guests[vmID]; ok {
+		return nil, newServerParameterError("vmid", fmt.Sprintf("unable to create %s %d: config file already exists", guest.description(), vmID))
+	}
+
+	if guestType == guestTypeContainer {
+		if _, ok := r.value("ostemplate"); !ok {
+			return nil, newServerParameterError("ostemplate", "property is missing and it is not optional")
+		}
+	}
+
+	guest.PoolID, _ = r.value("pool")
+
+	if guest.PoolID != "" {
+		if _, ok := s.state.Pools[guest.PoolID]; !ok {
+			return nil, newServerParameterError("pool", fmt.Sprintf("pool '%s' does not exist", guest.PoolID))
+		}
+	}
+
+	guest.updateConfig(vmID, r)
+
+	// Containers receive a root filesystem on the specified storage, if none has been defined.
+	if _, ok := guest.Config["rootfs"]; !ok && guestType == guestTypeContainer {
+		storage, ok := r.value("storage")
+
+		if !ok {
+			storage = containerDefaultStorage
+		}
+
+		guest.Config["rootfs"] = guest.allocateDisk(vmID, fmt.Sprintf("%s:%d", storage, containerDefaultRootFSSize))
+	}
+
+	if r.bool("start", false) {
+		guest.Status = guestStatusRunning
+	}
+
+	s.state.Guests[vmID] = guest
+
+	return s.createTask(guest.NodeName, fmt.Sprintf("%screate", guest.taskPrefix()), strconv.Itoa(vmID), r.username), nil
+}
+
+// deleteGuest handles DELETE nodes/{node}/{type}/{vmid}.
+func (s *Server) deleteGuest(r *serverRequest) (interface{}, error) {
+	vmID, guest, err := s.getGuest(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if guest.Status == guestStatusRunning {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("%s %d is running - destroy failed", guest.description(), vmID))
+	}
+
+	delete(s.state.Guests, vmID)
+
+	return s.createTask(guest.NodeName, fmt.Sprintf("%sdestroy", guest.taskPrefix()), strconv.Itoa(vmID), r.username), nil
+}
+
+// getGuest retrieves the guest, which has been specified in the path of a request.
+// Guests are only found on the node, which they reside on, just like their configuration files.
+func (s *Server) getGuest(r *serverRequest) (int, *Guest, error) {
+	_, err := s.getNode(r)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	vmID, err := strconv.Atoi(r.params["vmid"])
+
+	if err != nil {
+		return 0, nil, newServerParameterError("vmid", "type check ('integer') failed")
+	}
+
+	guest, ok := s.state.Guests[vmID]
+
+	if !ok || guest.Type != r.params["type"] || guest.NodeName != r.params["node"] {
+		return 0, nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("Configuration file 'nodes/%s/%s/%d.conf' does not exist", r.params["node"], r.params["type"], vmID))
+	}
+
+	return vmID, guest, nil
+}
+
+// getGuestConfig handles GET nodes/{node}/{type}/{vmid}/config.
+func (s *Server) getGuestConfig(r *serverRequest) (interface{}, error) {
+	_, guest, err := s.getGuest(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]string{}
+
+	for k, v := range guest.Config {
+		config[k] = v
+	}
+
+	config["digest"] = guest.digest()
+
+	if guest.Lock != "" {
+		config["lock"] = guest.Lock
+	}
+
+	return encodeValues(config, guestConfigResponseTypes[guest.Type]), nil
+}
+
+// getGuestNetworkInterfaces handles GET nodes/{node}/qemu/{vmid}/agent/network-get-interfaces.
+func (s *Server) getGuestNetworkInterfaces(r *serverRequest) (interface{}, error) {
+	vmID, guest, err := s.getGuest(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if guest.Status != guestStatusRunning {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf(guestNotRunningErrorFormat, guest.description(), vmID))
+	}
+
+	return nil, newServerError(http.StatusInternalServerError, "QEMU guest agent is not running")
+}
+
+// getGuestStatus handles GET nodes/{node}/{type}/{vmid}/status/current.
+func (s *Server) getGuestStatus(r *serverRequest) (interface{}, error) {
+	vmID, guest, err := s.getGuest(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	status := map[string]string{
+		"status": guest.Status,
+		"vmid":   strconv.Itoa(vmID),
+	}
+
+	if guest.Type == guestTypeVM {
+		status["qmpstatus"] = guest.Status
+
+		if name, ok := guest.Config["name"]; ok {
+			status["name"] = name
+		}
+	} else if hostname, ok := guest.Config["hostname"]; ok {
+		status["name"] = hostname
+	}
+
+	if guest.Lock != "" {
+		status["lock"] = guest.Lock
+	}
+
+	if guest.Status == guestStatusRunning {
+		status["uptime"] = "60"
+	} else {
+		status["uptime"] = "0"
+	}
+
+	return encodeValues(status, guestStatusResponseTypes[guest.Type]), nil
+}
+
+// migrateGuest handles POST nodes/{node}/{type}/{vmid}/migrate.
+// Running guests must either be migrated online (qemu) or be restarted on the target node (lxc).
+func (s *Server) migrateGuest(r *serverRequest) (interface{}, error) {
+	vmID, guest, err := s.getGuest(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	target, _ := r.value("target")
+
+	if _, ok := s.state.Nodes[target]; !ok {
+		return nil, newServerParameterError("target", fmt.Sprintf("no such node '%s'", target))
+	}
+
+	if target == guest.NodeName {
+		return nil, newServerError(http.StatusInternalServerError, "target is local node.")
+	}
+
+	if guest.Lock != "" {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("%s %d is locked (%s)", guest.description(), vmID, guest.Lock))
+	}
+
+	if guest.Status == guestStatusRunning {
+		if guest.Type == guestTypeContainer && !r.bool("restart", false) {
+			return nil, newServerError(http.StatusInternalServerError, "lxc container is running - use online or restart")
+		}
+
+		if guest.Type == guestTypeVM && !r.bool("online", false) {
+			return nil, newServerError(http.StatusInternalServerError, "can't migrate running VM without --online")
+		}
+
+		if guest.Type == guestTypeVM && !r.bool("with-local-disks", false) {
+			for k, v := range guest.Config {
+				if !guestDiskKeyRegexp.MatchString(k) {
+					continue
+				}
+
+				datastore, ok := s.state.Datastores[strings.SplitN(strings.TrimPrefix(v, "file="), ":", 2)[0]]
+
+				if ok && !datastore.Shared {
+					return nil, newServerError(http.StatusInternalServerError, "can't live migrate attached local disks without with-local-disks option")
+				}
+			}
+		}
+	}
+
+	// Local disks are moved to the mapped datastores (source:target), while disks on other datastores keep their datastores.
+	if targetStorage, ok := r.value("targetstorage"); ok && guest.Type == guestTypeVM {
+		mappings := map[string]string{}
+
+		for _, mapping := range strings.Split(targetStorage, ",") {
+			mappingParts := strings.SplitN(mapping, ":", 2)
+
+			if len(mappingParts) != 2 {
+				return nil, newServerParameterError("targetstorage", fmt.Sprintf("invalid storage mapping '%s'", mapping))
+			}
+
+			if _, ok := s.state.Datastores[mappingParts[1]]; !ok {
+				return nil, newServerParameterError("targetstorage", fmt.Sprintf("storage '%s' does not exist", mappingParts[1]))
+			}
+
+			mappings[mappingParts[0]] = mappingParts[1]
+		}
+
+		for k, v := range guest.Config {
+			if !guestDiskKeyRegexp.MatchString(k) {
+				continue
+			}
+
+			valueParts := strings.SplitN(v, ":", 2)
+			datastore, ok := s.state.Datastores[valueParts[0]]
+
+			if len(valueParts) == 2 && ok && !datastore.Shared && mappings[valueParts[0]] != "" {
+				guest.Config[k] = fmt.Sprintf("%s:%s", mappings[valueParts[0]], valueParts[1])
+			}
+		}
+	}
+
+	sourceNodeName := guest.NodeName
+	guest.NodeName = target
+
+	return s.createTask(sourceNodeName, fmt.Sprintf("%smigrate", guest.taskPrefix()), strconv.Itoa(vmID), r.username), nil
+}
+
+// updateGuestConfig handles POST and PUT nodes/{node}/{type}/{vmid}/config.
+// The asynchronous variant (POST) returns a task identifier, while the synchronous variant (PUT) returns nothing.
+func (s *Server) updateGuestConfig(r *serverRequest) (interface{}, error) {
+	vmID, guest, err := s.getGuest(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if digest, ok := r.value("digest"); ok && digest != guest.digest() {
+		return nil, newServerError(http.StatusInternalServerError, "detected modified configuration - file changed by other user? Try again.")
+	}
+
+	if guest.Lock != "" && !r.bool("skiplock", false) {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("%s %d is locked (%s)", guest.description(), vmID, guest.Lock))
+	}
+
+	for _, k := range r.list("delete") {
+		delete(guest.Config, strings.TrimSpace(k))
+	}
+
+	guest.updateConfig(vmID, r)
+
+	if r.request.Method == http.MethodPost {
+		return s.createTask(guest.NodeName, fmt.Sprintf("%sconfig", guest.taskPrefix()), strconv.Itoa(vmID), r.username), nil
+	}
+
+	return nil, nil
+}
+
+// updateGuestStatus handles POST nodes/{node}/{type}/{vmid}/status/{action}.
+func (s *Server) updateGuestStatus(r *serverRequest) (interface{}, error) {
+	vmID, guest, err := s.getGuest(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	action := r.params["action"]
+
+	switch action {
+	case "reboot", "shutdown":
+		if guest.Status != guestStatusRunning {
+			return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf(guestNotRunningErrorFormat, guest.description(), vmID))
+		}
+	case "start":
+		if guest.Status == guestStatusRunning {
+			return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("%s %d already running", guest.description(), vmID))
+		}
+
+		if guest.Config["template"] == "1" {
+			return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("you can't start a %s if it's a template", guest.description()))
+		}
+	case "stop":
+	default:
+		return nil, newServerError(http.StatusNotImplemented, fmt.Sprintf("Method 'POST status/%s' not implemented", action))
+	}
+
+	if action == "reboot" || action == "start" {
+		guest.Status = guestStatusRunning
+	} else {
+		guest.Status = guestStatusStopped
+	}
+
+	return s.createTask(guest.NodeName, fmt.Sprintf("%s%s", guest.taskPrefix(), action), strconv.Itoa(vmID), r.username), nil
+}
+
+// allocateDisk replaces a disk allocation (storage:size) with the volume, which would be created for it.
+func (g *Guest) allocateDisk(vmID int, value string) string {
+	matches := guestDiskAllocationRegexp.FindStringSubmatch(strings.TrimPrefix(value, "file="))
+
+	if matches == nil {
+		return value
+	}
+
+	diskNumber := 0
+
+	for _, v := range g.Config {
+		for _, m := range guestDiskVolumeIDRegexp.FindAllStringSubmatch(v, -1) {
+			if m[1] == strconv.Itoa(vmID) {
+				n, _ := strconv.Atoi(m[2])
+
+				if n >= diskNumber {
+					diskNumber = n + 1
+				}
+			}
+		}
+	}
+
+	volumeID := fmt.Sprintf("%s:vm-%d-disk-%d%s", matches[1], vmID, diskNumber, matches[3])
+
+	if !strings.Contains(matches[3], ",size=") {
+		volumeID = fmt.Sprintf("%s,size=%s%s", volumeID, matches[2], guestDiskSizeSuffix)
+	}
+
+	return volumeID
+}
+
+// assignNetworkAddress normalizes a network device and assigns it a MAC address, if it does not already have one.
+// Virtual machine devices are stored in the "<model>=<address>" format, just like the API does.
+func (g *Guest) assignNetworkAddress(vmID int, key, value string) string {
+	deviceNumber, _ := strconv.Atoi(strings.TrimPrefix(key, "net"))
+	options := strings.Split(value, ",")
+
+	if g.Type == guestTypeContainer {
+		for _, o := range options {
+			if strings.HasPrefix(o, "hwaddr=") {
+				return value
+			}
+		}
+
+		return fmt.Sprintf("%s,hwaddr=%s", value, newNetworkAddress(vmID, deviceNumber))
+	}
+
+	address := ""
+	model := ""
+	otherOptions := []string{}
+
+	for _, o := range options {
+		keyValue := strings.SplitN(o, "=", 2)
+
+		if len(keyValue) != 2 {
+			otherOptions = append(otherOptions, o)
+
+			continue
+		}
+
+		switch {
+		case keyValue[0] == "macaddr":
+			address = keyValue[1]
+		case keyValue[0] == "model":
+			model = keyValue[1]
+		case guestNetworkModelRegexp.MatchString(keyValue[0]):
+			model = keyValue[0]
+			address = keyValue[1]
+		default:
+			otherOptions = append(otherOptions, o)
+		}
+	}
+
+	if model == "" {
+		return value
+	}
+
+	if !guestNetworkAddressRegexp.MatchString(address) {
+		address = newNetworkAddress(vmID, deviceNumber)
+	}
+
+	return strings.Join(append([]string{fmt.Sprintf("%s=%s", model, address)}, otherOptions...), ",")
+}
+
+// description returns the term, which the API uses for the type of a guest in messages.
+func (g *Guest) description() string {
+	if g.Type == guestTypeContainer {
+		return "CT"
+	}
+
+	return "VM"
+}
+
+// digest returns the SHA1 digest of the configuration of a guest.
+func (g *Guest) digest() string {
+	lines := []string{}
+
+	for _, k := range sortedKeys(g.Config) {
+		lines = append(lines, fmt.Sprintf("%s: %s", k, g.Config[k]))
+	}
+
+	return getDigest(strings.Join(lines, "\n"))
+}
+
+// taskPrefix returns the prefix of the task types for a guest.
+func (g *Guest) taskPrefix() string {
+	if g.Type == guestTypeContainer {
+		return "vz"
+	}
+
+	return "qm"
+}
+
+// updateConfig applies the configuration values of a request to a guest.
+// Disks are allocated and network devices without a MAC address are assigned one, just like the API does.
+func (g *Guest) updateConfig(vmID int, r *serverRequest) {
+	controlParameters := map[string]bool{}
+
+	for _, k := range guestControlParameters[g.Type] {
+		controlParameters[k] = true
+	}
+
+	keys := []string{}
+
+	for k := range r.request.Form {
+		if !controlParameters[k] {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v, _ := r.value(k)
+
+		if guestDiskKeyRegexp.MatchString(k) {
+			v = g.allocateDisk(vmID, v)
+		} else if guestNetworkDeviceRegexp.MatchString(k) {
+			v = g.assignNetworkAddress(vmID, k, v)
+		}
+
+		g.Config[k] = v
+	}
+}
+
+// newNetworkAddress returns a deterministic MAC address for a network device.
+func newNetworkAddress(vmID, deviceNumber int) string {
+	return fmt.Sprintf("BC:24:11:%02X:%02X:%02X", (vmID>>8)&0xFF, vmID&0xFF, deviceNumber&0xFF)
+}
+
+// sortedGuestIDs returns the VM identifiers of the guests in ascending order.
+func sortedGuestIDs(guests map[int]*Guest) []int {
+	ids := []int{}
+
+	for id := range guests {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	return ids
+}
@@ -0,0 +1,753 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmoxtest
+
+import (
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danitso/terraform-provider-proxmox/proxmox"
+)
+
+// createTask registers a task, which has completed successfully, and returns its identifier (UPID).
+func (s *Server) createTask(nodeName, taskType, id, username string, log ...string) string {
+	startTime := time.Now()
+	upid := fmt.Sprintf(
+		"UPID:%s:%08X:%08X:%08X:%s:%s:%s:",
+		nodeName,
+		len(s.state.Tasks)+1,
+		len(s.state.Tasks)+1,
+		startTime.Unix(),
+		taskType,
+		id,
+		username,
+	)
+
+	s.state.Tasks[upid] = &Task{
+		ExitStatus: taskExitStatusOK,
+		ID:         id,
+		Log:        append(log, fmt.Sprintf("TASK %s", taskExitStatusOK)),
+		NodeName:   nodeName,
+		StartTime:  startTime,
+		Type:       taskType,
+		User:       username,
+	}
+
+	return upid
+}
+
+// deleteCertificate handles DELETE nodes/{node}/certificates/custom.
+func (s *Server) deleteCertificate(r *serverRequest) (interface{}, error) {
+	node, err := s.getNode(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	node.CustomCertificate = ""
+	node.CustomPrivateKey = ""
+
+	return nil, nil
+}
+
+// deleteDatastoreFile handles DELETE nodes/{node}/storage/{storage}/content/{volume}.
+func (s *Server) deleteDatastoreFile(r *serverRequest) (interface{}, error) {
+	datastore, err := s.getNodeDatastore(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	volumeID := r.params["volume"]
+
+	if _, ok := datastore.Files[volumeID]; !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("volume '%s' does not exist", volumeID))
+	}
+
+	delete(datastore.Files, volumeID)
+
+	return nil, nil
+}
+
+// downloadDatastoreFile handles POST nodes/{node}/storage/{storage}/download-url.
+func (s *Server) downloadDatastoreFile(r *serverRequest) (interface{}, error) {
+	datastore, err := s.getNodeDatastore(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, _ := r.value("content")
+	fileName, _ := r.value("filename")
+	fileURL, _ := r.value("url")
+
+	err = datastore.validateContentType(contentType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: time.Minute,
+	}
+
+	res, err := client.Get(fileURL)
+
+	if err != nil {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("download failed: %s", err.Error()))
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("download failed: %s", res.Status))
+	}
+
+	fileSize, err := io.Copy(ioutil.Discard, res.Body)
+
+	if err != nil {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("download failed: %s", err.Error()))
+	}
+
+	volumeID := datastore.addFile(r.params["storage"], contentType, fileName, int(fileSize))
+
+	return s.createTask(r.params["node"], "download", volumeID, r.username, fmt.Sprintf("downloading %s to %s", fileURL, volumeID)), nil
+}
+
+// getCertificates handles GET nodes/{node}/certificates/info.
+func (s *Server) getCertificates(r *serverRequest) (interface{}, error) {
+	node, err := s.getNode(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data := []proxmox.VirtualEnvironmentCertificateListResponseData{}
+
+	if node.CustomCertificate != "" {
+		data = append(data, newCertificateListResponseData("pveproxy-ssl.pem", node.CustomCertificate))
+	}
+
+	return data, nil
+}
+
+// getClusterNextID handles GET cluster/nextid.
+func (s *Server) getClusterNextID(r *serverRequest) (interface{}, error) {
+	if v, ok := r.value("vmid"); ok {
+		vmID, err := strconv.Atoi(v)
+
+		if err != nil || vmID < minimumVMID {
+			return nil, newServerParameterError("vmid", fmt.Sprintf("invalid format - value must be at least %d", minimumVMID))
+		}
+
+		if _, ok := s.state.Guests[vmID]; ok {
+			return nil, newServerParameterError("vmid", fmt.Sprintf("VM %d already exists", vmID))
+		}
+
+		return strconv.Itoa(vmID), nil
+	}
+
+	vmID := minimumVMID
+
+	for {
+		if _, ok := s.state.Guests[vmID]; !ok {
+			return strconv.Itoa(vmID), nil
+		}
+
+		vmID++
+	}
+}
+
+// getClusterStatus handles GET cluster/status.
+func (s *Server) getClusterStatus(r *serverRequest) (interface{}, error) {
+	online := proxmox.CustomBool(true)
+	data := []*proxmox.VirtualEnvironmentClusterStatusResponseData{}
+
+	for i, nodeName := range sortedKeys(s.state.Nodes) {
+		address := s.state.Nodes[nodeName].Address
+		nodeID := i + 1
+
+		data = append(data, &proxmox.VirtualEnvironmentClusterStatusResponseData{
+			ID:     fmt.Sprintf("node/%s", nodeName),
+			IP:     &address,
+			Name:   nodeName,
+			NodeID: &nodeID,
+			Online: &online,
+			Type:   "node",
+		})
+	}
+
+	return data, nil
+}
+
+// getDatastore handles GET storage/{storage}.
+func (s *Server) getDatastore(r *serverRequest) (interface{}, error) {
+	datastoreID := r.params["storage"]
+	datastore, ok := s.state.Datastores[datastoreID]
+
+	if !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("storage '%s' does not exist", datastoreID))
+	}
+
+	contentTypes := proxmox.CustomCommaSeparatedList(datastore.ContentTypes)
+	disabled := proxmox.CustomBool(datastore.Disabled)
+	shared := proxmox.CustomBool(datastore.Shared)
+
+	data := &proxmox.VirtualEnvironmentDatastoreGetResponseData{
+		ContentTypes: &contentTypes,
+		Disabled:     &disabled,
+		ID:           datastoreID,
+		Path:         optionalString(datastore.Path),
+		Shared:       &shared,
+		Type:         datastore.Type,
+	}
+
+	if len(datastore.Nodes) > 0 {
+		nodes := proxmox.CustomCommaSeparatedList(datastore.Nodes)
+		data.Nodes = &nodes
+	}
+
+	return data, nil
+}
+
+// getDNS handles GET nodes/{node}/dns.
+func (s *Server) getDNS(r *serverRequest) (interface{}, error) {
+	node, err := s.getNode(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data := &proxmox.VirtualEnvironmentDNSGetResponseData{
+		SearchDomain: optionalString(node.DNSSearchDomain),
+	}
+
+	servers := []**string{&data.Server1, &data.Server2, &data.Server3}
+
+	for i, v := range node.DNSServers {
+		if i < len(servers) {
+			*servers[i] = optionalString(v)
+		}
+	}
+
+	return data, nil
+}
+
+// getHosts handles GET nodes/{node}/hosts.
+func (s *Server) getHosts(r *serverRequest) (interface{}, error) {
+	node, err := s.getNode(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	digest := getDigest(node.Hosts)
+
+	return &proxmox.VirtualEnvironmentHostsGetResponseData{
+		Data:   node.Hosts,
+		Digest: &digest,
+	}, nil
+}
+
+// getNode retrieves the node, which has been specified in the path of a request.
+func (s *Server) getNode(r *serverRequest) (*Node, error) {
+	nodeName := r.params["node"]
+	node, ok := s.state.Nodes[nodeName]
+
+	if !ok {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("no such node '%s'", nodeName))
+	}
+
+	return node, nil
+}
+
+// getNodeDatastore retrieves the datastore, which has been specified in the path of a request, if it is available on the node.
+func (s *Server) getNodeDatastore(r *serverRequest) (*Datastore, error) {
+	_, err := s.getNode(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	datastoreID := r.params["storage"]
+	datastore, ok := s.state.Datastores[datastoreID]
+
+	if !ok || !datastore.isAvailableOnNode(r.params["node"]) {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("storage '%s' does not exist", datastoreID))
+	}
+
+	return datastore, nil
+}
+
+// getTask retrieves the task, which has been specified in the path of a request.
+func (s *Server) getTask(r *serverRequest) (*Task, error) {
+	upid := r.params["upid"]
+	task, ok := s.state.Tasks[upid]
+
+	if !ok || task.NodeName != r.params["node"] {
+		return nil, newServerError(http.StatusInternalServerError, fmt.Sprintf("no such task '%s'", upid))
+	}
+
+	return task, nil
+}
+
+// getTaskStatus handles GET nodes/{node}/tasks/{upid}/status.
+func (s *Server) getTaskStatus(r *serverRequest) (interface{}, error) {
+	task, err := s.getTask(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := int(task.StartTime.Unix())
+
+	return &proxmox.VirtualEnvironmentTaskGetStatusResponseData{
+		ExitStatus: &task.ExitStatus,
+		ID:         &task.ID,
+		NodeName:   task.NodeName,
+		StartTime:  &startTime,
+		Status:     taskStatusStopped,
+		Type:       task.Type,
+		UPID:       r.params["upid"],
+		User:       task.User,
+	}, nil
+}
+
+// getVersion handles GET version.
+func (s *Server) getVersion(r *serverRequest) (interface{}, error) {
+	release := strings.SplitN(s.state.Version, "-", 2)[0]
+
+	return &proxmox.VirtualEnvironmentVersionResponseData{
+		Keyboard:     "en-us",
+		Release:      release,
+		RepositoryID: "c11b3eb2",
+		Version:      s.state.Version,
+	}, nil
+}
+
+// listDatastoreFiles handles GET nodes/{node}/storage/{storage}/content.
+func (s *Server) listDatastoreFiles(r *serverRequest) (interface{}, error) {
+	datastore, err := s.getNodeDatastore(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, _ := r.value("content")
+	data := []*proxmox.VirtualEnvironmentDatastoreFileListResponseData{}
+
+	for _, volumeID := range sortedKeys(datastore.Files) {
+		file := datastore.Files[volumeID]
+
+		if contentType != "" && file.ContentType != contentType {
+			continue
+		}
+
+		data = append(data, &proxmox.VirtualEnvironmentDatastoreFileListResponseData{
+			ContentType: file.ContentType,
+			FileFormat:  file.FileFormat,
+			FileSize:    file.FileSize,
+			VolumeID:    volumeID,
+		})
+	}
+
+	return data, nil
+}
+
+// listDatastores handles GET nodes/{node}/storage.
+func (s *Server) listDatastores(r *serverRequest) (interface{}, error) {
+	_, err := s.getNode(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	contentTypes := r.list("content")
+	datastoreID, _ := r.value("storage")
+	enabledOnly := r.bool("enabled", false)
+	data := []*proxmox.VirtualEnvironmentDatastoreListResponseData{}
+
+	for _, id := range sortedKeys(s.state.Datastores) {
+		datastore := s.state.Datastores[id]
+
+		if !datastore.isAvailableOnNode(r.params["node"]) || (datastoreID != "" && id != datastoreID) || (enabledOnly && datastore.Disabled) {
+			continue
+		}
+
+		matchesContentTypes := true
+
+		for _, c := range contentTypes {
+			if !datastore.hasContentType(c) {
+				matchesContentTypes = false
+			}
+		}
+
+		if !matchesContentTypes {
+			continue
+		}
+
+		active := proxmox.CustomBool(!datastore.Disabled)
+		datastoreContentTypes := proxmox.CustomCommaSeparatedList(datastore.ContentTypes)
+		shared := proxmox.CustomBool(datastore.Shared)
+		spaceTotal := 100 * 1024 * 1024 * 1024
+		spaceUsed := 0
+
+		for _, file := range datastore.Files {
+			spaceUsed += file.FileSize
+		}
+
+		spaceAvailable := spaceTotal - spaceUsed
+
+		data = append(data, &proxmox.VirtualEnvironmentDatastoreListResponseData{
+			Active:         &active,
+			ContentTypes:   &datastoreContentTypes,
+			Enabled:        &active,
+			ID:             id,
+			Shared:         &shared,
+			SpaceAvailable: &spaceAvailable,
+			SpaceTotal:     &spaceTotal,
+			SpaceUsed:      &spaceUsed,
+			Type:           datastore.Type,
+		})
+	}
+
+	return data, nil
+}
+
+// listNetworkDevices handles GET nodes/{node}/network.
+func (s *Server) listNetworkDevices(r *serverRequest) (interface{}, error) {
+	node, err := s.getNode(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	active := proxmox.CustomBool(true)
+	bridge := &proxmox.VirtualEnvironmentNodeNetworkDeviceListResponseData{
+		Active:    &active,
+		Autostart: &active,
+		Iface:     "vmbr0",
+		Priority:  2,
+		Type:      "bridge",
+	}
+
+	if ip := net.ParseIP(node.Address).To4(); ip != nil {
+		cidr := fmt.Sprintf("%s/24", node.Address)
+		gateway := net.IPv4(ip[0], ip[1], ip[2], 1).String()
+
+		bridge.Address = &node.Address
+		bridge.CIDR = &cidr
+		bridge.Gateway = &gateway
+	}
+
+	return []*proxmox.VirtualEnvironmentNodeNetworkDeviceListResponseData{
+		{
+			Active:   &active,
+			Exists:   &active,
+			Iface:    "eth0",
+			Priority: 1,
+			Type:     "eth",
+		},
+		bridge,
+	}, nil
+}
+
+// listNodes handles GET nodes.
+func (s *Server) listNodes(r *serverRequest) (interface{}, error) {
+	data := []*proxmox.VirtualEnvironmentNodeListResponseData{}
+
+	for _, nodeName := range sortedKeys(s.state.Nodes) {
+		cpuCount := 4
+		memoryAvailable := 8 * 1024 * 1024 * 1024
+		status := "online"
+		uptime := 3600
+
+		data = append(data, &proxmox.VirtualEnvironmentNodeListResponseData{
+			CPUCount:        &cpuCount,
+			MemoryAvailable: &memoryAvailable,
+			Name:            nodeName,
+			Status:          &status,
+			Uptime:          &uptime,
+		})
+	}
+
+	return data, nil
+}
+
+// listTaskLog handles GET nodes/{node}/tasks/{upid}/log.
+func (s *Server) listTaskLog(r *serverRequest) (interface{}, error) {
+	task, err := s.getTask(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	limit := len(task.Log)
+	start := 0
+
+	if v, ok := r.value("limit"); ok {
+		limit, _ = strconv.Atoi(v)
+	}
+
+	if v, ok := r.value("start"); ok {
+		start, _ = strconv.Atoi(v)
+	}
+
+	data := []*proxmox.VirtualEnvironmentTaskLogListResponseData{}
+
+	for i := start; i < len(task.Log) && i < start+limit; i++ {
+		data = append(data, &proxmox.VirtualEnvironmentTaskLogListResponseData{
+			LineNumber: i + 1,
+			Text:       task.Log[i],
+		})
+	}
+
+	return serverResponseBody{
+		"data":  data,
+		"total": len(task.Log),
+	}, nil
+}
+
+// updateCertificate handles POST nodes/{node}/certificates/custom.
+func (s *Server) updateCertificate(r *serverRequest) (interface{}, error) {
+	node, err := s.getNode(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	certificates, _ := r.value("certificates")
+	block, _ := pem.Decode([]byte(certificates))
+
+	if block == nil {
+		return nil, newServerParameterError("certificates", "failed to parse the certificates")
+	}
+
+	if node.CustomCertificate != "" && !r.bool("force", false) {
+		return nil, newServerError(http.StatusInternalServerError, "Custom certificate exists but 'force' is not set.")
+	}
+
+	node.CustomCertificate = certificates
+	node.CustomPrivateKey, _ = r.value("key")
+
+	return newCertificateListResponseData("pveproxy-ssl.pem", certificates), nil
+}
+
+// updateDNS handles PUT nodes/{node}/dns.
+func (s *Server) updateDNS(r *serverRequest) (interface{}, error) {
+	node, err := s.getNode(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	node.DNSSearchDomain, _ = r.value("search")
+	node.DNSServers = []string{}
+
+	for _, key := range []string{"dns1", "dns2", "dns3"} {
+		if v, ok := r.value(key); ok && v != "" {
+			node.DNSServers = append(node.DNSServers, v)
+		}
+	}
+
+	return nil, nil
+}
+
+// updateHosts handles POST nodes/{node}/hosts.
+func (s *Server) updateHosts(r *serverRequest) (interface{}, error) {
+	node, err := s.getNode(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if digest, ok := r.value("digest"); ok && digest != getDigest(node.Hosts) {
+		return nil, newServerError(http.StatusInternalServerError, "detected modified configuration - file changed by other user? Try again.")
+	}
+
+	node.Hosts, _ = r.value("data")
+
+	return nil, nil
+}
+
+// uploadDatastoreFile handles POST nodes/{node}/storage/{storage}/upload.
+func (s *Server) uploadDatastoreFile(r *serverRequest) (interface{}, error) {
+	datastore, err := s.getNodeDatastore(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := r.request.MultipartReader()
+
+	if err != nil {
+		return nil, newServerError(http.StatusBadRequest, err.Error())
+	}
+
+	values := map[string]string{}
+	fileName := ""
+	fileSize := int64(0)
+
+	for {
+		part, err := reader.NextPart()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, newServerError(http.StatusBadRequest, err.Error())
+		}
+
+		if part.FileName() != "" {
+			fileName = part.FileName()
+			fileSize, err = io.Copy(ioutil.Discard, part)
+		} else {
+			var value []byte
+
+			value, err = ioutil.ReadAll(part)
+			values[part.FormName()] = string(value)
+		}
+
+		if err != nil {
+			return nil, newServerError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	if fileName == "" {
+		return nil, newServerParameterError("filename", "property is missing and it is not optional")
+	}
+
+	err = datastore.validateContentType(values["content"])
+
+	if err != nil {
+		return nil, err
+	}
+
+	volumeID := datastore.addFile(r.params["storage"], values["content"], filepath.Base(fileName), int(fileSize))
+
+	return s.createTask(r.params["node"], "imgcopy", volumeID, r.username), nil
+}
+
+// addFile adds a file to a datastore and returns its volume identifier.
+func (d *Datastore) addFile(datastoreID, contentType, fileName string, fileSize int) string {
+	fileFormat := strings.TrimPrefix(filepath.Ext(fileName), ".")
+
+	if contentType == "vztmpl" && strings.HasSuffix(fileName, ".tar.gz") {
+		fileFormat = "tgz"
+	}
+
+	volumeID := fmt.Sprintf("%s:%s/%s", datastoreID, contentType, fileName)
+
+	d.Files[volumeID] = &DatastoreFile{
+		ContentType: contentType,
+		FileFormat:  fileFormat,
+		FileSize:    fileSize,
+	}
+
+	return volumeID
+}
+
+// hasContentType determines whether a datastore supports the specified content type.
+func (d *Datastore) hasContentType(contentType string) bool {
+	for _, c := range d.ContentTypes {
+		if c == contentType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isAvailableOnNode determines whether a datastore is available on the specified node.
+func (d *Datastore) isAvailableOnNode(nodeName string) bool {
+	if len(d.Nodes) == 0 {
+		return true
+	}
+
+	for _, n := range d.Nodes {
+		if n == nodeName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateContentType ensures that a datastore supports the specified content type.
+func (d *Datastore) validateContentType(contentType string) error {
+	if !d.hasContentType(contentType) {
+		return newServerParameterError("content", fmt.Sprintf("storage does not support content type '%s'", contentType))
+	}
+
+	return nil
+}
+
+// getDigest returns the SHA1 digest of a configuration file.
+func getDigest(data string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(data)))
+}
+
+// newCertificateListResponseData converts a PEM encoded certificate chain to the data from a certificate list response.
+func newCertificateListResponseData(fileName, certificates string) proxmox.VirtualEnvironmentCertificateListResponseData {
+	data := proxmox.VirtualEnvironmentCertificateListResponseData{
+		Certificates: &certificates,
+		FileName:     &fileName,
+	}
+
+	block, _ := pem.Decode([]byte(certificates))
+
+	if block == nil {
+		return data
+	}
+
+	certificate, err := x509.ParseCertificate(block.Bytes)
+
+	if err != nil {
+		return data
+	}
+
+	fingerprint := []string{}
+
+	for _, b := range sha256.Sum256(certificate.Raw) {
+		fingerprint = append(fingerprint, fmt.Sprintf("%02X", b))
+	}
+
+	fingerprintString := strings.Join(fingerprint, ":")
+	issuer := certificate.Issuer.String()
+	notAfter := proxmox.CustomTimestamp(certificate.NotAfter)
+	notBefore := proxmox.CustomTimestamp(certificate.NotBefore)
+	subject := certificate.Subject.String()
+	subjectAlternativeNames := certificate.DNSNames
+
+	data.Fingerprint = &fingerprintString
+	data.Issuer = &issuer
+	data.NotAfter = &notAfter
+	data.NotBefore = &notBefore
+	data.Subject = &subject
+	data.SubjectAlternativeNames = &subjectAlternativeNames
+
+	if publicKey, ok := certificate.PublicKey.(*rsa.PublicKey); ok {
+		publicKeyBits := publicKey.N.BitLen()
+		publicKeyType := "rsaEncryption"
+
+		data.PublicKeyBits = &publicKeyBits
+		data.PublicKeyType = &publicKeyType
+	}
+
+	return data
+}
@@ -0,0 +1,292 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmoxtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danitso/terraform-provider-proxmox/proxmox"
+)
+
+// testNewClient creates a client, which authenticates against the specified server.
+func testNewClient(t *testing.T, s *Server) *proxmox.VirtualEnvironmentClient {
+	c, err := s.NewClient()
+
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+
+	return c
+}
+
+// TestServerAccessControl tests whether groups, users and access control lists can be managed.
+func TestServerAccessControl(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	c := testNewClient(t, s)
+	comment := "Test group"
+
+	err := c.CreateGroup(&proxmox.VirtualEnvironmentGroupCreateRequestBody{
+		Comment: &comment,
+		ID:      "test",
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create group: %s", err.Error())
+	}
+
+	err = c.CreateGroup(&proxmox.VirtualEnvironmentGroupCreateRequestBody{ID: "test"})
+
+	if !proxmox.IsAlreadyExists(err) {
+		t.Fatalf("Expected an \"already exists\" error - got: %v", err)
+	}
+
+	err = c.CreateUser(&proxmox.VirtualEnvironmentUserCreateRequestBody{
+		Groups:   []string{"test"},
+		ID:       "test@pve",
+		Password: "secret",
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create user: %s", err.Error())
+	}
+
+	propagate := proxmox.CustomBool(false)
+
+	err = c.UpdateACL(&proxmox.VirtualEnvironmentACLUpdateRequestBody{
+		Groups:    []string{"test"},
+		Path:      "/vms",
+		Propagate: &propagate,
+		Roles:     []string{"PVEAuditor"},
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to update access control list: %s", err.Error())
+	}
+
+	group, err := c.GetGroup("test")
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve group: %s", err.Error())
+	}
+
+	if group.Comment == nil || *group.Comment != comment || len(group.Members) != 1 || group.Members[0] != "test@pve" {
+		t.Fatalf("Expected the group to have the comment \"%s\" and the member \"test@pve\" - got: %v and %v", comment, group.Comment, group.Members)
+	}
+
+	user, err := c.GetUser("test@pve")
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve user: %s", err.Error())
+	}
+
+	if user.Enabled == nil || !bool(*user.Enabled) {
+		t.Fatalf("Expected the user to be enabled by default")
+	}
+
+	acl, err := c.GetACL()
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve access control list: %s", err.Error())
+	}
+
+	if len(acl) != 1 || acl[0].Path != "/vms" || acl[0].Propagate == nil || bool(*acl[0].Propagate) {
+		t.Fatalf("Expected a single non-propagating entry for the path \"/vms\" - got: %d entries", len(acl))
+	}
+
+	err = c.DeleteGroup("test")
+
+	if err != nil {
+		t.Fatalf("Failed to delete group: %s", err.Error())
+	}
+
+	_, err = c.GetGroup("test")
+
+	if !proxmox.IsNotFound(err) {
+		t.Fatalf("Expected a \"not found\" error - got: %v", err)
+	}
+
+	acl, err = c.GetACL()
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve access control list: %s", err.Error())
+	}
+
+	if len(acl) != 0 {
+		t.Fatalf("Expected the access control list entries of the group to be removed - got: %d entries", len(acl))
+	}
+}
+
+// TestServerAuthentication tests whether tickets and API tokens are validated.
+func TestServerAuthentication(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	c := testNewClient(t, s)
+
+	_, err := c.Version()
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve version: %s", err.Error())
+	}
+
+	// The client must request a new ticket, once the current one has been rejected.
+	s.ExpireTickets()
+
+	_, err = c.ListNodes()
+
+	if err != nil {
+		t.Fatalf("Failed to list nodes after the tickets expired: %s", err.Error())
+	}
+
+	c, err = proxmox.NewVirtualEnvironmentClient(s.URL, proxmox.DefaultRootAccount, "wrong", "", true)
+
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+
+	_, err = c.Version()
+
+	if err == nil {
+		t.Fatalf("Expected the authentication to fail with an invalid password")
+	}
+
+	s.Do(func(state *State) {
+		state.APITokens["root@pam!test"] = "secret"
+	})
+
+	c, err = proxmox.NewVirtualEnvironmentClient(s.URL, "", "", "root@pam!test=secret", true)
+
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+
+	_, err = c.Version()
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve version with an API token: %s", err.Error())
+	}
+}
+
+// TestServerVM tests whether virtual machines can be created, configured, started, cloned and deleted.
+func TestServerVM(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	c := testNewClient(t, s)
+	bridge := "vmbr0"
+	memory := 1024
+	name := "test"
+	vmID := 100
+
+	upid, err := c.CreateVM(DefaultNodeName, &proxmox.VirtualEnvironmentVMCreateRequestBody{
+		DedicatedMemory: &memory,
+		Name:            &name,
+		NetworkDevices: proxmox.CustomNetworkDevices{
+			{Bridge: &bridge, Enabled: true, Model: "virtio"},
+		},
+		SCSIDevices: proxmox.CustomStorageDevices{
+			{Enabled: true, FileVolume: "local-lvm:8"},
+		},
+		VMID: &vmID,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create VM: %s", err.Error())
+	}
+
+	err = c.WaitForTask(DefaultNodeName, *upid, 10, 1)
+
+	if err != nil {
+		t.Fatalf("Failed to wait for task: %s", err.Error())
+	}
+
+	_, err = c.GetClusterNextID(&vmID)
+
+	if !proxmox.IsAlreadyExists(err) {
+		t.Fatalf("Expected an \"already exists\" error for VM ID %d - got: %v", vmID, err)
+	}
+
+	vm, err := c.GetVM(DefaultNodeName, vmID)
+
+	if err != nil {
+		t.Fatalf("Failed to retrieve VM: %s", err.Error())
+	}
+
+	if vm.DedicatedMemory == nil || *vm.DedicatedMemory != memory {
+		t.Fatalf("Expected the dedicated memory to be %d - got: %v", memory, vm.DedicatedMemory)
+	}
+
+	if vm.SCSIDevice0 == nil || vm.SCSIDevice0.FileVolume != "local-lvm:vm-100-disk-0" || vm.SCSIDevice0.Size == nil || *vm.SCSIDevice0.Size != "8G" {
+		t.Fatalf("Expected the disk to be allocated as \"local-lvm:vm-100-disk-0\" with a size of 8G - got: %v", vm.SCSIDevice0)
+	}
+
+	if vm.NetworkDevice0 == nil || vm.NetworkDevice0.MACAddress == nil || vm.NetworkDevice0.Model != "virtio" {
+		t.Fatalf("Expected the network device to be assigned a MAC address - got: %v", vm.NetworkDevice0)
+	}
+
+	upid, err = c.StartVM(DefaultNodeName, vmID)
+
+	if err != nil {
+		t.Fatalf("Failed to start VM: %s", err.Error())
+	}
+
+	err = c.WaitForVMState(DefaultNodeName, vmID, "running", 10, 1)
+
+	if err != nil {
+		t.Fatalf("Failed to wait for the VM to start: %s", err.Error())
+	}
+
+	_, err = c.DeleteVM(DefaultNodeName, vmID)
+
+	if err == nil || !strings.Contains(err.Error(), "is running") {
+		t.Fatalf("Expected the deletion of a running VM to fail - got: %v", err)
+	}
+
+	_, err = c.ShutdownVM(DefaultNodeName, vmID, &proxmox.VirtualEnvironmentVMShutdownRequestBody{})
+
+	if err != nil {
+		t.Fatalf("Failed to shut down VM: %s", err.Error())
+	}
+
+	cloneName := "clone"
+
+	upid, err = c.CloneVM(DefaultNodeName, vmID, &proxmox.VirtualEnvironmentVMCloneRequestBody{
+		Name:    &cloneName,
+		VMIDNew: 101,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to clone VM: %s", err.Error())
+	}
+
+	err = c.WaitForTask(DefaultNodeName, *upid, 10, 1)
+
+	if err != nil {
+		t.Fatalf("Failed to wait for task: %s", err.Error())
+	}
+
+	s.Do(func(state *State) {
+		clone := state.Guests[101]
+
+		if clone == nil || clone.Config["name"] != cloneName || !strings.HasPrefix(clone.Config["scsi0"], "local-lvm:vm-101-disk-0") {
+			t.Fatalf("Expected the clone to be named \"%s\" and to have its own disk - got: %v", cloneName, clone)
+		}
+	})
+
+	_, err = c.DeleteVM(DefaultNodeName, vmID)
+
+	if err != nil {
+		t.Fatalf("Failed to delete VM: %s", err.Error())
+	}
+
+	_, err = c.GetVM(DefaultNodeName, vmID)
+
+	if !proxmox.IsNotFound(err) {
+		t.Fatalf("Expected a \"not found\" error - got: %v", err)
+	}
+}
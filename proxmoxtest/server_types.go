@@ -0,0 +1,179 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmoxtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultNodeAddress contains the address of the default node.
+	DefaultNodeAddress = "10.0.0.2"
+	// DefaultNodeName contains the name of the default node.
+	DefaultNodeName = "pve"
+	// DefaultPassword contains the password of the root account.
+	DefaultPassword = "password"
+	// DefaultVersion contains the version reported by the server.
+	DefaultVersion = "6.2-4"
+
+	containerDefaultRootFSSize = 4
+	containerDefaultStorage    = "local"
+	guestDiskSizeSuffix        = "G"
+	guestStatusRunning         = "running"
+	guestStatusStopped         = "stopped"
+	guestTypeContainer         = "lxc"
+	guestTypeVM                = "qemu"
+	minimumVMID                = 100
+	taskExitStatusOK           = "OK"
+	taskStatusStopped          = "stopped"
+)
+
+// ACLEntry contains an access control list entry.
+type ACLEntry struct {
+	Path          string
+	Propagate     bool
+	RoleID        string
+	Type          string
+	UserOrGroupID string
+}
+
+// Datastore contains the state of a datastore.
+type Datastore struct {
+	ContentTypes []string
+	Disabled     bool
+	Files        map[string]*DatastoreFile
+	Nodes        []string
+	Path         string
+	Shared       bool
+	Type         string
+}
+
+// DatastoreFile contains the state of a file, which is stored in a datastore.
+type DatastoreFile struct {
+	ContentType string
+	FileFormat  string
+	FileSize    int
+}
+
+// Group contains the state of an access group.
+type Group struct {
+	Comment string
+}
+
+// Guest contains the state of a virtual machine or a container.
+// The configuration contains the raw values, as they are returned by the configuration endpoint.
+type Guest struct {
+	Config   map[string]string
+	Lock     string
+	NodeName string
+	PoolID   string
+	Status   string
+	Type     string
+}
+
+// Node contains the state of a node.
+type Node struct {
+	Address           string
+	CustomCertificate string
+	CustomPrivateKey  string
+	DNSSearchDomain   string
+	DNSServers        []string
+	Hosts             string
+}
+
+// Pool contains the state of a pool.
+type Pool struct {
+	Comment string
+}
+
+// Role contains the state of a role.
+type Role struct {
+	Privileges []string
+	Special    bool
+}
+
+// Server implements an in-memory fake of the Proxmox Virtual Environment API.
+type Server struct {
+	URL string
+
+	mutex   sync.Mutex
+	server  *httptest.Server
+	state   *State
+	tickets map[string]*serverTicket
+}
+
+// State contains the in-memory state of a server.
+// The guests are keyed by their VM identifier, while the other maps are keyed by the identifier of the object.
+type State struct {
+	ACL        []*ACLEntry
+	APITokens  map[string]string
+	Datastores map[string]*Datastore
+	Groups     map[string]*Group
+	Guests     map[int]*Guest
+	Nodes      map[string]*Node
+	Pools      map[string]*Pool
+	Roles      map[string]*Role
+	Tasks      map[string]*Task
+	Users      map[string]*User
+	Version    string
+}
+
+// Task contains the state of a task, which completes as soon as it has been started.
+type Task struct {
+	ExitStatus string
+	ID         string
+	Log        []string
+	NodeName   string
+	StartTime  time.Time
+	Type       string
+	User       string
+}
+
+// User contains the state of a user.
+type User struct {
+	Comment        string
+	Email          string
+	Enabled        bool
+	ExpirationDate int64
+	FirstName      string
+	Groups         []string
+	Keys           string
+	LastName       string
+	Password       string
+}
+
+// serverError contains the details of an error response.
+type serverError struct {
+	errors     map[string]string
+	message    string
+	statusCode int
+}
+
+// serverRequest contains a request and the path parameters, which have been extracted from it.
+type serverRequest struct {
+	params   map[string]string
+	request  *http.Request
+	username string
+}
+
+// serverResponseBody contains a complete response body, which is not wrapped in a data object.
+type serverResponseBody map[string]interface{}
+
+// serverRoute maps a method and a path pattern to a handler.
+type serverRoute struct {
+	handler func(s *Server, r *serverRequest) (interface{}, error)
+	method  string
+	pattern string
+	params  map[string]string
+}
+
+// serverTicket contains the details of an authentication ticket.
+type serverTicket struct {
+	csrfPreventionToken string
+	username            string
+}
@@ -5,30 +5,74 @@
 package proxmoxtf
 
 import (
+	"context"
 	"errors"
+	"io/ioutil"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/danitso/terraform-provider-proxmox/proxmox"
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
 )
 
 const (
-	mkProviderVirtualEnvironment         = "virtual_environment"
-	mkProviderVirtualEnvironmentEndpoint = "endpoint"
-	mkProviderVirtualEnvironmentInsecure = "insecure"
-	mkProviderVirtualEnvironmentPassword = "password"
-	mkProviderVirtualEnvironmentUsername = "username"
+	dvProviderVirtualEnvironmentMaxConcurrentRequests    = 0
+	dvProviderVirtualEnvironmentMaxConcurrentSSHSessions = 0
+	dvProviderVirtualEnvironmentRetryAttempts            = proxmox.DefaultRetryAttempts
+	dvProviderVirtualEnvironmentRetryWaitMax             = "30s"
+	dvProviderVirtualEnvironmentRetryWaitMin             = "1s"
+	dvProviderVirtualEnvironmentSSHAgent                 = false
+	dvProviderVirtualEnvironmentSSHInsecure              = false
+	dvProviderVirtualEnvironmentSSHPort                  = 22
+	dvProviderVirtualEnvironmentSSHTrustOnFirstUse       = false
+	dvProviderVirtualEnvironmentVMIDRangeMax             = proxmox.MaximumVMID
+	dvProviderVirtualEnvironmentVMIDRangeMin             = proxmox.MinimumVMID
+
+	mkProviderVirtualEnvironment                         = "virtual_environment"
+	mkProviderVirtualEnvironmentAPIToken                 = "api_token"
+	mkProviderVirtualEnvironmentCABundle                 = "ca_bundle"
+	mkProviderVirtualEnvironmentCABundleFile             = "ca_bundle_file"
+	mkProviderVirtualEnvironmentEndpoint                 = "endpoint"
+	mkProviderVirtualEnvironmentEndpoints                = "endpoints"
+	mkProviderVirtualEnvironmentFingerprint              = "fingerprint"
+	mkProviderVirtualEnvironmentInsecure                 = "insecure"
+	mkProviderVirtualEnvironmentMaxConcurrentRequests    = "max_concurrent_requests"
+	mkProviderVirtualEnvironmentMaxConcurrentSSHSessions = "max_concurrent_ssh_sessions"
+	mkProviderVirtualEnvironmentNodeAddresses            = "node_addresses"
+	mkProviderVirtualEnvironmentPassword                 = "password"
+	mkProviderVirtualEnvironmentRetryAttempts            = "retry_attempts"
+	mkProviderVirtualEnvironmentRetryWaitMax             = "retry_wait_max"
+	mkProviderVirtualEnvironmentRetryWaitMin             = "retry_wait_min"
+	mkProviderVirtualEnvironmentSSH                      = "ssh"
+	mkProviderVirtualEnvironmentSSHAgent                 = "agent"
+	mkProviderVirtualEnvironmentSSHAgentSocket           = "agent_socket"
+	mkProviderVirtualEnvironmentSSHHostKeys              = "host_keys"
+	mkProviderVirtualEnvironmentSSHInsecure              = "insecure"
+	mkProviderVirtualEnvironmentSSHKnownHostsFile        = "known_hosts_file"
+	mkProviderVirtualEnvironmentSSHPassword              = "password"
+	mkProviderVirtualEnvironmentSSHPort                  = "port"
+	mkProviderVirtualEnvironmentSSHPrivateKey            = "private_key"
+	mkProviderVirtualEnvironmentSSHPrivateKeyFile        = "private_key_file"
+	mkProviderVirtualEnvironmentSSHPrivateKeyPassphrase  = "private_key_passphrase"
+	mkProviderVirtualEnvironmentSSHTrustOnFirstUse       = "trust_on_first_use"
+	mkProviderVirtualEnvironmentSSHUsername              = "username"
+	mkProviderVirtualEnvironmentUsername                 = "username"
+	mkProviderVirtualEnvironmentVMIDRange                = "vm_id_range"
+	mkProviderVirtualEnvironmentVMIDRangeMax             = "max"
+	mkProviderVirtualEnvironmentVMIDRangeMin             = "min"
 )
 
 type providerConfiguration struct {
-	veClient *proxmox.VirtualEnvironmentClient
+	stopContext context.Context
+	veClient    *proxmox.VirtualEnvironmentClient
 }
 
 // Provider returns the object for this provider.
 func Provider() *schema.Provider {
-	return &schema.Provider{
-		ConfigureFunc: providerConfigure,
+	provider := &schema.Provider{
 		DataSourcesMap: map[string]*schema.Resource{
 			"proxmox_virtual_environment_datastores": dataSourceVirtualEnvironmentDatastores(),
 			"proxmox_virtual_environment_dns":        dataSourceVirtualEnvironmentDNS(),
@@ -62,6 +106,34 @@ func Provider() *schema.Provider {
 				Optional: true,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
+						mkProviderVirtualEnvironmentAPIToken: {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The API token for the Proxmox Virtual Environment API (format: username@realm!tokenid=secret)",
+							DefaultFunc: schema.MultiEnvDefaultFunc(
+								[]string{"PROXMOX_VE_API_TOKEN", "PM_VE_API_TOKEN"},
+								"",
+							),
+							Sensitive: true,
+						},
+						mkProviderVirtualEnvironmentCABundle: {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The PEM encoded CA certificates used to verify the server certificate",
+							DefaultFunc: schema.MultiEnvDefaultFunc(
+								[]string{"PROXMOX_VE_CA_BUNDLE", "PM_VE_CA_BUNDLE"},
+								"",
+							),
+						},
+						mkProviderVirtualEnvironmentCABundleFile: {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The path to a file containing the PEM encoded CA certificates used to verify the server certificate",
+							DefaultFunc: schema.MultiEnvDefaultFunc(
+								[]string{"PROXMOX_VE_CA_BUNDLE_FILE", "PM_VE_CA_BUNDLE_FILE"},
+								"",
+							),
+						},
 						mkProviderVirtualEnvironmentEndpoint: {
 							Type:        schema.TypeString,
 							Optional:    true,
@@ -74,9 +146,7 @@ func Provider() *schema.Provider {
 								value := v.(string)
 
 								if value == "" {
-									return []string{}, []error{
-										errors.New("You must specify an endpoint for the Proxmox Virtual Environment API (valid: https://host:port)"),
-									}
+									return []string{}, []error{}
 								}
 
 								_, err := url.ParseRequestURI(value)
@@ -90,6 +160,21 @@ func Provider() *schema.Provider {
 								return []string{}, []error{}
 							},
 						},
+						mkProviderVirtualEnvironmentEndpoints: {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "The additional endpoints for the Proxmox Virtual Environment API, which are used when the previous endpoints cannot be reached",
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						mkProviderVirtualEnvironmentFingerprint: {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The expected SHA-256 fingerprints of the server certificates (separated by commas)",
+							DefaultFunc: schema.MultiEnvDefaultFunc(
+								[]string{"PROXMOX_VE_FINGERPRINT", "PM_VE_FINGERPRINT"},
+								"",
+							),
+						},
 						mkProviderVirtualEnvironmentInsecure: {
 							Type:        schema.TypeBool,
 							Optional:    true,
@@ -106,6 +191,26 @@ func Provider() *schema.Provider {
 								return false, nil
 							},
 						},
+						mkProviderVirtualEnvironmentMaxConcurrentRequests: {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "The maximum number of concurrent API requests (0 means unlimited)",
+							Default:      dvProviderVirtualEnvironmentMaxConcurrentRequests,
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+						mkProviderVirtualEnvironmentMaxConcurrentSSHSessions: {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "The maximum number of concurrent SSH sessions (0 means unlimited)",
+							Default:      dvProviderVirtualEnvironmentMaxConcurrentSSHSessions,
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+						mkProviderVirtualEnvironmentNodeAddresses: {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Description: "The addresses used to connect to the nodes, indexed by node name",
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
 						mkProviderVirtualEnvironmentPassword: {
 							Type:        schema.TypeString,
 							Optional:    true,
@@ -114,17 +219,145 @@ func Provider() *schema.Provider {
 								[]string{"PROXMOX_VE_PASSWORD", "PM_VE_PASSWORD"},
 								"",
 							),
-							ValidateFunc: func(v interface{}, k string) (warns []string, errs []error) {
-								value := v.(string)
+							Sensitive: true,
+						},
+						mkProviderVirtualEnvironmentRetryAttempts: {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "The maximum number of attempts for requests which fail due to transient errors",
+							Default:      dvProviderVirtualEnvironmentRetryAttempts,
+							ValidateFunc: validation.IntAtLeast(1),
+						},
+						mkProviderVirtualEnvironmentRetryWaitMax: {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "The maximum delay between two attempts",
+							Default:      dvProviderVirtualEnvironmentRetryWaitMax,
+							ValidateFunc: getTimeoutValidator(),
+						},
+						mkProviderVirtualEnvironmentRetryWaitMin: {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "The minimum delay between two attempts",
+							Default:      dvProviderVirtualEnvironmentRetryWaitMin,
+							ValidateFunc: getTimeoutValidator(),
+						},
+						mkProviderVirtualEnvironmentSSH: {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "The SSH configuration for the nodes",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									mkProviderVirtualEnvironmentSSHAgent: {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Description: "Whether to authenticate using the SSH agent",
+										DefaultFunc: func() (interface{}, error) {
+											for _, k := range []string{"PROXMOX_VE_SSH_AGENT", "PM_VE_SSH_AGENT"} {
+												v := os.Getenv(k)
 
-								if value == "" {
-									return []string{}, []error{
-										errors.New("You must specify a password for the Proxmox Virtual Environment API"),
-									}
-								}
+												if v == "true" || v == "1" {
+													return true, nil
+												}
+											}
 
-								return []string{}, []error{}
+											return dvProviderVirtualEnvironmentSSHAgent, nil
+										},
+									},
+									mkProviderVirtualEnvironmentSSHAgentSocket: {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "The path to the SSH agent socket (defaults to SSH_AUTH_SOCK)",
+										DefaultFunc: schema.MultiEnvDefaultFunc(
+											[]string{"PROXMOX_VE_SSH_AUTH_SOCK", "SSH_AUTH_SOCK"},
+											"",
+										),
+									},
+									mkProviderVirtualEnvironmentSSHHostKeys: {
+										Type:        schema.TypeList,
+										Optional:    true,
+										Description: "The trusted host keys (format: [host] type key)",
+										Elem:        &schema.Schema{Type: schema.TypeString},
+									},
+									mkProviderVirtualEnvironmentSSHInsecure: {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Description: "Whether to skip the host key verification step",
+										Default:     dvProviderVirtualEnvironmentSSHInsecure,
+									},
+									mkProviderVirtualEnvironmentSSHKnownHostsFile: {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "The path to the known_hosts file (defaults to ~/.ssh/known_hosts)",
+										DefaultFunc: schema.MultiEnvDefaultFunc(
+											[]string{"PROXMOX_VE_SSH_KNOWN_HOSTS_FILE", "PM_VE_SSH_KNOWN_HOSTS_FILE"},
+											"",
+										),
+									},
+									mkProviderVirtualEnvironmentSSHPassword: {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "The SSH password (defaults to the password for the API)",
+										DefaultFunc: schema.MultiEnvDefaultFunc(
+											[]string{"PROXMOX_VE_SSH_PASSWORD", "PM_VE_SSH_PASSWORD"},
+											"",
+										),
+										Sensitive: true,
+									},
+									mkProviderVirtualEnvironmentSSHPort: {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Description:  "The SSH port",
+										Default:      dvProviderVirtualEnvironmentSSHPort,
+										ValidateFunc: validation.IntBetween(1, 65535),
+									},
+									mkProviderVirtualEnvironmentSSHPrivateKey: {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "The PEM encoded SSH private key",
+										DefaultFunc: schema.MultiEnvDefaultFunc(
+											[]string{"PROXMOX_VE_SSH_PRIVATE_KEY", "PM_VE_SSH_PRIVATE_KEY"},
+											"",
+										),
+										Sensitive: true,
+									},
+									mkProviderVirtualEnvironmentSSHPrivateKeyFile: {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "The path to a file containing the PEM encoded SSH private key",
+										DefaultFunc: schema.MultiEnvDefaultFunc(
+											[]string{"PROXMOX_VE_SSH_PRIVATE_KEY_FILE", "PM_VE_SSH_PRIVATE_KEY_FILE"},
+											"",
+										),
+									},
+									mkProviderVirtualEnvironmentSSHPrivateKeyPassphrase: {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "The passphrase for the SSH private key",
+										DefaultFunc: schema.MultiEnvDefaultFunc(
+											[]string{"PROXMOX_VE_SSH_PRIVATE_KEY_PASSPHRASE", "PM_VE_SSH_PRIVATE_KEY_PASSPHRASE"},
+											"",
+										),
+										Sensitive: true,
+									},
+									mkProviderVirtualEnvironmentSSHTrustOnFirstUse: {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Description: "Whether to trust unknown host keys and add them to the known_hosts file (requires known_hosts_file)",
+										Default:     dvProviderVirtualEnvironmentSSHTrustOnFirstUse,
+									},
+									mkProviderVirtualEnvironmentSSHUsername: {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "The SSH username (defaults to the user part of the username for the API)",
+										DefaultFunc: schema.MultiEnvDefaultFunc(
+											[]string{"PROXMOX_VE_SSH_USERNAME", "PM_VE_SSH_USERNAME"},
+											"",
+										),
+									},
+								},
 							},
+							MaxItems: 1,
 						},
 						mkProviderVirtualEnvironmentUsername: {
 							Type:        schema.TypeString,
@@ -134,17 +367,30 @@ func Provider() *schema.Provider {
 								[]string{"PROXMOX_VE_USERNAME", "PM_VE_USERNAME"},
 								"",
 							),
-							ValidateFunc: func(v interface{}, k string) (warns []string, errs []error) {
-								value := v.(string)
-
-								if value == "" {
-									return []string{}, []error{
-										errors.New("You must specify a username for the Proxmox Virtual Environment API (valid: username@realm)"),
-									}
-								}
-
-								return []string{}, []error{}
+						},
+						mkProviderVirtualEnvironmentVMIDRange: {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "The range of VM identifiers, which are allocated for resources without a VM identifier",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									mkProviderVirtualEnvironmentVMIDRangeMax: {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Description:  "The highest VM identifier",
+										Default:      dvProviderVirtualEnvironmentVMIDRangeMax,
+										ValidateFunc: validation.IntBetween(proxmox.MinimumVMID, proxmox.MaximumVMID),
+									},
+									mkProviderVirtualEnvironmentVMIDRangeMin: {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Description:  "The lowest VM identifier",
+										Default:      dvProviderVirtualEnvironmentVMIDRangeMin,
+										ValidateFunc: validation.IntBetween(proxmox.MinimumVMID, proxmox.MaximumVMID),
+									},
+								},
 							},
+							MaxItems: 1,
 						},
 					},
 				},
@@ -152,9 +398,15 @@ func Provider() *schema.Provider {
 			},
 		},
 	}
+
+	provider.ConfigureFunc = func(d *schema.ResourceData) (interface{}, error) {
+		return providerConfigure(d, provider.StopContext())
+	}
+
+	return provider
 }
 
-func providerConfigure(d *schema.ResourceData) (interface{}, error) {
+func providerConfigure(d *schema.ResourceData, stopContext context.Context) (interface{}, error) {
 	var err error
 	var veClient *proxmox.VirtualEnvironmentClient
 
@@ -164,20 +416,176 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 	if len(veConfigBlock) > 0 {
 		veConfig := veConfigBlock[0].(map[string]interface{})
 
+		endpoints := []string{}
+		endpoint := veConfig[mkProviderVirtualEnvironmentEndpoint].(string)
+
+		if endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+
+		for _, v := range veConfig[mkProviderVirtualEnvironmentEndpoints].([]interface{}) {
+			endpoints = append(endpoints, v.(string))
+		}
+
+		if len(endpoints) == 0 {
+			return nil, errors.New("You must specify an endpoint for the Proxmox Virtual Environment API (valid: https://host:port)")
+		}
+
 		veClient, err = proxmox.NewVirtualEnvironmentClient(
-			veConfig[mkProviderVirtualEnvironmentEndpoint].(string),
+			endpoints[0],
 			veConfig[mkProviderVirtualEnvironmentUsername].(string),
 			veConfig[mkProviderVirtualEnvironmentPassword].(string),
+			veConfig[mkProviderVirtualEnvironmentAPIToken].(string),
 			veConfig[mkProviderVirtualEnvironmentInsecure].(bool),
 		)
 
 		if err != nil {
 			return nil, err
 		}
+
+		err = veClient.SetEndpoints(endpoints)
+
+		if err != nil {
+			return nil, err
+		}
+
+		caBundle := []byte(veConfig[mkProviderVirtualEnvironmentCABundle].(string))
+		caBundleFile := veConfig[mkProviderVirtualEnvironmentCABundleFile].(string)
+
+		if caBundleFile != "" {
+			if len(caBundle) > 0 {
+				return nil, errors.New("You cannot specify both an inline CA bundle and a CA bundle file")
+			}
+
+			caBundle, err = ioutil.ReadFile(caBundleFile)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		fingerprints := []string{}
+
+		for _, v := range strings.Split(veConfig[mkProviderVirtualEnvironmentFingerprint].(string), ",") {
+			fingerprint := strings.TrimSpace(v)
+
+			if fingerprint != "" {
+				fingerprints = append(fingerprints, fingerprint)
+			}
+		}
+
+		err = veClient.ConfigureTLS(caBundle, fingerprints)
+
+		if err != nil {
+			return nil, err
+		}
+
+		retryWaitMax, err := time.ParseDuration(veConfig[mkProviderVirtualEnvironmentRetryWaitMax].(string))
+
+		if err != nil {
+			return nil, err
+		}
+
+		retryWaitMin, err := time.ParseDuration(veConfig[mkProviderVirtualEnvironmentRetryWaitMin].(string))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if retryWaitMin > retryWaitMax {
+			return nil, errors.New("You must specify a minimum retry delay which is less than or equal to the maximum retry delay")
+		}
+
+		sshHostKeyPolicy := &proxmox.VirtualEnvironmentSSHHostKeyPolicy{}
+		sshSettings := &proxmox.VirtualEnvironmentSSHSettings{}
+		sshConfigBlock := veConfig[mkProviderVirtualEnvironmentSSH].([]interface{})
+
+		if len(sshConfigBlock) > 0 && sshConfigBlock[0] != nil {
+			sshConfig := sshConfigBlock[0].(map[string]interface{})
+			sshPrivateKey := []byte(sshConfig[mkProviderVirtualEnvironmentSSHPrivateKey].(string))
+			sshPrivateKeyFile := sshConfig[mkProviderVirtualEnvironmentSSHPrivateKeyFile].(string)
+
+			if sshPrivateKeyFile != "" {
+				if len(sshPrivateKey) > 0 {
+					return nil, errors.New("You cannot specify both an inline SSH private key and an SSH private key file")
+				}
+
+				sshPrivateKey, err = ioutil.ReadFile(sshPrivateKeyFile)
+
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			sshSettings.Agent = sshConfig[mkProviderVirtualEnvironmentSSHAgent].(bool)
+			sshSettings.AgentSocket = sshConfig[mkProviderVirtualEnvironmentSSHAgentSocket].(string)
+			sshSettings.Password = sshConfig[mkProviderVirtualEnvironmentSSHPassword].(string)
+			sshSettings.Port = sshConfig[mkProviderVirtualEnvironmentSSHPort].(int)
+			sshSettings.PrivateKey = sshPrivateKey
+			sshSettings.PrivateKeyPassphrase = sshConfig[mkProviderVirtualEnvironmentSSHPrivateKeyPassphrase].(string)
+			sshSettings.Username = sshConfig[mkProviderVirtualEnvironmentSSHUsername].(string)
+			sshHostKeys := sshConfig[mkProviderVirtualEnvironmentSSHHostKeys].([]interface{})
+
+			sshHostKeyPolicy.HostKeys = make([]string, len(sshHostKeys))
+
+			for i, v := range sshHostKeys {
+				sshHostKeyPolicy.HostKeys[i] = v.(string)
+			}
+
+			sshHostKeyPolicy.Insecure = sshConfig[mkProviderVirtualEnvironmentSSHInsecure].(bool)
+			sshHostKeyPolicy.KnownHostsFile = sshConfig[mkProviderVirtualEnvironmentSSHKnownHostsFile].(string)
+			sshHostKeyPolicy.TrustOnFirstUse = sshConfig[mkProviderVirtualEnvironmentSSHTrustOnFirstUse].(bool)
+		}
+
+		err = veClient.SetSSHHostKeyPolicy(sshHostKeyPolicy)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = veClient.SetSSHSettings(sshSettings)
+
+		if err != nil {
+			return nil, err
+		}
+
+		nodeAddresses := veConfig[mkProviderVirtualEnvironmentNodeAddresses].(map[string]interface{})
+		veClient.NodeAddresses = make(map[string]string, len(nodeAddresses))
+
+		for k, v := range nodeAddresses {
+			veClient.NodeAddresses[k] = v.(string)
+		}
+
+		vmIDRangeBlock := veConfig[mkProviderVirtualEnvironmentVMIDRange].([]interface{})
+
+		if len(vmIDRangeBlock) > 0 && vmIDRangeBlock[0] != nil {
+			vmIDRange := vmIDRangeBlock[0].(map[string]interface{})
+
+			err = veClient.SetVMIDRange(
+				vmIDRange[mkProviderVirtualEnvironmentVMIDRangeMin].(int),
+				vmIDRange[mkProviderVirtualEnvironmentVMIDRangeMax].(int),
+			)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		veClient.SetConcurrencyLimits(
+			veConfig[mkProviderVirtualEnvironmentMaxConcurrentRequests].(int),
+			veConfig[mkProviderVirtualEnvironmentMaxConcurrentSSHSessions].(int),
+		)
+
+		veClient.RetryPolicy = &proxmox.VirtualEnvironmentRetryPolicy{
+			Attempts: veConfig[mkProviderVirtualEnvironmentRetryAttempts].(int),
+			WaitMax:  retryWaitMax,
+			WaitMin:  retryWaitMin,
+		}
 	}
 
 	config := providerConfiguration{
-		veClient: veClient,
+		stopContext: stopContext,
+		veClient:    veClient,
 	}
 
 	return config, nil
@@ -5,11 +5,27 @@
 package proxmoxtf
 
 import (
+	"context"
 	"testing"
 
+	"github.com/danitso/terraform-provider-proxmox/proxmoxtest"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
+// testNewProviderConfiguration creates a provider configuration, which uses a client for the specified fake server.
+func testNewProviderConfiguration(t *testing.T, s *proxmoxtest.Server) providerConfiguration {
+	veClient, err := s.NewClient()
+
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+
+	return providerConfiguration{
+		stopContext: context.Background(),
+		veClient:    veClient,
+	}
+}
+
 // TestProviderInstantiation() tests whether the Provider instance can be instantiated.
 func TestProviderInstantiation(t *testing.T) {
 	s := Provider()
@@ -36,16 +52,86 @@ func TestProviderSchema(t *testing.T) {
 	veSchema := testNestedSchemaExistence(t, s, mkProviderVirtualEnvironment)
 
 	testOptionalArguments(t, veSchema, []string{
+		mkProviderVirtualEnvironmentAPIToken,
+		mkProviderVirtualEnvironmentCABundle,
+		mkProviderVirtualEnvironmentCABundleFile,
 		mkProviderVirtualEnvironmentEndpoint,
+		mkProviderVirtualEnvironmentEndpoints,
+		mkProviderVirtualEnvironmentFingerprint,
 		mkProviderVirtualEnvironmentInsecure,
+		mkProviderVirtualEnvironmentMaxConcurrentRequests,
+		mkProviderVirtualEnvironmentMaxConcurrentSSHSessions,
+		mkProviderVirtualEnvironmentNodeAddresses,
 		mkProviderVirtualEnvironmentPassword,
+		mkProviderVirtualEnvironmentRetryAttempts,
+		mkProviderVirtualEnvironmentRetryWaitMax,
+		mkProviderVirtualEnvironmentRetryWaitMin,
+		mkProviderVirtualEnvironmentSSH,
 		mkProviderVirtualEnvironmentUsername,
+		mkProviderVirtualEnvironmentVMIDRange,
 	})
 
 	testValueTypes(t, veSchema, map[string]schema.ValueType{
-		mkProviderVirtualEnvironmentEndpoint: schema.TypeString,
-		mkProviderVirtualEnvironmentInsecure: schema.TypeBool,
-		mkProviderVirtualEnvironmentPassword: schema.TypeString,
-		mkProviderVirtualEnvironmentUsername: schema.TypeString,
+		mkProviderVirtualEnvironmentAPIToken:                 schema.TypeString,
+		mkProviderVirtualEnvironmentCABundle:                 schema.TypeString,
+		mkProviderVirtualEnvironmentCABundleFile:             schema.TypeString,
+		mkProviderVirtualEnvironmentEndpoint:                 schema.TypeString,
+		mkProviderVirtualEnvironmentEndpoints:                schema.TypeList,
+		mkProviderVirtualEnvironmentFingerprint:              schema.TypeString,
+		mkProviderVirtualEnvironmentInsecure:                 schema.TypeBool,
+		mkProviderVirtualEnvironmentMaxConcurrentRequests:    schema.TypeInt,
+		mkProviderVirtualEnvironmentMaxConcurrentSSHSessions: schema.TypeInt,
+		mkProviderVirtualEnvironmentNodeAddresses:            schema.TypeMap,
+		mkProviderVirtualEnvironmentPassword:                 schema.TypeString,
+		mkProviderVirtualEnvironmentRetryAttempts:            schema.TypeInt,
+		mkProviderVirtualEnvironmentRetryWaitMax:             schema.TypeString,
+		mkProviderVirtualEnvironmentRetryWaitMin:             schema.TypeString,
+		mkProviderVirtualEnvironmentSSH:                      schema.TypeList,
+		mkProviderVirtualEnvironmentUsername:                 schema.TypeString,
+		mkProviderVirtualEnvironmentVMIDRange:                schema.TypeList,
+	})
+
+	sshSchema := testNestedSchemaExistence(t, veSchema, mkProviderVirtualEnvironmentSSH)
+
+	testOptionalArguments(t, sshSchema, []string{
+		mkProviderVirtualEnvironmentSSHAgent,
+		mkProviderVirtualEnvironmentSSHAgentSocket,
+		mkProviderVirtualEnvironmentSSHHostKeys,
+		mkProviderVirtualEnvironmentSSHInsecure,
+		mkProviderVirtualEnvironmentSSHKnownHostsFile,
+		mkProviderVirtualEnvironmentSSHPassword,
+		mkProviderVirtualEnvironmentSSHPort,
+		mkProviderVirtualEnvironmentSSHPrivateKey,
+		mkProviderVirtualEnvironmentSSHPrivateKeyFile,
+		mkProviderVirtualEnvironmentSSHPrivateKeyPassphrase,
+		mkProviderVirtualEnvironmentSSHTrustOnFirstUse,
+		mkProviderVirtualEnvironmentSSHUsername,
+	})
+
+	testValueTypes(t, sshSchema, map[string]schema.ValueType{
+		mkProviderVirtualEnvironmentSSHAgent:                schema.TypeBool,
+		mkProviderVirtualEnvironmentSSHAgentSocket:          schema.TypeString,
+		mkProviderVirtualEnvironmentSSHHostKeys:             schema.TypeList,
+		mkProviderVirtualEnvironmentSSHInsecure:             schema.TypeBool,
+		mkProviderVirtualEnvironmentSSHKnownHostsFile:       schema.TypeString,
+		mkProviderVirtualEnvironmentSSHPassword:             schema.TypeString,
+		mkProviderVirtualEnvironmentSSHPort:                 schema.TypeInt,
+		mkProviderVirtualEnvironmentSSHPrivateKey:           schema.TypeString,
+		mkProviderVirtualEnvironmentSSHPrivateKeyFile:       schema.TypeString,
+		mkProviderVirtualEnvironmentSSHPrivateKeyPassphrase: schema.TypeString,
+		mkProviderVirtualEnvironmentSSHTrustOnFirstUse:      schema.TypeBool,
+		mkProviderVirtualEnvironmentSSHUsername:             schema.TypeString,
+	})
+
+	vmIDRangeSchema := testNestedSchemaExistence(t, veSchema, mkProviderVirtualEnvironmentVMIDRange)
+
+	testOptionalArguments(t, vmIDRangeSchema, []string{
+		mkProviderVirtualEnvironmentVMIDRangeMax,
+		mkProviderVirtualEnvironmentVMIDRangeMin,
+	})
+
+	testValueTypes(t, vmIDRangeSchema, map[string]schema.ValueType{
+		mkProviderVirtualEnvironmentVMIDRangeMax: schema.TypeInt,
+		mkProviderVirtualEnvironmentVMIDRangeMin: schema.TypeInt,
 	})
 }
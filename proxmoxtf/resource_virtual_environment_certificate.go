@@ -116,6 +116,9 @@ func resourceVirtualEnvironmentCertificate() *schema.Resource {
 		Read:   resourceVirtualEnvironmentCertificateRead,
 		Update: resourceVirtualEnvironmentCertificateUpdate,
 		Delete: resourceVirtualEnvironmentCertificateDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceVirtualEnvironmentCertificateImport,
+		},
 	}
 }
 
@@ -163,6 +166,20 @@ func resourceVirtualEnvironmentCertificateGetUpdateBody(d *schema.ResourceData,
 	return body, nil
 }
 
+func resourceVirtualEnvironmentCertificateImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	nodeName := strings.TrimSuffix(d.Id(), "_certificate")
+
+	if nodeName == "" {
+		return nil, fmt.Errorf("Invalid import ID \"%s\" (expected \"<node_name>\")", d.Id())
+	}
+
+	d.Set(mkResourceVirtualEnvironmentCertificateOverwrite, dvResourceVirtualEnvironmentCertificateOverwrite)
+	d.Set(mkResourceVirtualEnvironmentCertificateNodeName, nodeName)
+	d.SetId(fmt.Sprintf("%s_certificate", nodeName))
+
+	return []*schema.ResourceData{d}, nil
+}
+
 func resourceVirtualEnvironmentCertificateRead(d *schema.ResourceData, m interface{}) error {
 	config := m.(providerConfiguration)
 	veClient, err := config.GetVEClient()
@@ -178,26 +195,30 @@ func resourceVirtualEnvironmentCertificateRead(d *schema.ResourceData, m interfa
 		return err
 	}
 
+	found := false
+
 	d.Set(mkResourceVirtualEnvironmentCertificateCertificate, "")
 	d.Set(mkResourceVirtualEnvironmentCertificateCertificateChain, "")
 
-	certificateChain := d.Get(mkResourceVirtualEnvironmentCertificateCertificateChain).(string)
-
 	for _, c := range *list {
 		if c.FileName != nil && *c.FileName == "pveproxy-ssl.pem" {
+			found = true
+
 			if c.Certificates != nil {
-				newCertificate := ""
+				newCertificate := *c.Certificates
 				newCertificateChain := ""
 
-				if certificateChain != "" {
-					certificates := strings.Split(strings.TrimSpace(*c.Certificates), "\n")
-					newCertificate = certificates[0] + "\n"
+				// The node stores the certificate and the chain in the same file, which is why we need to split them.
+				certificates := strings.TrimSpace(*c.Certificates)
+				certificateEnd := strings.Index(certificates, "-----END CERTIFICATE-----")
 
-					if len(certificates) > 1 {
-						newCertificateChain = strings.Join(certificates[1:], "\n") + "\n"
+				if certificateEnd >= 0 {
+					certificateEnd += len("-----END CERTIFICATE-----")
+
+					if chain := strings.TrimSpace(certificates[certificateEnd:]); chain != "" {
+						newCertificate = certificates[:certificateEnd] + "\n"
+						newCertificateChain = chain + "\n"
 					}
-				} else {
-					newCertificate = *c.Certificates
 				}
 
 				d.Set(mkResourceVirtualEnvironmentCertificateCertificate, newCertificate)
@@ -274,6 +295,11 @@ func resourceVirtualEnvironmentCertificateRead(d *schema.ResourceData, m interfa
 		}
 	}
 
+	// The custom certificate has been removed outside of Terraform, which requires it to be uploaded again.
+	if !found && d.Id() != "" {
+		d.SetId("")
+	}
+
 	return nil
 }
 
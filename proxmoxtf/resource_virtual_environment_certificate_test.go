@@ -7,6 +7,7 @@ package proxmoxtf
 import (
 	"testing"
 
+	"github.com/danitso/terraform-provider-proxmox/proxmoxtest"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
@@ -61,3 +62,44 @@ func TestResourceVirtualEnvironmentCertificateSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentCertificateSubjectAlternativeNames: schema.TypeList,
 	})
 }
+
+// TestResourceVirtualEnvironmentCertificateImport tests whether the certificate chain is separated from the certificate,
+// when a certificate is imported.
+func TestResourceVirtualEnvironmentCertificateImport(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	certificate := "-----BEGIN CERTIFICATE-----\nbGVhZg==\n-----END CERTIFICATE-----\n"
+	certificateChain := "-----BEGIN CERTIFICATE-----\naW50ZXJtZWRpYXRl\n-----END CERTIFICATE-----\n"
+
+	server.Do(func(state *proxmoxtest.State) {
+		state.Nodes[proxmoxtest.DefaultNodeName].CustomCertificate = certificate + certificateChain
+	})
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentCertificate()
+
+	d := s.Data(nil)
+	d.SetId(proxmoxtest.DefaultNodeName)
+
+	imported, err := s.Importer.State(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to import certificate: %s", err.Error())
+	}
+
+	d = imported[0]
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %s", err.Error())
+	}
+
+	if d.Get(mkResourceVirtualEnvironmentCertificateCertificate).(string) != certificate {
+		t.Fatalf("Expected the certificate to exclude the chain - got: %s", d.Get(mkResourceVirtualEnvironmentCertificateCertificate))
+	}
+
+	if d.Get(mkResourceVirtualEnvironmentCertificateCertificateChain).(string) != certificateChain {
+		t.Fatalf("Expected the certificate chain to be imported - got: %s", d.Get(mkResourceVirtualEnvironmentCertificateCertificateChain))
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/danitso/terraform-provider-proxmox/proxmox"
 	"github.com/hashicorp/terraform/helper/schema"
@@ -35,6 +36,8 @@ const (
 	dvResourceVirtualEnvironmentContainerDiskDatastoreID                   = "local-lvm"
 	dvResourceVirtualEnvironmentContainerMemoryDedicated                   = 512
 	dvResourceVirtualEnvironmentContainerMemorySwap                        = 0
+	dvResourceVirtualEnvironmentContainerMigrate                           = true
+	dvResourceVirtualEnvironmentContainerMigrateTimeout                    = "3h"
 	dvResourceVirtualEnvironmentContainerNetworkInterfaceBridge            = "vmbr0"
 	dvResourceVirtualEnvironmentContainerNetworkInterfaceEnabled           = true
 	dvResourceVirtualEnvironmentContainerNetworkInterfaceMACAddress        = ""
@@ -82,6 +85,8 @@ const (
 	mkResourceVirtualEnvironmentContainerMemory                            = "memory"
 	mkResourceVirtualEnvironmentContainerMemoryDedicated                   = "dedicated"
 	mkResourceVirtualEnvironmentContainerMemorySwap                        = "swap"
+	mkResourceVirtualEnvironmentContainerMigrate                           = "migrate"
+	mkResourceVirtualEnvironmentContainerMigrateTimeout                    = "migrate_timeout"
 	mkResourceVirtualEnvironmentContainerNetworkInterface                  = "network_interface"
 	mkResourceVirtualEnvironmentContainerNetworkInterfaceBridge            = "bridge"
 	mkResourceVirtualEnvironmentContainerNetworkInterfaceEnabled           = "enabled"
@@ -431,6 +436,19 @@ func resourceVirtualEnvironmentContainer() *schema.Resource {
 				MaxItems: 1,
 				MinItems: 0,
 			},
+			mkResourceVirtualEnvironmentContainerMigrate: {
+				Type:        schema.TypeBool,
+				Description: "Whether to migrate the container instead of re-creating it, when the node name changes",
+				Optional:    true,
+				Default:     dvResourceVirtualEnvironmentContainerMigrate,
+			},
+			mkResourceVirtualEnvironmentContainerMigrateTimeout: {
+				Type:         schema.TypeString,
+				Description:  "The maximum amount of time to wait for a migration to finish",
+				Optional:     true,
+				Default:      dvResourceVirtualEnvironmentContainerMigrateTimeout,
+				ValidateFunc: getTimeoutValidator(),
+			},
 			mkResourceVirtualEnvironmentContainerNetworkInterface: {
 				Type:        schema.TypeList,
 				Description: "The network interfaces",
@@ -488,7 +506,6 @@ func resourceVirtualEnvironmentContainer() *schema.Resource {
 				Type:        schema.TypeString,
 				Description: "The node name",
 				Required:    true,
-				ForceNew:    true,
 			},
 			mkResourceVirtualEnvironmentContainerOperatingSystem: {
 				Type:        schema.TypeList,
@@ -503,6 +520,10 @@ func resourceVirtualEnvironmentContainer() *schema.Resource {
 							Required:     true,
 							ForceNew:     true,
 							ValidateFunc: getFileIDValidator(),
+							DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+								// The template file of an imported container cannot be determined from the API.
+								return d.Id() != "" && old == ""
+							},
 						},
 						mkResourceVirtualEnvironmentContainerOperatingSystemType: {
 							Type:         schema.TypeString,
@@ -546,12 +567,20 @@ func resourceVirtualEnvironmentContainer() *schema.Resource {
 				ForceNew:     true,
 				Default:      dvResourceVirtualEnvironmentContainerVMID,
 				ValidateFunc: getVMIDValidator(),
+				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+					// An allocated or imported VM identifier is retained, as long as no specific one has been requested.
+					return d.Id() != "" && new == strconv.Itoa(dvResourceVirtualEnvironmentContainerVMID)
+				},
 			},
 		},
 		Create: resourceVirtualEnvironmentContainerCreate,
 		Read:   resourceVirtualEnvironmentContainerRead,
 		Update: resourceVirtualEnvironmentContainerUpdate,
 		Delete: resourceVirtualEnvironmentContainerDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceVirtualEnvironmentContainerImport,
+		},
+		CustomizeDiff: resourceVirtualEnvironmentContainerCustomizeDiff,
 	}
 }
 
@@ -593,21 +622,10 @@ func resourceVirtualEnvironmentContainerCreateClone(d *schema.ResourceData, m in
 	poolID := d.Get(mkResourceVirtualEnvironmentContainerPoolID).(string)
 	vmID := d.Get(mkResourceVirtualEnvironmentContainerVMID).(int)
 
-	if vmID == -1 {
-		vmIDNew, err := veClient.GetVMID()
-
-		if err != nil {
-			return err
-		}
-
-		vmID = *vmIDNew
-	}
-
 	fullCopy := proxmox.CustomBool(true)
 
 	cloneBody := &proxmox.VirtualEnvironmentContainerCloneRequestBody{
 		FullCopy: &fullCopy,
-		VMIDNew:  vmID,
 	}
 
 	if cloneDatastoreID != "" {
@@ -626,22 +644,27 @@ func resourceVirtualEnvironmentContainerCreateClone(d *schema.ResourceData, m in
 		cloneBody.PoolID = &poolID
 	}
 
+	cloneSourceNodeName := nodeName
+
 	if cloneNodeName != "" && cloneNodeName != nodeName {
 		cloneBody.TargetNodeName = &nodeName
-
-		err = veClient.CloneContainer(cloneNodeName, cloneVMID, cloneBody)
-	} else {
-		err = veClient.CloneContainer(nodeName, cloneVMID, cloneBody)
+		cloneSourceNodeName = cloneNodeName
 	}
 
+	vmID, taskID, err := createWithVMID(config.stopContext, veClient, vmID, func(vmID int) (*string, error) {
+		cloneBody.VMIDNew = vmID
+
+		return veClient.CloneContainerWithContext(config.stopContext, cloneSourceNodeName, cloneVMID, cloneBody)
+	})
+
 	if err != nil {
 		return err
 	}
 
 	d.SetId(strconv.Itoa(vmID))
 
-	// Wait for the container to be created and its configuration lock to be released.
-	err = veClient.WaitForContainerLock(nodeName, vmID, 600, 5, true)
+	// Wait for the clone task to finish, as the container configuration remains locked until then.
+	err = veClient.WaitForTaskWithContext(config.stopContext, cloneSourceNodeName, *taskID, 600, 5)
 
 	if err != nil {
 		return err
@@ -866,7 +889,7 @@ func resourceVirtualEnvironmentContainerCreateClone(d *schema.ResourceData, m in
 	}
 
 	// Wait for the container's lock to be released.
-	err = veClient.WaitForContainerLock(nodeName, vmID, 600, 5, true)
+	err = veClient.WaitForContainerLockWithContext(config.stopContext, nodeName, vmID, 600, 5, true)
 
 	if err != nil {
 		return err
@@ -1062,16 +1085,6 @@ func resourceVirtualEnvironmentContainerCreateCustom(d *schema.ResourceData, m i
 	template := proxmox.CustomBool(d.Get(mkResourceVirtualEnvironmentContainerTemplate).(bool))
 	vmID := d.Get(mkResourceVirtualEnvironmentContainerVMID).(int)
 
-	if vmID == -1 {
-		vmIDNew, err := veClient.GetVMID()
-
-		if err != nil {
-			return err
-		}
-
-		vmID = *vmIDNew
-	}
-
 	// Attempt to create the resource using the retrieved values.
 	createBody := proxmox.VirtualEnvironmentContainerCreateRequestBody{
 		ConsoleEnabled:       &consoleEnabled,
@@ -1088,7 +1101,6 @@ func resourceVirtualEnvironmentContainerCreateCustom(d *schema.ResourceData, m i
 		Swap:                 &memorySwap,
 		Template:             &template,
 		TTY:                  &consoleTTYCount,
-		VMID:                 &vmID,
 	}
 
 	if description != "" {
@@ -1119,7 +1131,11 @@ func resourceVirtualEnvironmentContainerCreateCustom(d *schema.ResourceData, m i
 		createBody.PoolID = &poolID
 	}
 
-	err = veClient.CreateContainer(nodeName, &createBody)
+	vmID, taskID, err := createWithVMID(config.stopContext, veClient, vmID, func(vmID int) (*string, error) {
+		createBody.VMID = &vmID
+
+		return veClient.CreateContainerWithContext(config.stopContext, nodeName, &createBody)
+	})
 
 	if err != nil {
 		return err
@@ -1127,8 +1143,8 @@ func resourceVirtualEnvironmentContainerCreateCustom(d *schema.ResourceData, m i
 
 	d.SetId(strconv.Itoa(vmID))
 
-	// Wait for the container's lock to be released.
-	err = veClient.WaitForContainerLock(nodeName, vmID, 600, 5, true)
+	// Wait for the creation task to finish, as the container remains locked until then.
+	err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, 600, 5)
 
 	if err != nil {
 		return err
@@ -1160,13 +1176,19 @@ func resourceVirtualEnvironmentContainerCreateStart(d *schema.ResourceData, m in
 	}
 
 	// Start the container and wait for it to reach a running state before continuing.
-	err = veClient.StartContainer(nodeName, vmID)
+	taskID, err := veClient.StartContainerWithContext(config.stopContext, nodeName, vmID)
+
+	if err != nil {
+		return err
+	}
+
+	err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, 120, 5)
 
 	if err != nil {
 		return err
 	}
 
-	err = veClient.WaitForContainerState(nodeName, vmID, "running", 120, 5)
+	err = veClient.WaitForContainerStateWithContext(config.stopContext, nodeName, vmID, "running", 120, 5)
 
 	if err != nil {
 		return err
@@ -1175,6 +1197,15 @@ func resourceVirtualEnvironmentContainerCreateStart(d *schema.ResourceData, m in
 	return resourceVirtualEnvironmentContainerRead(d, m)
 }
 
+func resourceVirtualEnvironmentContainerCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	// A container is only re-created on another node, if migrations have been disabled.
+	if d.Id() != "" && d.HasChange(mkResourceVirtualEnvironmentContainerNodeName) && !d.Get(mkResourceVirtualEnvironmentContainerMigrate).(bool) {
+		return d.ForceNew(mkResourceVirtualEnvironmentContainerNodeName)
+	}
+
+	return nil
+}
+
 func resourceVirtualEnvironmentContainerGetConsoleModeValidator() schema.SchemaValidateFunc {
 	return validation.StringInSlice([]string{
 		"console",
@@ -1206,6 +1237,84 @@ func resourceVirtualEnvironmentContainerGetOperatingSystemTypeValidator() schema
 	}, false)
 }
 
+func resourceVirtualEnvironmentContainerImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	config := m.(providerConfiguration)
+	veClient, err := config.GetVEClient()
+
+	if err != nil {
+		return nil, err
+	}
+
+	nodeName, vmID, err := parseVMImportID(d.Id())
+
+	if err != nil {
+		return nil, err
+	}
+
+	poolID, err := getVMPoolID(veClient, vmID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.Set(mkResourceVirtualEnvironmentContainerNodeName, nodeName)
+	d.Set(mkResourceVirtualEnvironmentContainerPoolID, poolID)
+	d.Set(mkResourceVirtualEnvironmentContainerVMID, vmID)
+	d.SetId(strconv.Itoa(vmID))
+
+	return []*schema.ResourceData{d}, nil
+}
+
+func resourceVirtualEnvironmentContainerMigrate(d *schema.ResourceData, m interface{}, vmID int) error {
+	config := m.(providerConfiguration)
+	veClient, err := config.GetVEClient()
+
+	if err != nil {
+		return err
+	}
+
+	oldNodeName, newNodeName := d.GetChange(mkResourceVirtualEnvironmentContainerNodeName)
+	status, err := veClient.GetContainerStatusWithContext(config.stopContext, oldNodeName.(string), vmID)
+
+	if err != nil {
+		return err
+	}
+
+	migrateTimeout, err := time.ParseDuration(d.Get(mkResourceVirtualEnvironmentContainerMigrateTimeout).(string))
+
+	if err != nil {
+		return err
+	}
+
+	migrateBody := &proxmox.VirtualEnvironmentContainerMigrateRequestBody{
+		TargetNode: newNodeName.(string),
+	}
+
+	// Running containers cannot be migrated online, which is why they are shut down and started on the target node.
+	if status.Status == "running" {
+		restart := proxmox.CustomBool(true)
+		shutdownTimeout := 300
+
+		migrateBody.Restart = &restart
+		migrateBody.Timeout = &shutdownTimeout
+	}
+
+	taskID, err := veClient.MigrateContainerWithContext(config.stopContext, oldNodeName.(string), vmID, migrateBody)
+
+	if err == nil {
+		err = veClient.WaitForTaskWithContext(config.stopContext, oldNodeName.(string), *taskID, int(migrateTimeout.Seconds()), 5)
+	}
+
+	if err != nil {
+		// Keep the previous node name in the state, as the container still resides on the previous node.
+		d.Set(mkResourceVirtualEnvironmentContainerNodeName, oldNodeName)
+
+		return fmt.Errorf("Failed to migrate container %d from node \"%s\" to node \"%s\": %s", vmID, oldNodeName, newNodeName, err.Error())
+	}
+
+	return nil
+}
+
 func resourceVirtualEnvironmentContainerRead(d *schema.ResourceData, m interface{}) error {
 	config := m.(providerConfiguration)
 	veClient, err := config.GetVEClient()
@@ -1225,8 +1334,7 @@ func resourceVirtualEnvironmentContainerRead(d *schema.ResourceData, m interface
 	containerConfig, err := veClient.GetContainer(nodeName, vmID)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") ||
-			(strings.Contains(err.Error(), "HTTP 500") && strings.Contains(err.Error(), "does not exist")) {
+		if proxmox.IsNotFound(err) {
 			d.SetId("")
 
 			return nil
@@ -1338,11 +1446,11 @@ func resourceVirtualEnvironmentContainerRead(d *schema.ResourceData, m interface
 
 	if len(clone) > 0 {
 		if len(currentDisk) > 0 {
-			d.Set(mkResourceVirtualEnvironmentContainerDiskDatastoreID, []interface{}{disk})
+			d.Set(mkResourceVirtualEnvironmentContainerDisk, []interface{}{disk})
 		}
 	} else if len(currentDisk) > 0 ||
 		disk[mkResourceVirtualEnvironmentContainerDiskDatastoreID] != dvResourceVirtualEnvironmentContainerDiskDatastoreID {
-		d.Set(mkResourceVirtualEnvironmentContainerDiskDatastoreID, []interface{}{disk})
+		d.Set(mkResourceVirtualEnvironmentContainerDisk, []interface{}{disk})
 	}
 
 	// Compare the memory configuration to the one stored in the state.
@@ -1571,8 +1679,7 @@ func resourceVirtualEnvironmentContainerRead(d *schema.ResourceData, m interface
 		if len(currentMemory) > 0 {
 			d.Set(mkResourceVirtualEnvironmentContainerOperatingSystem, []interface{}{operatingSystem})
 		}
-	} else if len(currentOperatingSystem) > 0 ||
-		operatingSystem[mkResourceVirtualEnvironmentContainerOperatingSystemType] != dvResourceVirtualEnvironmentContainerOperatingSystemType {
+	} else {
 		d.Set(mkResourceVirtualEnvironmentContainerOperatingSystem, []interface{}{operatingSystem})
 	}
 
@@ -1613,6 +1720,15 @@ func resourceVirtualEnvironmentContainerUpdate(d *schema.ResourceData, m interfa
 		return err
 	}
 
+	// Migrate the container to the new node, before applying the remaining changes on that node.
+	if d.HasChange(mkResourceVirtualEnvironmentContainerNodeName) {
+		err = resourceVirtualEnvironmentContainerMigrate(d, m, vmID)
+
+		if err != nil {
+			return err
+		}
+	}
+
 	// Prepare the new request object.
 	updateBody := proxmox.VirtualEnvironmentContainerUpdateRequestBody{
 		Delete: []string{},
@@ -1847,13 +1963,19 @@ func resourceVirtualEnvironmentContainerUpdate(d *schema.ResourceData, m interfa
 
 	if d.HasChange(mkResourceVirtualEnvironmentContainerStarted) && !bool(template) {
 		if started {
-			err = veClient.StartContainer(nodeName, vmID)
+			taskID, err := veClient.StartContainerWithContext(config.stopContext, nodeName, vmID)
 
 			if err != nil {
 				return err
 			}
 
-			err = veClient.WaitForContainerState(nodeName, vmID, "running", 120, 5)
+			err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, 120, 5)
+
+			if err != nil {
+				return err
+			}
+
+			err = veClient.WaitForContainerStateWithContext(config.stopContext, nodeName, vmID, "running", 120, 5)
 
 			if err != nil {
 				return err
@@ -1862,7 +1984,7 @@ func resourceVirtualEnvironmentContainerUpdate(d *schema.ResourceData, m interfa
 			forceStop := proxmox.CustomBool(true)
 			shutdownTimeout := 300
 
-			err = veClient.ShutdownContainer(nodeName, vmID, &proxmox.VirtualEnvironmentContainerShutdownRequestBody{
+			taskID, err := veClient.ShutdownContainerWithContext(config.stopContext, nodeName, vmID, &proxmox.VirtualEnvironmentContainerShutdownRequestBody{
 				ForceStop: &forceStop,
 				Timeout:   &shutdownTimeout,
 			})
@@ -1871,7 +1993,7 @@ func resourceVirtualEnvironmentContainerUpdate(d *schema.ResourceData, m interfa
 				return err
 			}
 
-			err = veClient.WaitForContainerState(nodeName, vmID, "stopped", 30, 5)
+			err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, shutdownTimeout+30, 5)
 
 			if err != nil {
 				return err
@@ -1885,13 +2007,19 @@ func resourceVirtualEnvironmentContainerUpdate(d *schema.ResourceData, m interfa
 	if !bool(template) && rebootRequired {
 		rebootTimeout := 300
 
-		err = veClient.RebootContainer(nodeName, vmID, &proxmox.VirtualEnvironmentContainerRebootRequestBody{
+		taskID, err := veClient.RebootContainerWithContext(config.stopContext, nodeName, vmID, &proxmox.VirtualEnvironmentContainerRebootRequestBody{
 			Timeout: &rebootTimeout,
 		})
 
 		if err != nil {
 			return err
 		}
+
+		err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, rebootTimeout+30, 5)
+
+		if err != nil {
+			return err
+		}
 	}
 
 	return resourceVirtualEnvironmentContainerRead(d, m)
@@ -1916,6 +2044,12 @@ func resourceVirtualEnvironmentContainerDelete(d *schema.ResourceData, m interfa
 	status, err := veClient.GetContainerStatus(nodeName, vmID)
 
 	if err != nil {
+		if proxmox.IsNotFound(err) {
+			d.SetId("")
+
+			return nil
+		}
+
 		return err
 	}
 
@@ -1923,7 +2057,7 @@ func resourceVirtualEnvironmentContainerDelete(d *schema.ResourceData, m interfa
 		forceStop := proxmox.CustomBool(true)
 		shutdownTimeout := 300
 
-		err = veClient.ShutdownContainer(nodeName, vmID, &proxmox.VirtualEnvironmentContainerShutdownRequestBody{
+		taskID, err := veClient.ShutdownContainerWithContext(config.stopContext, nodeName, vmID, &proxmox.VirtualEnvironmentContainerShutdownRequestBody{
 			ForceStop: &forceStop,
 			Timeout:   &shutdownTimeout,
 		})
@@ -1932,17 +2066,17 @@ func resourceVirtualEnvironmentContainerDelete(d *schema.ResourceData, m interfa
 			return err
 		}
 
-		err = veClient.WaitForContainerState(nodeName, vmID, "stopped", 30, 5)
+		err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, shutdownTimeout+30, 5)
 
 		if err != nil {
 			return err
 		}
 	}
 
-	err = veClient.DeleteContainer(nodeName, vmID)
+	taskID, err := veClient.DeleteContainerWithContext(config.stopContext, nodeName, vmID)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") {
+		if proxmox.IsNotFound(err) {
 			d.SetId("")
 
 			return nil
@@ -1951,11 +2085,10 @@ func resourceVirtualEnvironmentContainerDelete(d *schema.ResourceData, m interfa
 		return err
 	}
 
-	// Wait for the state to become unavailable as that clearly indicates the destruction of the container.
-	err = veClient.WaitForContainerState(nodeName, vmID, "", 60, 2)
+	err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, 600, 2)
 
-	if err == nil {
-		return fmt.Errorf("Failed to delete container \"%d\"", vmID)
+	if err != nil {
+		return err
 	}
 
 	d.SetId("")
@@ -7,7 +7,9 @@ package proxmoxtf
 import (
 	"testing"
 
+	"github.com/danitso/terraform-provider-proxmox/proxmoxtest"
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
 )
 
 // TestResourceVirtualEnvironmentContainerInstantiation tests whether the ResourceVirtualEnvironmentContainer instance can be instantiated.
@@ -33,6 +35,8 @@ func TestResourceVirtualEnvironmentContainerSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentContainerDisk,
 		mkResourceVirtualEnvironmentContainerInitialization,
 		mkResourceVirtualEnvironmentContainerMemory,
+		mkResourceVirtualEnvironmentContainerMigrate,
+		mkResourceVirtualEnvironmentContainerMigrateTimeout,
 		mkResourceVirtualEnvironmentContainerOperatingSystem,
 		mkResourceVirtualEnvironmentContainerPoolID,
 		mkResourceVirtualEnvironmentContainerStarted,
@@ -46,6 +50,8 @@ func TestResourceVirtualEnvironmentContainerSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentContainerDisk:            schema.TypeList,
 		mkResourceVirtualEnvironmentContainerInitialization:  schema.TypeList,
 		mkResourceVirtualEnvironmentContainerMemory:          schema.TypeList,
+		mkResourceVirtualEnvironmentContainerMigrate:         schema.TypeBool,
+		mkResourceVirtualEnvironmentContainerMigrateTimeout:  schema.TypeString,
 		mkResourceVirtualEnvironmentContainerOperatingSystem: schema.TypeList,
 		mkResourceVirtualEnvironmentContainerPoolID:          schema.TypeString,
 		mkResourceVirtualEnvironmentContainerStarted:         schema.TypeBool,
@@ -220,3 +226,114 @@ func TestResourceVirtualEnvironmentContainerSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentContainerOperatingSystemType:           schema.TypeString,
 	})
 }
+
+// TestResourceVirtualEnvironmentContainerImport tests whether an imported container matches the configuration, which it
+// was created from, without having to be replaced.
+func TestResourceVirtualEnvironmentContainerImport(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentContainer()
+
+	raw := map[string]interface{}{
+		mkResourceVirtualEnvironmentContainerNodeName: proxmoxtest.DefaultNodeName,
+		mkResourceVirtualEnvironmentContainerOperatingSystem: []interface{}{
+			map[string]interface{}{
+				mkResourceVirtualEnvironmentContainerOperatingSystemTemplateFileID: "local:vztmpl/test.tar.gz",
+			},
+		},
+	}
+
+	err := s.Create(schema.TestResourceDataRaw(t, s.Schema, raw), config)
+
+	if err != nil {
+		t.Fatalf("Failed to create container: %s", err.Error())
+	}
+
+	d := s.Data(nil)
+	d.SetId("pve/100")
+
+	imported, err := s.Importer.State(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to import container: %s", err.Error())
+	}
+
+	d = imported[0]
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read container: %s", err.Error())
+	}
+
+	if d.Id() != "100" || d.Get(mkResourceVirtualEnvironmentContainerVMID).(int) != 100 {
+		t.Fatalf("Expected the container to be imported with VM identifier 100 - got: %s", d.Id())
+	}
+
+	diff, err := s.Diff(d.State(), terraform.NewResourceConfigRaw(raw), config)
+
+	if err != nil {
+		t.Fatalf("Failed to compute the differences: %s", err.Error())
+	}
+
+	if diff != nil && diff.RequiresNew() {
+		t.Fatalf("Expected the imported container not to require replacement - got: %v", diff)
+	}
+}
+
+// TestResourceVirtualEnvironmentContainerMigration tests whether a running container is restarted on another node, when
+// the node name changes.
+func TestResourceVirtualEnvironmentContainerMigration(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	server.Do(func(state *proxmoxtest.State) {
+		state.Nodes["pve2"] = &proxmoxtest.Node{}
+	})
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentContainer()
+
+	raw := map[string]interface{}{
+		mkResourceVirtualEnvironmentContainerNodeName: proxmoxtest.DefaultNodeName,
+		mkResourceVirtualEnvironmentContainerOperatingSystem: []interface{}{
+			map[string]interface{}{
+				mkResourceVirtualEnvironmentContainerOperatingSystemTemplateFileID: "local:vztmpl/test.tar.gz",
+			},
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, s.Schema, raw)
+	err := s.Create(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to create container: %s", err.Error())
+	}
+
+	raw[mkResourceVirtualEnvironmentContainerNodeName] = "pve2"
+
+	diff, err := s.Diff(d.State(), terraform.NewResourceConfigRaw(raw), config)
+
+	if err != nil {
+		t.Fatalf("Failed to compute the differences: %s", err.Error())
+	}
+
+	if diff == nil || diff.RequiresNew() {
+		t.Fatalf("Expected the container to be migrated instead of being replaced - got: %v", diff)
+	}
+
+	_, err = s.Apply(d.State(), diff, config)
+
+	if err != nil {
+		t.Fatalf("Failed to migrate container: %s", err.Error())
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		container := state.Guests[100]
+
+		if container == nil || container.NodeName != "pve2" || container.Status != "running" {
+			t.Fatalf("Expected container 100 to be running on node \"pve2\" - got: %v", container)
+		}
+	})
+}
@@ -6,6 +6,7 @@ package proxmoxtf
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/danitso/terraform-provider-proxmox/proxmox"
 	"github.com/hashicorp/terraform/helper/schema"
@@ -47,6 +48,9 @@ func resourceVirtualEnvironmentDNS() *schema.Resource {
 		Read:   resourceVirtualEnvironmentDNSRead,
 		Update: resourceVirtualEnvironmentDNSUpdate,
 		Delete: resourceVirtualEnvironmentDNSDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceVirtualEnvironmentDNSImport,
+		},
 	}
 }
 
@@ -88,6 +92,19 @@ func resourceVirtualEnvironmentDNSGetUpdateBody(d *schema.ResourceData, m interf
 	return body, nil
 }
 
+func resourceVirtualEnvironmentDNSImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	nodeName := strings.TrimSuffix(d.Id(), "_dns")
+
+	if nodeName == "" {
+		return nil, fmt.Errorf("Invalid import ID \"%s\" (expected \"<node_name>\")", d.Id())
+	}
+
+	d.Set(mkResourceVirtualEnvironmentDNSNodeName, nodeName)
+	d.SetId(fmt.Sprintf("%s_dns", nodeName))
+
+	return []*schema.ResourceData{d}, nil
+}
+
 func resourceVirtualEnvironmentDNSRead(d *schema.ResourceData, m interface{}) error {
 	config := m.(providerConfiguration)
 	veClient, err := config.GetVEClient()
@@ -5,8 +5,10 @@
 package proxmoxtf
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/danitso/terraform-provider-proxmox/proxmoxtest"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
@@ -38,3 +40,53 @@ func TestResourceVirtualEnvironmentDNSSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentDNSServers:  schema.TypeList,
 	})
 }
+
+// TestResourceVirtualEnvironmentDNSImport tests whether the DNS settings of a node can be imported.
+func TestResourceVirtualEnvironmentDNSImport(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentDNS()
+
+	for _, id := range []string{proxmoxtest.DefaultNodeName, fmt.Sprintf("%s_dns", proxmoxtest.DefaultNodeName)} {
+		d := s.Data(nil)
+		d.SetId(id)
+
+		imported, err := s.Importer.State(d, config)
+
+		if err != nil {
+			t.Fatalf("Failed to import DNS settings: %s", err.Error())
+		}
+
+		d = imported[0]
+		err = s.Read(d, config)
+
+		if err != nil {
+			t.Fatalf("Failed to read DNS settings: %s", err.Error())
+		}
+
+		if d.Id() != fmt.Sprintf("%s_dns", proxmoxtest.DefaultNodeName) || d.Get(mkResourceVirtualEnvironmentDNSNodeName) != proxmoxtest.DefaultNodeName {
+			t.Fatalf("Expected the DNS settings of node \"%s\" to be imported - got: %s", proxmoxtest.DefaultNodeName, d.Id())
+		}
+
+		if d.Get(mkResourceVirtualEnvironmentDNSDomain) != "example.com" {
+			t.Fatalf("Expected the domain to be \"example.com\" - got: %v", d.Get(mkResourceVirtualEnvironmentDNSDomain))
+		}
+
+		servers := d.Get(mkResourceVirtualEnvironmentDNSServers).([]interface{})
+
+		if len(servers) != 1 || servers[0] != "1.1.1.1" {
+			t.Fatalf("Expected the DNS servers to be imported - got: %v", servers)
+		}
+	}
+
+	d := s.Data(nil)
+	d.SetId("_dns")
+
+	_, err := s.Importer.State(d, config)
+
+	if err == nil {
+		t.Fatalf("Expected an error for an import ID without a node name")
+	}
+}
@@ -6,9 +6,13 @@ package proxmoxtf
 
 import (
 	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/tls"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"log"
@@ -24,31 +28,36 @@ import (
 )
 
 const (
-	dvResourceVirtualEnvironmentFileContentType        = ""
-	dvResourceVirtualEnvironmentFileSourceData         = ""
-	dvResourceVirtualEnvironmentFileSourceFileChanged  = false
-	dvResourceVirtualEnvironmentFileSourceFileChecksum = ""
-	dvResourceVirtualEnvironmentFileSourceFileFileName = ""
-	dvResourceVirtualEnvironmentFileSourceFileInsecure = false
-	dvResourceVirtualEnvironmentFileSourceRawResize    = 0
-
-	mkResourceVirtualEnvironmentFileContentType          = "content_type"
-	mkResourceVirtualEnvironmentFileDatastoreID          = "datastore_id"
-	mkResourceVirtualEnvironmentFileFileModificationDate = "file_modification_date"
-	mkResourceVirtualEnvironmentFileFileName             = "file_name"
-	mkResourceVirtualEnvironmentFileFileSize             = "file_size"
-	mkResourceVirtualEnvironmentFileFileTag              = "file_tag"
-	mkResourceVirtualEnvironmentFileNodeName             = "node_name"
-	mkResourceVirtualEnvironmentFileSourceFile           = "source_file"
-	mkResourceVirtualEnvironmentFileSourceFilePath       = "path"
-	mkResourceVirtualEnvironmentFileSourceFileChanged    = "changed"
-	mkResourceVirtualEnvironmentFileSourceFileChecksum   = "checksum"
-	mkResourceVirtualEnvironmentFileSourceFileFileName   = "file_name"
-	mkResourceVirtualEnvironmentFileSourceFileInsecure   = "insecure"
-	mkResourceVirtualEnvironmentFileSourceRaw            = "source_raw"
-	mkResourceVirtualEnvironmentFileSourceRawData        = "data"
-	mkResourceVirtualEnvironmentFileSourceRawFileName    = "file_name"
-	mkResourceVirtualEnvironmentFileSourceRawResize      = "resize"
+	dvResourceVirtualEnvironmentFileSourceData                       = ""
+	dvResourceVirtualEnvironmentFileSourceFileChanged                = false
+	dvResourceVirtualEnvironmentFileSourceFileChecksum               = ""
+	dvResourceVirtualEnvironmentFileSourceFileChecksumAlgorithm      = "sha256"
+	dvResourceVirtualEnvironmentFileSourceFileDecompressionAlgorithm = ""
+	dvResourceVirtualEnvironmentFileSourceFileDownloadOnNode         = false
+	dvResourceVirtualEnvironmentFileSourceFileFileName               = ""
+	dvResourceVirtualEnvironmentFileSourceFileInsecure               = false
+	dvResourceVirtualEnvironmentFileSourceRawResize                  = 0
+
+	mkResourceVirtualEnvironmentFileContentType                      = "content_type"
+	mkResourceVirtualEnvironmentFileDatastoreID                      = "datastore_id"
+	mkResourceVirtualEnvironmentFileFileModificationDate             = "file_modification_date"
+	mkResourceVirtualEnvironmentFileFileName                         = "file_name"
+	mkResourceVirtualEnvironmentFileFileSize                         = "file_size"
+	mkResourceVirtualEnvironmentFileFileTag                          = "file_tag"
+	mkResourceVirtualEnvironmentFileNodeName                         = "node_name"
+	mkResourceVirtualEnvironmentFileSourceFile                       = "source_file"
+	mkResourceVirtualEnvironmentFileSourceFilePath                   = "path"
+	mkResourceVirtualEnvironmentFileSourceFileChanged                = "changed"
+	mkResourceVirtualEnvironmentFileSourceFileChecksum               = "checksum"
+	mkResourceVirtualEnvironmentFileSourceFileChecksumAlgorithm      = "checksum_algorithm"
+	mkResourceVirtualEnvironmentFileSourceFileDecompressionAlgorithm = "decompression_algorithm"
+	mkResourceVirtualEnvironmentFileSourceFileDownloadOnNode         = "download_on_node"
+	mkResourceVirtualEnvironmentFileSourceFileFileName               = "file_name"
+	mkResourceVirtualEnvironmentFileSourceFileInsecure               = "insecure"
+	mkResourceVirtualEnvironmentFileSourceRaw                        = "source_raw"
+	mkResourceVirtualEnvironmentFileSourceRawData                    = "data"
+	mkResourceVirtualEnvironmentFileSourceRawFileName                = "file_name"
+	mkResourceVirtualEnvironmentFileSourceRawResize                  = "resize"
 )
 
 func resourceVirtualEnvironmentFile() *schema.Resource {
@@ -58,8 +67,8 @@ func resourceVirtualEnvironmentFile() *schema.Resource {
 				Type:         schema.TypeString,
 				Description:  "The content type",
 				Optional:     true,
+				Computed:     true,
 				ForceNew:     true,
-				Default:      dvResourceVirtualEnvironmentFileContentType,
 				ValidateFunc: getContentTypeValidator(),
 			},
 			mkResourceVirtualEnvironmentFileDatastoreID: {
@@ -98,10 +107,11 @@ func resourceVirtualEnvironmentFile() *schema.Resource {
 				ForceNew:    true,
 			},
 			mkResourceVirtualEnvironmentFileSourceFile: {
-				Type:        schema.TypeList,
-				Description: "The source file",
-				Optional:    true,
-				ForceNew:    true,
+				Type:             schema.TypeList,
+				Description:      "The source file",
+				Optional:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: resourceVirtualEnvironmentFileSuppressSourceDiff,
 				DefaultFunc: func() (interface{}, error) {
 					return make([]interface{}, 1), nil
 				},
@@ -122,11 +132,34 @@ func resourceVirtualEnvironmentFile() *schema.Resource {
 						},
 						mkResourceVirtualEnvironmentFileSourceFileChecksum: {
 							Type:        schema.TypeString,
-							Description: "The SHA256 checksum of the source file",
+							Description: "The checksum of the source file",
 							Optional:    true,
 							ForceNew:    true,
 							Default:     dvResourceVirtualEnvironmentFileSourceFileChecksum,
 						},
+						mkResourceVirtualEnvironmentFileSourceFileChecksumAlgorithm: {
+							Type:         schema.TypeString,
+							Description:  "The algorithm used to calculate the checksum of the source file",
+							Optional:     true,
+							ForceNew:     true,
+							Default:      dvResourceVirtualEnvironmentFileSourceFileChecksumAlgorithm,
+							ValidateFunc: getChecksumAlgorithmValidator(),
+						},
+						mkResourceVirtualEnvironmentFileSourceFileDecompressionAlgorithm: {
+							Type:         schema.TypeString,
+							Description:  "The algorithm used by the node to decompress the downloaded file",
+							Optional:     true,
+							ForceNew:     true,
+							Default:      dvResourceVirtualEnvironmentFileSourceFileDecompressionAlgorithm,
+							ValidateFunc: getDecompressionAlgorithmValidator(),
+						},
+						mkResourceVirtualEnvironmentFileSourceFileDownloadOnNode: {
+							Type:        schema.TypeBool,
+							Description: "Whether the node downloads the source file from the URL instead of the provider",
+							Optional:    true,
+							ForceNew:    true,
+							Default:     dvResourceVirtualEnvironmentFileSourceFileDownloadOnNode,
+						},
 						mkResourceVirtualEnvironmentFileSourceFileFileName: {
 							Type:        schema.TypeString,
 							Description: "The file name to use instead of the source file name",
@@ -147,10 +180,11 @@ func resourceVirtualEnvironmentFile() *schema.Resource {
 				MinItems: 0,
 			},
 			mkResourceVirtualEnvironmentFileSourceRaw: {
-				Type:        schema.TypeList,
-				Description: "The raw source",
-				Optional:    true,
-				ForceNew:    true,
+				Type:             schema.TypeList,
+				Description:      "The raw source",
+				Optional:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: resourceVirtualEnvironmentFileSuppressSourceDiff,
 				DefaultFunc: func() (interface{}, error) {
 					return make([]interface{}, 1), nil
 				},
@@ -184,6 +218,9 @@ func resourceVirtualEnvironmentFile() *schema.Resource {
 		Create: resourceVirtualEnvironmentFileCreate,
 		Read:   resourceVirtualEnvironmentFileRead,
 		Delete: resourceVirtualEnvironmentFileDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceVirtualEnvironmentFileImport,
+		},
 	}
 }
 
@@ -212,6 +249,8 @@ func resourceVirtualEnvironmentFileCreate(d *schema.ResourceData, m interface{})
 	sourceFile := d.Get(mkResourceVirtualEnvironmentFileSourceFile).([]interface{})
 	sourceRaw := d.Get(mkResourceVirtualEnvironmentFileSourceRaw).([]interface{})
 
+	sourceFileChecksum := ""
+	sourceFileChecksumAlgorithm := ""
 	sourceFilePathLocal := ""
 
 	// Determine if both source_data and source_file is specified as this is not supported.
@@ -230,9 +269,26 @@ func resourceVirtualEnvironmentFileCreate(d *schema.ResourceData, m interface{})
 	if len(sourceFile) > 0 {
 		sourceFileBlock := sourceFile[0].(map[string]interface{})
 		sourceFilePath := sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFilePath].(string)
-		sourceFileChecksum := sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFileChecksum].(string)
+		sourceFileChecksum = sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFileChecksum].(string)
+		sourceFileChecksumAlgorithm = sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFileChecksumAlgorithm].(string)
+		sourceFileDecompressionAlgorithm := sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFileDecompressionAlgorithm].(string)
+		sourceFileDownloadOnNode := sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFileDownloadOnNode].(bool)
 		sourceFileInsecure := sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFileInsecure].(bool)
 
+		if sourceFileDownloadOnNode {
+			return resourceVirtualEnvironmentFileCreateDownload(d, m, *contentType, *fileName)
+		}
+
+		if sourceFileDecompressionAlgorithm != "" {
+			return fmt.Errorf(
+				"The argument \"%s.%s\" requires \"%s.%s\" to be enabled",
+				mkResourceVirtualEnvironmentFileSourceFile,
+				mkResourceVirtualEnvironmentFileSourceFileDecompressionAlgorithm,
+				mkResourceVirtualEnvironmentFileSourceFile,
+				mkResourceVirtualEnvironmentFileSourceFileDownloadOnNode,
+			)
+		}
+
 		if resourceVirtualEnvironmentFileIsURL(d, m) {
 			log.Printf("[DEBUG] Downloading file from '%s'", sourceFilePath)
 
@@ -284,7 +340,14 @@ func resourceVirtualEnvironmentFileCreate(d *schema.ResourceData, m interface{})
 				return err
 			}
 
-			h := sha256.New()
+			h, err := resourceVirtualEnvironmentFileGetChecksumHash(sourceFileChecksumAlgorithm)
+
+			if err != nil {
+				file.Close()
+
+				return err
+			}
+
 			_, err = io.Copy(h, file)
 
 			if err != nil {
@@ -297,10 +360,10 @@ func resourceVirtualEnvironmentFileCreate(d *schema.ResourceData, m interface{})
 
 			calculatedChecksum := fmt.Sprintf("%x", h.Sum(nil))
 
-			log.Printf("[DEBUG] The calculated SHA256 checksum for source \"%s\" is \"%s\"", sourceFilePath, calculatedChecksum)
+			log.Printf("[DEBUG] The calculated %s checksum for source \"%s\" is \"%s\"", strings.ToUpper(sourceFileChecksumAlgorithm), sourceFilePath, calculatedChecksum)
 
-			if sourceFileChecksum != calculatedChecksum {
-				return fmt.Errorf("The calculated SHA256 checksum \"%s\" does not match source checksum \"%s\"", calculatedChecksum, sourceFileChecksum)
+			if !strings.EqualFold(sourceFileChecksum, calculatedChecksum) {
+				return fmt.Errorf("The calculated %s checksum \"%s\" does not match source checksum \"%s\"", strings.ToUpper(sourceFileChecksumAlgorithm), calculatedChecksum, sourceFileChecksum)
 			}
 		}
 	} else if len(sourceRaw) > 0 {
@@ -354,15 +417,26 @@ func resourceVirtualEnvironmentFileCreate(d *schema.ResourceData, m interface{})
 
 	defer file.Close()
 
+	fileInfo, err := file.Stat()
+
+	if err != nil {
+		return err
+	}
+
+	fileSize := fileInfo.Size()
+
 	body := &proxmox.VirtualEnvironmentDatastoreUploadRequestBody{
-		ContentType: *contentType,
-		DatastoreID: datastoreID,
-		FileName:    *fileName,
-		FileReader:  file,
-		NodeName:    nodeName,
+		Checksum:          sourceFileChecksum,
+		ChecksumAlgorithm: sourceFileChecksumAlgorithm,
+		ContentType:       *contentType,
+		DatastoreID:       datastoreID,
+		FileName:          *fileName,
+		FileReader:        file,
+		FileSize:          &fileSize,
+		NodeName:          nodeName,
 	}
 
-	_, err = veClient.UploadFileToDatastore(body)
+	_, err = veClient.UploadFileToDatastoreWithContext(config.stopContext, body)
 
 	if err != nil {
 		return err
@@ -379,6 +453,103 @@ func resourceVirtualEnvironmentFileCreate(d *schema.ResourceData, m interface{})
 	return resourceVirtualEnvironmentFileRead(d, m)
 }
 
+func resourceVirtualEnvironmentFileCreateDownload(d *schema.ResourceData, m interface{}, contentType string, fileName string) error {
+	config := m.(providerConfiguration)
+	veClient, err := config.GetVEClient()
+
+	if err != nil {
+		return err
+	}
+
+	if !resourceVirtualEnvironmentFileIsURL(d, m) {
+		return fmt.Errorf(
+			"The argument \"%s.%s\" requires \"%s.%s\" to be a URL",
+			mkResourceVirtualEnvironmentFileSourceFile,
+			mkResourceVirtualEnvironmentFileSourceFileDownloadOnNode,
+			mkResourceVirtualEnvironmentFileSourceFile,
+			mkResourceVirtualEnvironmentFileSourceFilePath,
+		)
+	}
+
+	if contentType != "iso" && contentType != "vztmpl" {
+		return fmt.Errorf(
+			"The argument \"%s.%s\" is only supported for the content types \"iso\" and \"vztmpl\" - got: \"%s\"",
+			mkResourceVirtualEnvironmentFileSourceFile,
+			mkResourceVirtualEnvironmentFileSourceFileDownloadOnNode,
+			contentType,
+		)
+	}
+
+	datastoreID := d.Get(mkResourceVirtualEnvironmentFileDatastoreID).(string)
+	nodeName := d.Get(mkResourceVirtualEnvironmentFileNodeName).(string)
+	sourceFile := d.Get(mkResourceVirtualEnvironmentFileSourceFile).([]interface{})
+	sourceFileBlock := sourceFile[0].(map[string]interface{})
+	sourceFileChecksum := sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFileChecksum].(string)
+	sourceFileChecksumAlgorithm := sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFileChecksumAlgorithm].(string)
+	sourceFileDecompressionAlgorithm := sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFileDecompressionAlgorithm].(string)
+	sourceFileInsecure := proxmox.CustomBool(!sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFileInsecure].(bool))
+	sourceFilePath := sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFilePath].(string)
+
+	body := &proxmox.VirtualEnvironmentDatastoreDownloadURLRequestBody{
+		ContentType:        contentType,
+		FileName:           fileName,
+		URL:                sourceFilePath,
+		VerifyCertificates: &sourceFileInsecure,
+	}
+
+	if sourceFileChecksum != "" {
+		body.Checksum = &sourceFileChecksum
+		body.ChecksumAlgorithm = &sourceFileChecksumAlgorithm
+	}
+
+	if sourceFileDecompressionAlgorithm != "" {
+		body.DecompressionAlgorithm = &sourceFileDecompressionAlgorithm
+	}
+
+	log.Printf("[DEBUG] Instructing node \"%s\" to download file from '%s'", nodeName, sourceFilePath)
+
+	taskID, err := veClient.DownloadFileToDatastoreWithContext(config.stopContext, nodeName, datastoreID, body)
+
+	if err != nil {
+		return err
+	}
+
+	err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, 3600, 5)
+
+	if err != nil {
+		return err
+	}
+
+	volumeID, err := resourceVirtualEnvironmentFileGetVolumeID(d, m)
+
+	if err != nil {
+		return err
+	}
+
+	d.SetId(*volumeID)
+
+	return resourceVirtualEnvironmentFileRead(d, m)
+}
+
+func resourceVirtualEnvironmentFileGetChecksumHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha224":
+		return sha256.New224(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha384":
+		return sha512.New384(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("Unsupported checksum algorithm \"%s\"", algorithm)
+	}
+}
+
 func resourceVirtualEnvironmentFileGetContentType(d *schema.ResourceData, m interface{}) (*string, error) {
 	contentType := d.Get(mkResourceVirtualEnvironmentFileContentType).(string)
 	sourceFile := d.Get(mkResourceVirtualEnvironmentFileSourceFile).([]interface{})
@@ -498,6 +669,26 @@ func resourceVirtualEnvironmentFileGetVolumeID(d *schema.ResourceData, m interfa
 	return &volumeID, nil
 }
 
+func resourceVirtualEnvironmentFileImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	id := strings.SplitN(d.Id(), "/", 3)
+
+	if len(id) != 3 || id[0] == "" || id[1] == "" || id[2] == "" {
+		return nil, fmt.Errorf("Invalid import ID \"%s\" (expected \"<node_name>/<datastore_id>/<volume_id>\")", d.Id())
+	}
+
+	volumeID := id[2]
+
+	if !strings.Contains(volumeID, ":") {
+		volumeID = fmt.Sprintf("%s:%s", id[1], volumeID)
+	}
+
+	d.Set(mkResourceVirtualEnvironmentFileDatastoreID, id[1])
+	d.Set(mkResourceVirtualEnvironmentFileNodeName, id[0])
+	d.SetId(volumeID)
+
+	return []*schema.ResourceData{d}, nil
+}
+
 func resourceVirtualEnvironmentFileIsURL(d *schema.ResourceData, m interface{}) bool {
 	sourceFile := d.Get(mkResourceVirtualEnvironmentFileSourceFile).([]interface{})
 	sourceFilePath := ""
@@ -525,28 +716,33 @@ func resourceVirtualEnvironmentFileRead(d *schema.ResourceData, m interface{}) e
 	sourceFile := d.Get(mkResourceVirtualEnvironmentFileSourceFile).([]interface{})
 	sourceFilePath := ""
 
-	if len(sourceFile) == 0 {
-		return nil
-	}
-
-	sourceFileBlock := sourceFile[0].(map[string]interface{})
-	sourceFilePath = sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFilePath].(string)
-
 	list, err := veClient.ListDatastoreFiles(nodeName, datastoreID)
 
 	if err != nil {
-		return err
-	}
+		if proxmox.IsNotFound(err) {
+			d.SetId("")
 
-	fileIsURL := resourceVirtualEnvironmentFileIsURL(d, m)
-	fileName, err := resourceVirtualEnvironmentFileGetFileName(d, m)
+			return nil
+		}
 
-	if err != nil {
 		return err
 	}
 
 	for _, v := range list {
 		if v.VolumeID == d.Id() {
+			d.Set(mkResourceVirtualEnvironmentFileContentType, v.ContentType)
+			d.Set(mkResourceVirtualEnvironmentFileFileName, filepath.Base(strings.TrimPrefix(v.VolumeID, datastoreID+":")))
+
+			// The source of a file cannot be determined from the API, which is why the remaining attributes are only
+			// available when the file is managed through a source file.
+			if len(sourceFile) == 0 || sourceFile[0] == nil {
+				return nil
+			}
+
+			sourceFileBlock := sourceFile[0].(map[string]interface{})
+			sourceFilePath = sourceFileBlock[mkResourceVirtualEnvironmentFileSourceFilePath].(string)
+			fileIsURL := resourceVirtualEnvironmentFileIsURL(d, m)
+
 			var fileModificationDate string
 			var fileSize int64
 			var fileTag string
@@ -617,7 +813,6 @@ func resourceVirtualEnvironmentFileRead(d *schema.ResourceData, m interface{}) e
 			lastFileTag := d.Get(mkResourceVirtualEnvironmentFileFileTag).(string)
 
 			d.Set(mkResourceVirtualEnvironmentFileFileModificationDate, fileModificationDate)
-			d.Set(mkResourceVirtualEnvironmentFileFileName, *fileName)
 			d.Set(mkResourceVirtualEnvironmentFileFileSize, fileSize)
 			d.Set(mkResourceVirtualEnvironmentFileFileTag, fileTag)
 			d.Set(mkResourceVirtualEnvironmentFileSourceFileChanged, lastFileModificationDate != fileModificationDate || lastFileSize != fileSize || lastFileTag != fileTag)
@@ -631,6 +826,19 @@ func resourceVirtualEnvironmentFileRead(d *schema.ResourceData, m interface{}) e
 	return nil
 }
 
+// resourceVirtualEnvironmentFileSuppressSourceDiff suppresses the differences between the source blocks of an imported
+// file and its configuration, as the source of an existing file cannot be determined from the API.
+func resourceVirtualEnvironmentFileSuppressSourceDiff(k, old, new string, d *schema.ResourceData) bool {
+	if d.Id() == "" {
+		return false
+	}
+
+	oldSourceFile, _ := d.GetChange(mkResourceVirtualEnvironmentFileSourceFile)
+	oldSourceRaw, _ := d.GetChange(mkResourceVirtualEnvironmentFileSourceRaw)
+
+	return len(oldSourceFile.([]interface{})) == 0 && len(oldSourceRaw.([]interface{})) == 0
+}
+
 func resourceVirtualEnvironmentFileDelete(d *schema.ResourceData, m interface{}) error {
 	config := m.(providerConfiguration)
 	veClient, err := config.GetVEClient()
@@ -645,7 +853,7 @@ func resourceVirtualEnvironmentFileDelete(d *schema.ResourceData, m interface{})
 	err = veClient.DeleteDatastoreFile(nodeName, datastoreID, d.Id())
 
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") {
+		if proxmox.IsNotFound(err) {
 			d.SetId("")
 
 			return nil
@@ -7,6 +7,7 @@ package proxmoxtf
 import (
 	"testing"
 
+	"github.com/danitso/terraform-provider-proxmox/proxmoxtest"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
@@ -62,16 +63,22 @@ func TestResourceVirtualEnvironmentFileSchema(t *testing.T) {
 	testOptionalArguments(t, sourceFileSchema, []string{
 		mkResourceVirtualEnvironmentFileSourceFileChanged,
 		mkResourceVirtualEnvironmentFileSourceFileChecksum,
+		mkResourceVirtualEnvironmentFileSourceFileChecksumAlgorithm,
+		mkResourceVirtualEnvironmentFileSourceFileDecompressionAlgorithm,
+		mkResourceVirtualEnvironmentFileSourceFileDownloadOnNode,
 		mkResourceVirtualEnvironmentFileSourceFileFileName,
 		mkResourceVirtualEnvironmentFileSourceFileInsecure,
 	})
 
 	testValueTypes(t, sourceFileSchema, map[string]schema.ValueType{
-		mkResourceVirtualEnvironmentFileSourceFileChanged:  schema.TypeBool,
-		mkResourceVirtualEnvironmentFileSourceFileChecksum: schema.TypeString,
-		mkResourceVirtualEnvironmentFileSourceFileFileName: schema.TypeString,
-		mkResourceVirtualEnvironmentFileSourceFileInsecure: schema.TypeBool,
-		mkResourceVirtualEnvironmentFileSourceFilePath:     schema.TypeString,
+		mkResourceVirtualEnvironmentFileSourceFileChanged:                schema.TypeBool,
+		mkResourceVirtualEnvironmentFileSourceFileChecksum:               schema.TypeString,
+		mkResourceVirtualEnvironmentFileSourceFileChecksumAlgorithm:      schema.TypeString,
+		mkResourceVirtualEnvironmentFileSourceFileDecompressionAlgorithm: schema.TypeString,
+		mkResourceVirtualEnvironmentFileSourceFileDownloadOnNode:         schema.TypeBool,
+		mkResourceVirtualEnvironmentFileSourceFileFileName:               schema.TypeString,
+		mkResourceVirtualEnvironmentFileSourceFileInsecure:               schema.TypeBool,
+		mkResourceVirtualEnvironmentFileSourceFilePath:                   schema.TypeString,
 	})
 
 	sourceRawSchema := testNestedSchemaExistence(t, s, mkResourceVirtualEnvironmentFileSourceRaw)
@@ -91,3 +98,61 @@ func TestResourceVirtualEnvironmentFileSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentFileSourceRawResize:   schema.TypeInt,
 	})
 }
+
+// TestResourceVirtualEnvironmentFileImport tests whether an existing file can be imported.
+func TestResourceVirtualEnvironmentFileImport(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	server.Do(func(state *proxmoxtest.State) {
+		state.Datastores["local"].Files["local:iso/test.iso"] = &proxmoxtest.DatastoreFile{
+			ContentType: "iso",
+			FileFormat:  "iso",
+			FileSize:    1024,
+		}
+	})
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentFile()
+
+	for _, id := range []string{"pve/local/iso/test.iso", "pve/local/local:iso/test.iso"} {
+		d := s.Data(nil)
+		d.SetId(id)
+
+		imported, err := s.Importer.State(d, config)
+
+		if err != nil {
+			t.Fatalf("Failed to import file \"%s\": %s", id, err.Error())
+		}
+
+		d = imported[0]
+		err = s.Read(d, config)
+
+		if err != nil {
+			t.Fatalf("Failed to read file \"%s\": %s", id, err.Error())
+		}
+
+		if d.Id() != "local:iso/test.iso" || d.Get(mkResourceVirtualEnvironmentFileDatastoreID).(string) != "local" || d.Get(mkResourceVirtualEnvironmentFileNodeName).(string) != proxmoxtest.DefaultNodeName {
+			t.Fatalf("Expected the file \"%s\" to be imported as \"local:iso/test.iso\" - got: %s", id, d.Id())
+		}
+
+		if d.Get(mkResourceVirtualEnvironmentFileContentType).(string) != "iso" || d.Get(mkResourceVirtualEnvironmentFileFileName).(string) != "test.iso" {
+			t.Fatalf("Expected the content type and file name to be read from the datastore - got: %v and %v", d.Get(mkResourceVirtualEnvironmentFileContentType), d.Get(mkResourceVirtualEnvironmentFileFileName))
+		}
+	}
+
+	d := s.Data(nil)
+	d.SetId("pve/local/iso/missing.iso")
+
+	imported, err := s.Importer.State(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to import file: %s", err.Error())
+	}
+
+	err = s.Read(imported[0], config)
+
+	if err != nil || imported[0].Id() != "" {
+		t.Fatalf("Expected a missing file to be removed from the state - got: %v", err)
+	}
+}
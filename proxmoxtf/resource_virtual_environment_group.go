@@ -5,8 +5,6 @@
 package proxmoxtf
 
 import (
-	"strings"
-
 	"github.com/danitso/terraform-provider-proxmox/proxmox"
 	"github.com/hashicorp/terraform/helper/schema"
 )
@@ -77,6 +75,9 @@ func resourceVirtualEnvironmentGroup() *schema.Resource {
 		Read:   resourceVirtualEnvironmentGroupRead,
 		Update: resourceVirtualEnvironmentGroupUpdate,
 		Delete: resourceVirtualEnvironmentGroupDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
 	}
 }
 
@@ -141,7 +142,7 @@ func resourceVirtualEnvironmentGroupRead(d *schema.ResourceData, m interface{})
 	group, err := veClient.GetGroup(groupID)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") {
+		if proxmox.IsNotFound(err) {
 			d.SetId("")
 
 			return nil
@@ -150,6 +151,8 @@ func resourceVirtualEnvironmentGroupRead(d *schema.ResourceData, m interface{})
 		return err
 	}
 
+	d.Set(mkResourceVirtualEnvironmentGroupID, groupID)
+
 	acl, err := veClient.GetACL()
 
 	if err != nil {
@@ -292,7 +295,7 @@ func resourceVirtualEnvironmentGroupDelete(d *schema.ResourceData, m interface{}
 	err = veClient.DeleteGroup(groupID)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") {
+		if proxmox.IsNotFound(err) {
 			d.SetId("")
 
 			return nil
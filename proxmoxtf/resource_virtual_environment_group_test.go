@@ -7,6 +7,7 @@ package proxmoxtf
 import (
 	"testing"
 
+	"github.com/danitso/terraform-provider-proxmox/proxmoxtest"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
@@ -60,3 +61,102 @@ func TestResourceVirtualEnvironmentGroupSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentGroupACLRoleID:    schema.TypeString,
 	})
 }
+
+// TestResourceVirtualEnvironmentGroupLifecycle tests whether a group can be created, read, updated and deleted, and
+// whether changes made outside of Terraform are detected.
+func TestResourceVirtualEnvironmentGroupLifecycle(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentGroup()
+
+	d := schema.TestResourceDataRaw(t, s.Schema, map[string]interface{}{
+		mkResourceVirtualEnvironmentGroupACL: []interface{}{
+			map[string]interface{}{
+				mkResourceVirtualEnvironmentGroupACLPath:      "/vms",
+				mkResourceVirtualEnvironmentGroupACLPropagate: true,
+				mkResourceVirtualEnvironmentGroupACLRoleID:    "PVEAuditor",
+			},
+		},
+		mkResourceVirtualEnvironmentGroupComment: "Managed by Terraform",
+		mkResourceVirtualEnvironmentGroupID:      "test",
+	})
+
+	err := s.Create(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to create group: %s", err.Error())
+	}
+
+	if d.Id() != "test" {
+		t.Fatalf("Expected the ID to be \"test\" - got: \"%s\"", d.Id())
+	}
+
+	if d.Get(mkResourceVirtualEnvironmentGroupACL).(*schema.Set).Len() != 1 {
+		t.Fatalf("Expected the group to have a single ACL entry")
+	}
+
+	// The comment and the membership are changed outside of Terraform, which must be detected by the next read.
+	server.Do(func(state *proxmoxtest.State) {
+		state.Groups["test"].Comment = "Changed"
+		state.Users["root@pam"].Groups = []string{"test"}
+	})
+
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read group: %s", err.Error())
+	}
+
+	if d.Get(mkResourceVirtualEnvironmentGroupComment).(string) != "Changed" {
+		t.Fatalf("Expected the comment to be \"Changed\" - got: \"%s\"", d.Get(mkResourceVirtualEnvironmentGroupComment).(string))
+	}
+
+	if !d.Get(mkResourceVirtualEnvironmentGroupMembers).(*schema.Set).Contains("root@pam") {
+		t.Fatalf("Expected the group members to include \"root@pam\"")
+	}
+
+	d.Set(mkResourceVirtualEnvironmentGroupComment, "Updated")
+
+	err = s.Update(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to update group: %s", err.Error())
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		if state.Groups["test"].Comment != "Updated" {
+			t.Fatalf("Expected the comment to be \"Updated\" - got: \"%s\"", state.Groups["test"].Comment)
+		}
+	})
+
+	err = s.Delete(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to delete group: %s", err.Error())
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		if _, ok := state.Groups["test"]; ok {
+			t.Fatalf("Expected the group to be deleted")
+		}
+
+		if len(state.ACL) != 0 {
+			t.Fatalf("Expected the ACL entries of the group to be deleted - got: %d entries", len(state.ACL))
+		}
+	})
+
+	// A group, which has been deleted outside of Terraform, must be removed from the state.
+	d.SetId("test")
+
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read deleted group: %s", err.Error())
+	}
+
+	if d.Id() != "" {
+		t.Fatalf("Expected the ID to be cleared for a deleted group - got: \"%s\"", d.Id())
+	}
+}
@@ -100,6 +100,9 @@ func resourceVirtualEnvironmentHosts() *schema.Resource {
 		Read:   resourceVirtualEnvironmentHostsRead,
 		Update: resourceVirtualEnvironmentHostsUpdate,
 		Delete: resourceVirtualEnvironmentHostsDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceVirtualEnvironmentHostsImport,
+		},
 	}
 }
 
@@ -117,6 +120,19 @@ func resourceVirtualEnvironmentHostsCreate(d *schema.ResourceData, m interface{}
 	return nil
 }
 
+func resourceVirtualEnvironmentHostsImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	nodeName := strings.TrimSuffix(d.Id(), "_hosts")
+
+	if nodeName == "" {
+		return nil, fmt.Errorf("Invalid import ID \"%s\" (expected \"<node_name>\")", d.Id())
+	}
+
+	d.Set(mkResourceVirtualEnvironmentHostsNodeName, nodeName)
+	d.SetId(fmt.Sprintf("%s_hosts", nodeName))
+
+	return []*schema.ResourceData{d}, nil
+}
+
 func resourceVirtualEnvironmentHostsRead(d *schema.ResourceData, m interface{}) error {
 	config := m.(providerConfiguration)
 	veClient, err := config.GetVEClient()
@@ -5,8 +5,10 @@
 package proxmoxtf
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/danitso/terraform-provider-proxmox/proxmoxtest"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
@@ -68,3 +70,60 @@ func TestResourceVirtualEnvironmentHostsSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentHostsEntryHostnames: schema.TypeList,
 	})
 }
+
+// TestResourceVirtualEnvironmentHostsImport tests whether the hosts file of a node can be imported.
+func TestResourceVirtualEnvironmentHostsImport(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentHosts()
+
+	for _, id := range []string{proxmoxtest.DefaultNodeName, fmt.Sprintf("%s_hosts", proxmoxtest.DefaultNodeName)} {
+		d := s.Data(nil)
+		d.SetId(id)
+
+		imported, err := s.Importer.State(d, config)
+
+		if err != nil {
+			t.Fatalf("Failed to import hosts file: %s", err.Error())
+		}
+
+		d = imported[0]
+		err = s.Read(d, config)
+
+		if err != nil {
+			t.Fatalf("Failed to read hosts file: %s", err.Error())
+		}
+
+		if d.Id() != fmt.Sprintf("%s_hosts", proxmoxtest.DefaultNodeName) || d.Get(mkResourceVirtualEnvironmentHostsNodeName) != proxmoxtest.DefaultNodeName {
+			t.Fatalf("Expected the hosts file of node \"%s\" to be imported - got: %s", proxmoxtest.DefaultNodeName, d.Id())
+		}
+
+		entries := d.Get(mkResourceVirtualEnvironmentHostsEntry).([]interface{})
+
+		if len(entries) != 2 {
+			t.Fatalf("Expected 2 entries - got: %v", entries)
+		}
+
+		entry := entries[1].(map[string]interface{})
+		hostnames := entry[mkResourceVirtualEnvironmentHostsEntryHostnames].([]interface{})
+
+		if entry[mkResourceVirtualEnvironmentHostsEntryAddress] != proxmoxtest.DefaultNodeAddress || len(hostnames) != 2 || hostnames[1] != proxmoxtest.DefaultNodeName {
+			t.Fatalf("Expected the entry for the node to be imported - got: %v", entry)
+		}
+
+		if d.Get(mkResourceVirtualEnvironmentHostsDigest) == "" {
+			t.Fatalf("Expected the digest to be imported")
+		}
+	}
+
+	d := s.Data(nil)
+	d.SetId("_hosts")
+
+	_, err := s.Importer.State(d, config)
+
+	if err == nil {
+		t.Fatalf("Expected an error for an import ID without a node name")
+	}
+}
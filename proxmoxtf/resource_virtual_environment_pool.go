@@ -5,8 +5,6 @@
 package proxmoxtf
 
 import (
-	"strings"
-
 	"github.com/danitso/terraform-provider-proxmox/proxmox"
 	"github.com/hashicorp/terraform/helper/schema"
 )
@@ -78,6 +76,9 @@ func resourceVirtualEnvironmentPool() *schema.Resource {
 		Read:   resourceVirtualEnvironmentPoolRead,
 		Update: resourceVirtualEnvironmentPoolUpdate,
 		Delete: resourceVirtualEnvironmentPoolDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
 	}
 }
 
@@ -120,7 +121,7 @@ func resourceVirtualEnvironmentPoolRead(d *schema.ResourceData, m interface{}) e
 	pool, err := veClient.GetPool(poolID)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") {
+		if proxmox.IsNotFound(err) {
 			d.SetId("")
 
 			return nil
@@ -129,6 +130,8 @@ func resourceVirtualEnvironmentPoolRead(d *schema.ResourceData, m interface{}) e
 		return err
 	}
 
+	d.Set(mkResourceVirtualEnvironmentPoolPoolID, poolID)
+
 	if pool.Comment != nil {
 		d.Set(mkResourceVirtualEnvironmentPoolComment, pool.Comment)
 	} else {
@@ -201,7 +204,7 @@ func resourceVirtualEnvironmentPoolDelete(d *schema.ResourceData, m interface{})
 	err = veClient.DeletePool(poolID)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") {
+		if proxmox.IsNotFound(err) {
 			d.SetId("")
 
 			return nil
@@ -7,6 +7,7 @@ package proxmoxtf
 import (
 	"testing"
 
+	"github.com/danitso/terraform-provider-proxmox/proxmoxtest"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
@@ -53,3 +54,60 @@ func TestResourceVirtualEnvironmentPoolSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentPoolMembersVMID:        schema.TypeInt,
 	})
 }
+
+// TestResourceVirtualEnvironmentPoolImport tests whether an existing pool can be imported.
+func TestResourceVirtualEnvironmentPoolImport(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	server.Do(func(state *proxmoxtest.State) {
+		state.Guests[100] = &proxmoxtest.Guest{
+			Config:   map[string]string{},
+			NodeName: proxmoxtest.DefaultNodeName,
+			PoolID:   "test",
+			Status:   "stopped",
+			Type:     "qemu",
+		}
+		state.Pools["test"] = &proxmoxtest.Pool{
+			Comment: "Test pool",
+		}
+	})
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentPool()
+
+	d := s.Data(nil)
+	d.SetId("test")
+
+	imported, err := s.Importer.State(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to import pool: %s", err.Error())
+	}
+
+	d = imported[0]
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read pool: %s", err.Error())
+	}
+
+	if d.Get(mkResourceVirtualEnvironmentPoolPoolID) != "test" || d.Get(mkResourceVirtualEnvironmentPoolComment) != "Test pool" {
+		t.Fatalf("Expected the pool to be imported - got: %v", d.State().Attributes)
+	}
+
+	members := d.Get(mkResourceVirtualEnvironmentPoolMembers).([]interface{})
+
+	if len(members) != 1 {
+		t.Fatalf("Expected 1 member - got: %v", members)
+	}
+
+	member := members[0].(map[string]interface{})
+
+	if member[mkResourceVirtualEnvironmentPoolMembersID] != "qemu/100" ||
+		member[mkResourceVirtualEnvironmentPoolMembersNodeName] != proxmoxtest.DefaultNodeName ||
+		member[mkResourceVirtualEnvironmentPoolMembersType] != "qemu" ||
+		member[mkResourceVirtualEnvironmentPoolMembersVMID] != 100 {
+		t.Fatalf("Expected the VM to be imported as a member - got: %v", member)
+	}
+}
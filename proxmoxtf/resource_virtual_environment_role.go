@@ -5,8 +5,6 @@
 package proxmoxtf
 
 import (
-	"strings"
-
 	"github.com/danitso/terraform-provider-proxmox/proxmox"
 	"github.com/hashicorp/terraform/helper/schema"
 )
@@ -36,6 +34,9 @@ func resourceVirtualEnvironmentRole() *schema.Resource {
 		Read:   resourceVirtualEnvironmentRoleRead,
 		Update: resourceVirtualEnvironmentRoleUpdate,
 		Delete: resourceVirtualEnvironmentRoleDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
 	}
 }
 
@@ -83,7 +84,7 @@ func resourceVirtualEnvironmentRoleRead(d *schema.ResourceData, m interface{}) e
 	role, err := veClient.GetRole(roleID)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") {
+		if proxmox.IsNotFound(err) {
 			d.SetId("")
 
 			return nil
@@ -92,6 +93,8 @@ func resourceVirtualEnvironmentRoleRead(d *schema.ResourceData, m interface{}) e
 		return err
 	}
 
+	d.Set(mkResourceVirtualEnvironmentRoleRoleID, roleID)
+
 	privileges := schema.NewSet(schema.HashString, []interface{}{})
 
 	if *role != nil {
@@ -146,7 +149,7 @@ func resourceVirtualEnvironmentRoleDelete(d *schema.ResourceData, m interface{})
 	err = veClient.DeleteRole(roleID)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") {
+		if proxmox.IsNotFound(err) {
 			d.SetId("")
 
 			return nil
@@ -7,6 +7,7 @@ package proxmoxtf
 import (
 	"testing"
 
+	"github.com/danitso/terraform-provider-proxmox/proxmoxtest"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
@@ -33,3 +34,44 @@ func TestResourceVirtualEnvironmentRoleSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentRoleRoleID:     schema.TypeString,
 	})
 }
+
+// TestResourceVirtualEnvironmentRoleImport tests whether an existing role can be imported.
+func TestResourceVirtualEnvironmentRoleImport(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	server.Do(func(state *proxmoxtest.State) {
+		state.Roles["Test"] = &proxmoxtest.Role{
+			Privileges: []string{"VM.Audit", "VM.PowerMgmt"},
+		}
+	})
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentRole()
+
+	d := s.Data(nil)
+	d.SetId("Test")
+
+	imported, err := s.Importer.State(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to import role: %s", err.Error())
+	}
+
+	d = imported[0]
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read role: %s", err.Error())
+	}
+
+	if d.Get(mkResourceVirtualEnvironmentRoleRoleID) != "Test" {
+		t.Fatalf("Expected the role identifier to be \"Test\" - got: %v", d.Get(mkResourceVirtualEnvironmentRoleRoleID))
+	}
+
+	privileges := d.Get(mkResourceVirtualEnvironmentRolePrivileges).(*schema.Set)
+
+	if privileges.Len() != 2 || !privileges.Contains("VM.Audit") || !privileges.Contains("VM.PowerMgmt") {
+		t.Fatalf("Expected the privileges to be imported - got: %v", privileges.List())
+	}
+}
@@ -6,7 +6,9 @@ package proxmoxtf
 
 import (
 	"testing"
+	"time"
 
+	"github.com/danitso/terraform-provider-proxmox/proxmoxtest"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
@@ -71,3 +73,91 @@ func TestResourceVirtualEnvironmentUserSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentUserACLRoleID:    schema.TypeString,
 	})
 }
+
+// TestResourceVirtualEnvironmentUserImport tests whether an existing user can be imported.
+func TestResourceVirtualEnvironmentUserImport(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	expirationDate := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	server.Do(func(state *proxmoxtest.State) {
+		state.ACL = append(state.ACL, &proxmoxtest.ACLEntry{
+			Path:          "/vms",
+			Propagate:     true,
+			RoleID:        "PVEAuditor",
+			Type:          "user",
+			UserOrGroupID: "test@pve",
+		})
+		state.Groups["auditors"] = &proxmoxtest.Group{}
+		state.Users["test@pve"] = &proxmoxtest.User{
+			Comment:        "Test user",
+			Email:          "test@example.com",
+			Enabled:        false,
+			ExpirationDate: expirationDate.Unix(),
+			FirstName:      "First",
+			Groups:         []string{"auditors"},
+			Keys:           "x",
+			LastName:       "Last",
+		}
+	})
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentUser()
+
+	d := s.Data(nil)
+	d.SetId("test@pve")
+
+	imported, err := s.Importer.State(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to import user: %s", err.Error())
+	}
+
+	d = imported[0]
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read user: %s", err.Error())
+	}
+
+	for k, v := range map[string]interface{}{
+		mkResourceVirtualEnvironmentUserComment:   "Test user",
+		mkResourceVirtualEnvironmentUserEmail:     "test@example.com",
+		mkResourceVirtualEnvironmentUserEnabled:   false,
+		mkResourceVirtualEnvironmentUserFirstName: "First",
+		mkResourceVirtualEnvironmentUserKeys:      "x",
+		mkResourceVirtualEnvironmentUserLastName:  "Last",
+		mkResourceVirtualEnvironmentUserUserID:    "test@pve",
+	} {
+		if d.Get(k) != v {
+			t.Fatalf("Expected argument \"%s\" to be \"%v\" - got: %v", k, v, d.Get(k))
+		}
+	}
+
+	importedExpirationDate, err := time.Parse(time.RFC3339, d.Get(mkResourceVirtualEnvironmentUserExpirationDate).(string))
+
+	if err != nil || !importedExpirationDate.Equal(expirationDate) {
+		t.Fatalf("Expected the expiration date to be %s - got: %v", expirationDate.Format(time.RFC3339), d.Get(mkResourceVirtualEnvironmentUserExpirationDate))
+	}
+
+	groups := d.Get(mkResourceVirtualEnvironmentUserGroups).(*schema.Set)
+
+	if groups.Len() != 1 || !groups.Contains("auditors") {
+		t.Fatalf("Expected the groups to be imported - got: %v", groups.List())
+	}
+
+	acl := d.Get(mkResourceVirtualEnvironmentUserACL).(*schema.Set).List()
+
+	if len(acl) != 1 {
+		t.Fatalf("Expected 1 ACL entry - got: %v", acl)
+	}
+
+	aclEntry := acl[0].(map[string]interface{})
+
+	if aclEntry[mkResourceVirtualEnvironmentUserACLPath] != "/vms" ||
+		aclEntry[mkResourceVirtualEnvironmentUserACLPropagate] != true ||
+		aclEntry[mkResourceVirtualEnvironmentUserACLRoleID] != "PVEAuditor" {
+		t.Fatalf("Expected the ACL entry to be imported - got: %v", aclEntry)
+	}
+}
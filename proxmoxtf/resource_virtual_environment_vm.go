@@ -7,6 +7,8 @@ package proxmoxtf
 import (
 	"fmt"
 	"math"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -45,6 +47,17 @@ const (
 	dvResourceVirtualEnvironmentVMDiskSpeedReadBurstable            = 0
 	dvResourceVirtualEnvironmentVMDiskSpeedWrite                    = 0
 	dvResourceVirtualEnvironmentVMDiskSpeedWriteBurstable           = 0
+	dvResourceVirtualEnvironmentVMEFIDiskDatastoreID                = "local-lvm"
+	dvResourceVirtualEnvironmentVMEFIDiskFileFormat                 = "raw"
+	dvResourceVirtualEnvironmentVMEFIDiskPreEnrolledKeys            = false
+	dvResourceVirtualEnvironmentVMEFIDiskType                       = "2m"
+	dvResourceVirtualEnvironmentVMHostPCIID                         = ""
+	dvResourceVirtualEnvironmentVMHostPCIMapping                    = ""
+	dvResourceVirtualEnvironmentVMHostPCIMDev                       = ""
+	dvResourceVirtualEnvironmentVMHostPCIPCIE                       = false
+	dvResourceVirtualEnvironmentVMHostPCIROMBAR                     = true
+	dvResourceVirtualEnvironmentVMHostPCIROMFile                    = ""
+	dvResourceVirtualEnvironmentVMHostPCIXVGA                       = false
 	dvResourceVirtualEnvironmentVMInitializationDatastoreID         = "local-lvm"
 	dvResourceVirtualEnvironmentVMInitializationDNSDomain           = ""
 	dvResourceVirtualEnvironmentVMInitializationDNSServer           = ""
@@ -58,6 +71,8 @@ const (
 	dvResourceVirtualEnvironmentVMMemoryDedicated                   = 512
 	dvResourceVirtualEnvironmentVMMemoryFloating                    = 0
 	dvResourceVirtualEnvironmentVMMemoryShared                      = 0
+	dvResourceVirtualEnvironmentVMMigrate                           = true
+	dvResourceVirtualEnvironmentVMMigrateTimeout                    = "3h"
 	dvResourceVirtualEnvironmentVMName                              = ""
 	dvResourceVirtualEnvironmentVMNetworkDeviceBridge               = "vmbr0"
 	dvResourceVirtualEnvironmentVMNetworkDeviceEnabled              = true
@@ -71,14 +86,19 @@ const (
 	dvResourceVirtualEnvironmentVMStarted                           = true
 	dvResourceVirtualEnvironmentVMTabletDevice                      = true
 	dvResourceVirtualEnvironmentVMTemplate                          = false
+	dvResourceVirtualEnvironmentVMTPMStateDatastoreID               = "local-lvm"
+	dvResourceVirtualEnvironmentVMTPMStateVersion                   = "v2.0"
+	dvResourceVirtualEnvironmentVMUSBUSB3                           = false
 	dvResourceVirtualEnvironmentVMVGAEnabled                        = true
 	dvResourceVirtualEnvironmentVMVGAMemory                         = 16
 	dvResourceVirtualEnvironmentVMVGAType                           = "std"
 	dvResourceVirtualEnvironmentVMVMID                              = -1
 
 	maxResourceVirtualEnvironmentVMAudioDevices   = 1
+	maxResourceVirtualEnvironmentVMHostPCIDevices = 16
 	maxResourceVirtualEnvironmentVMNetworkDevices = 8
 	maxResourceVirtualEnvironmentVMSerialDevices  = 4
+	maxResourceVirtualEnvironmentVMUSBDevices     = 14
 
 	mkResourceVirtualEnvironmentVMACPI                              = "acpi"
 	mkResourceVirtualEnvironmentVMAgent                             = "agent"
@@ -117,6 +137,19 @@ const (
 	mkResourceVirtualEnvironmentVMDiskSpeedReadBurstable            = "read_burstable"
 	mkResourceVirtualEnvironmentVMDiskSpeedWrite                    = "write"
 	mkResourceVirtualEnvironmentVMDiskSpeedWriteBurstable           = "write_burstable"
+	mkResourceVirtualEnvironmentVMEFIDisk                           = "efi_disk"
+	mkResourceVirtualEnvironmentVMEFIDiskDatastoreID                = "datastore_id"
+	mkResourceVirtualEnvironmentVMEFIDiskFileFormat                 = "file_format"
+	mkResourceVirtualEnvironmentVMEFIDiskPreEnrolledKeys            = "pre_enrolled_keys"
+	mkResourceVirtualEnvironmentVMEFIDiskType                       = "type"
+	mkResourceVirtualEnvironmentVMHostPCI                           = "hostpci"
+	mkResourceVirtualEnvironmentVMHostPCIID                         = "id"
+	mkResourceVirtualEnvironmentVMHostPCIMapping                    = "mapping"
+	mkResourceVirtualEnvironmentVMHostPCIMDev                       = "mdev"
+	mkResourceVirtualEnvironmentVMHostPCIPCIE                       = "pcie"
+	mkResourceVirtualEnvironmentVMHostPCIROMBAR                     = "rombar"
+	mkResourceVirtualEnvironmentVMHostPCIROMFile                    = "rom_file"
+	mkResourceVirtualEnvironmentVMHostPCIXVGA                       = "xvga"
 	mkResourceVirtualEnvironmentVMInitialization                    = "initialization"
 	mkResourceVirtualEnvironmentVMInitializationDatastoreID         = "datastore_id"
 	mkResourceVirtualEnvironmentVMInitializationDNS                 = "dns"
@@ -142,6 +175,9 @@ const (
 	mkResourceVirtualEnvironmentVMMemoryDedicated                   = "dedicated"
 	mkResourceVirtualEnvironmentVMMemoryFloating                    = "floating"
 	mkResourceVirtualEnvironmentVMMemoryShared                      = "shared"
+	mkResourceVirtualEnvironmentVMMigrate                           = "migrate"
+	mkResourceVirtualEnvironmentVMMigrateTargetStorage              = "migrate_target_storage"
+	mkResourceVirtualEnvironmentVMMigrateTimeout                    = "migrate_timeout"
 	mkResourceVirtualEnvironmentVMName                              = "name"
 	mkResourceVirtualEnvironmentVMNetworkDevice                     = "network_device"
 	mkResourceVirtualEnvironmentVMNetworkDeviceBridge               = "bridge"
@@ -160,6 +196,12 @@ const (
 	mkResourceVirtualEnvironmentVMStarted                           = "started"
 	mkResourceVirtualEnvironmentVMTabletDevice                      = "tablet_device"
 	mkResourceVirtualEnvironmentVMTemplate                          = "template"
+	mkResourceVirtualEnvironmentVMTPMState                          = "tpm_state"
+	mkResourceVirtualEnvironmentVMTPMStateDatastoreID               = "datastore_id"
+	mkResourceVirtualEnvironmentVMTPMStateVersion                   = "version"
+	mkResourceVirtualEnvironmentVMUSB                               = "usb"
+	mkResourceVirtualEnvironmentVMUSBHost                           = "host"
+	mkResourceVirtualEnvironmentVMUSBUSB3                           = "usb3"
 	mkResourceVirtualEnvironmentVMVGA                               = "vga"
 	mkResourceVirtualEnvironmentVMVGAEnabled                        = "enabled"
 	mkResourceVirtualEnvironmentVMVGAMemory                         = "memory"
@@ -510,6 +552,106 @@ func resourceVirtualEnvironmentVM() *schema.Resource {
 				MaxItems: 14,
 				MinItems: 0,
 			},
+			mkResourceVirtualEnvironmentVMEFIDisk: {
+				Type:        schema.TypeList,
+				Description: "The EFI disk",
+				Optional:    true,
+				DefaultFunc: func() (interface{}, error) {
+					return []interface{}{}, nil
+				},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						mkResourceVirtualEnvironmentVMEFIDiskDatastoreID: {
+							Type:        schema.TypeString,
+							Description: "The datastore id",
+							Optional:    true,
+							Default:     dvResourceVirtualEnvironmentVMEFIDiskDatastoreID,
+						},
+						mkResourceVirtualEnvironmentVMEFIDiskFileFormat: {
+							Type:         schema.TypeString,
+							Description:  "The file format",
+							Optional:     true,
+							Default:      dvResourceVirtualEnvironmentVMEFIDiskFileFormat,
+							ValidateFunc: getFileFormatValidator(),
+						},
+						mkResourceVirtualEnvironmentVMEFIDiskPreEnrolledKeys: {
+							Type:        schema.TypeBool,
+							Description: "Whether to pre-enroll the Secure Boot keys of the distribution and Microsoft",
+							Optional:    true,
+							Default:     dvResourceVirtualEnvironmentVMEFIDiskPreEnrolledKeys,
+						},
+						mkResourceVirtualEnvironmentVMEFIDiskType: {
+							Type:         schema.TypeString,
+							Description:  "The size of the OVMF EFI vars template",
+							Optional:     true,
+							Default:      dvResourceVirtualEnvironmentVMEFIDiskType,
+							ValidateFunc: resourceVirtualEnvironmentVMGetEFIDiskTypeValidator(),
+							DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+								return d.Id() != "" && old == "" && new == dvResourceVirtualEnvironmentVMEFIDiskType
+							},
+						},
+					},
+				},
+				MaxItems: 1,
+				MinItems: 0,
+			},
+			mkResourceVirtualEnvironmentVMHostPCI: {
+				Type:        schema.TypeList,
+				Description: "The host PCI devices",
+				Optional:    true,
+				DefaultFunc: func() (interface{}, error) {
+					return []interface{}{}, nil
+				},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						mkResourceVirtualEnvironmentVMHostPCIID: {
+							Type:         schema.TypeString,
+							Description:  "The PCI device ID (multiple functions are separated by semicolons)",
+							Optional:     true,
+							Default:      dvResourceVirtualEnvironmentVMHostPCIID,
+							ValidateFunc: resourceVirtualEnvironmentVMGetHostPCIIDValidator(),
+						},
+						mkResourceVirtualEnvironmentVMHostPCIMapping: {
+							Type:        schema.TypeString,
+							Description: "The resource mapping name of the PCI device",
+							Optional:    true,
+							Default:     dvResourceVirtualEnvironmentVMHostPCIMapping,
+						},
+						mkResourceVirtualEnvironmentVMHostPCIMDev: {
+							Type:        schema.TypeString,
+							Description: "The mediated device type",
+							Optional:    true,
+							Default:     dvResourceVirtualEnvironmentVMHostPCIMDev,
+						},
+						mkResourceVirtualEnvironmentVMHostPCIPCIE: {
+							Type:        schema.TypeBool,
+							Description: "Whether to pass the device through as a PCI Express device",
+							Optional:    true,
+							Default:     dvResourceVirtualEnvironmentVMHostPCIPCIE,
+						},
+						mkResourceVirtualEnvironmentVMHostPCIROMBAR: {
+							Type:        schema.TypeBool,
+							Description: "Whether to map the ROM of the device into the memory map of the guest",
+							Optional:    true,
+							Default:     dvResourceVirtualEnvironmentVMHostPCIROMBAR,
+						},
+						mkResourceVirtualEnvironmentVMHostPCIROMFile: {
+							Type:        schema.TypeString,
+							Description: "The ROM file relative to /usr/share/kvm/",
+							Optional:    true,
+							Default:     dvResourceVirtualEnvironmentVMHostPCIROMFile,
+						},
+						mkResourceVirtualEnvironmentVMHostPCIXVGA: {
+							Type:        schema.TypeBool,
+							Description: "Whether to use the device as the primary GPU",
+							Optional:    true,
+							Default:     dvResourceVirtualEnvironmentVMHostPCIXVGA,
+						},
+					},
+				},
+				MaxItems: maxResourceVirtualEnvironmentVMHostPCIDevices,
+				MinItems: 0,
+			},
 			mkResourceVirtualEnvironmentVMInitialization: {
 				Type:        schema.TypeList,
 				Description: "The cloud-init configuration",
@@ -742,6 +884,25 @@ func resourceVirtualEnvironmentVM() *schema.Resource {
 				MaxItems: 1,
 				MinItems: 0,
 			},
+			mkResourceVirtualEnvironmentVMMigrate: {
+				Type:        schema.TypeBool,
+				Description: "Whether to migrate the VM instead of re-creating it, when the node name changes",
+				Optional:    true,
+				Default:     dvResourceVirtualEnvironmentVMMigrate,
+			},
+			mkResourceVirtualEnvironmentVMMigrateTargetStorage: {
+				Type:        schema.TypeMap,
+				Description: "The datastores on the target node, which local disks are moved to, indexed by the source datastore ids",
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			mkResourceVirtualEnvironmentVMMigrateTimeout: {
+				Type:         schema.TypeString,
+				Description:  "The maximum amount of time to wait for a migration to finish",
+				Optional:     true,
+				Default:      dvResourceVirtualEnvironmentVMMigrateTimeout,
+				ValidateFunc: getTimeoutValidator(),
+			},
 			mkResourceVirtualEnvironmentVMName: {
 				Type:        schema.TypeString,
 				Description: "The name",
@@ -813,7 +974,6 @@ func resourceVirtualEnvironmentVM() *schema.Resource {
 				Type:        schema.TypeString,
 				Description: "The node name",
 				Required:    true,
-				ForceNew:    true,
 			},
 			mkResourceVirtualEnvironmentVMOperatingSystem: {
 				Type:        schema.TypeList,
@@ -894,6 +1054,59 @@ func resourceVirtualEnvironmentVM() *schema.Resource {
 				ForceNew:    true,
 				Default:     dvResourceVirtualEnvironmentVMTemplate,
 			},
+			mkResourceVirtualEnvironmentVMTPMState: {
+				Type:        schema.TypeList,
+				Description: "The TPM state",
+				Optional:    true,
+				DefaultFunc: func() (interface{}, error) {
+					return []interface{}{}, nil
+				},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						mkResourceVirtualEnvironmentVMTPMStateDatastoreID: {
+							Type:        schema.TypeString,
+							Description: "The datastore id",
+							Optional:    true,
+							Default:     dvResourceVirtualEnvironmentVMTPMStateDatastoreID,
+						},
+						mkResourceVirtualEnvironmentVMTPMStateVersion: {
+							Type:         schema.TypeString,
+							Description:  "The TPM version",
+							Optional:     true,
+							Default:      dvResourceVirtualEnvironmentVMTPMStateVersion,
+							ValidateFunc: resourceVirtualEnvironmentVMGetTPMStateVersionValidator(),
+						},
+					},
+				},
+				MaxItems: 1,
+				MinItems: 0,
+			},
+			mkResourceVirtualEnvironmentVMUSB: {
+				Type:        schema.TypeList,
+				Description: "The USB devices",
+				Optional:    true,
+				DefaultFunc: func() (interface{}, error) {
+					return []interface{}{}, nil
+				},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						mkResourceVirtualEnvironmentVMUSBHost: {
+							Type:         schema.TypeString,
+							Description:  "The host USB device (vendor:product ID, port or spice)",
+							Required:     true,
+							ValidateFunc: resourceVirtualEnvironmentVMGetUSBHostValidator(),
+						},
+						mkResourceVirtualEnvironmentVMUSBUSB3: {
+							Type:        schema.TypeBool,
+							Description: "Whether to use a USB3 controller for the device",
+							Optional:    true,
+							Default:     dvResourceVirtualEnvironmentVMUSBUSB3,
+						},
+					},
+				},
+				MaxItems: maxResourceVirtualEnvironmentVMUSBDevices,
+				MinItems: 0,
+			},
 			mkResourceVirtualEnvironmentVMVGA: {
 				Type:        schema.TypeList,
 				Description: "The VGA configuration",
@@ -941,12 +1154,20 @@ func resourceVirtualEnvironmentVM() *schema.Resource {
 				ForceNew:     true,
 				Default:      dvResourceVirtualEnvironmentVMVMID,
 				ValidateFunc: getVMIDValidator(),
+				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+					// An allocated or imported VM identifier is retained, as long as no specific one has been requested.
+					return d.Id() != "" && new == strconv.Itoa(dvResourceVirtualEnvironmentVMVMID)
+				},
 			},
 		},
 		Create: resourceVirtualEnvironmentVMCreate,
 		Read:   resourceVirtualEnvironmentVMRead,
 		Update: resourceVirtualEnvironmentVMUpdate,
 		Delete: resourceVirtualEnvironmentVMDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceVirtualEnvironmentVMImport,
+		},
+		CustomizeDiff: resourceVirtualEnvironmentVMCustomizeDiff,
 	}
 }
 
@@ -980,21 +1201,10 @@ func resourceVirtualEnvironmentVMCreateClone(d *schema.ResourceData, m interface
 	poolID := d.Get(mkResourceVirtualEnvironmentVMPoolID).(string)
 	vmID := d.Get(mkResourceVirtualEnvironmentVMVMID).(int)
 
-	if vmID == -1 {
-		vmIDNew, err := veClient.GetVMID()
-
-		if err != nil {
-			return err
-		}
-
-		vmID = *vmIDNew
-	}
-
 	fullCopy := proxmox.CustomBool(true)
 
 	cloneBody := &proxmox.VirtualEnvironmentVMCloneRequestBody{
 		FullCopy: &fullCopy,
-		VMIDNew:  vmID,
 	}
 
 	if cloneDatastoreID != "" {
@@ -1013,22 +1223,27 @@ func resourceVirtualEnvironmentVMCreateClone(d *schema.ResourceData, m interface
 		cloneBody.PoolID = &poolID
 	}
 
+	cloneSourceNodeName := nodeName
+
 	if cloneNodeName != "" && cloneNodeName != nodeName {
 		cloneBody.TargetNodeName = &nodeName
-
-		err = veClient.CloneVM(cloneNodeName, cloneVMID, cloneBody)
-	} else {
-		err = veClient.CloneVM(nodeName, cloneVMID, cloneBody)
+		cloneSourceNodeName = cloneNodeName
 	}
 
+	vmID, taskID, err := createWithVMID(config.stopContext, veClient, vmID, func(vmID int) (*string, error) {
+		cloneBody.VMIDNew = vmID
+
+		return veClient.CloneVMWithContext(config.stopContext, cloneSourceNodeName, cloneVMID, cloneBody)
+	})
+
 	if err != nil {
 		return err
 	}
 
 	d.SetId(strconv.Itoa(vmID))
 
-	// Wait for the virtual machine to be created and its configuration lock to be released.
-	err = veClient.WaitForVMConfigUnlock(nodeName, vmID, 600, 5, true)
+	// Wait for the clone task to finish, as the virtual machine configuration remains locked until then.
+	err = veClient.WaitForTaskWithContext(config.stopContext, cloneSourceNodeName, *taskID, 600, 5)
 
 	if err != nil {
 		return err
@@ -1046,6 +1261,8 @@ func resourceVirtualEnvironmentVMCreateClone(d *schema.ResourceData, m interface
 	bios := d.Get(mkResourceVirtualEnvironmentVMBIOS).(string)
 	cdrom := d.Get(mkResourceVirtualEnvironmentVMCDROM).([]interface{})
 	cpu := d.Get(mkResourceVirtualEnvironmentVMCPU).([]interface{})
+	efiDisk := d.Get(mkResourceVirtualEnvironmentVMEFIDisk).([]interface{})
+	hostPCI := d.Get(mkResourceVirtualEnvironmentVMHostPCI).([]interface{})
 	initialization := d.Get(mkResourceVirtualEnvironmentVMInitialization).([]interface{})
 	keyboardLayout := d.Get(mkResourceVirtualEnvironmentVMKeyboardLayout).(string)
 	memory := d.Get(mkResourceVirtualEnvironmentVMMemory).([]interface{})
@@ -1055,6 +1272,8 @@ func resourceVirtualEnvironmentVMCreateClone(d *schema.ResourceData, m interface
 	started := proxmox.CustomBool(d.Get(mkResourceVirtualEnvironmentVMStarted).(bool))
 	tabletDevice := proxmox.CustomBool(d.Get(mkResourceVirtualEnvironmentVMTabletDevice).(bool))
 	template := proxmox.CustomBool(d.Get(mkResourceVirtualEnvironmentVMTemplate).(bool))
+	tpmState := d.Get(mkResourceVirtualEnvironmentVMTPMState).([]interface{})
+	usb := d.Get(mkResourceVirtualEnvironmentVMUSB).([]interface{})
 	vga := d.Get(mkResourceVirtualEnvironmentVMVGA).([]interface{})
 
 	updateBody := &proxmox.VirtualEnvironmentVMUpdateRequestBody{
@@ -1202,6 +1421,18 @@ func resourceVirtualEnvironmentVMCreateClone(d *schema.ResourceData, m interface
 		}
 	}
 
+	if len(hostPCI) > 0 {
+		updateBody.PCIDevices, err = resourceVirtualEnvironmentVMGetHostPCIDeviceObjects(d, m)
+
+		if err != nil {
+			return err
+		}
+
+		for i := len(updateBody.PCIDevices); i < maxResourceVirtualEnvironmentVMHostPCIDevices; i++ {
+			delete = append(delete, fmt.Sprintf("hostpci%d", i))
+		}
+	}
+
 	if len(networkDevice) > 0 {
 		updateBody.NetworkDevices, err = resourceVirtualEnvironmentVMGetNetworkDeviceObjects(d, m)
 
@@ -1251,6 +1482,43 @@ func resourceVirtualEnvironmentVMCreateClone(d *schema.ResourceData, m interface
 		updateBody.Template = &template
 	}
 
+	// The EFI disk and the TPM state of the source VM are kept, as they may contain keys and secrets.
+	if len(efiDisk) > 0 || len(tpmState) > 0 {
+		vmConfig, err := veClient.GetVMWithContext(config.stopContext, nodeName, vmID)
+
+		if err != nil {
+			return err
+		}
+
+		if vmConfig.EFIDisk == nil {
+			updateBody.EFIDisk, err = resourceVirtualEnvironmentVMGetEFIDiskObject(d, m)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if vmConfig.TPMState == nil {
+			updateBody.TPMState, err = resourceVirtualEnvironmentVMGetTPMStateObject(d, m)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(usb) > 0 {
+		updateBody.USBDevices, err = resourceVirtualEnvironmentVMGetUSBDeviceObjects(d, m)
+
+		if err != nil {
+			return err
+		}
+
+		for i := len(updateBody.USBDevices); i < maxResourceVirtualEnvironmentVMUSBDevices; i++ {
+			delete = append(delete, fmt.Sprintf("usb%d", i))
+		}
+	}
+
 	if len(vga) > 0 {
 		vgaDevice, err := resourceVirtualEnvironmentVMGetVGADeviceObject(d, m)
 
@@ -1336,6 +1604,18 @@ func resourceVirtualEnvironmentVMCreateCustom(d *schema.ResourceData, m interfac
 		return err
 	}
 
+	efiDiskObject, err := resourceVirtualEnvironmentVMGetEFIDiskObject(d, m)
+
+	if err != nil {
+		return err
+	}
+
+	hostPCIDeviceObjects, err := resourceVirtualEnvironmentVMGetHostPCIDeviceObjects(d, m)
+
+	if err != nil {
+		return err
+	}
+
 	initializationConfig, err := resourceVirtualEnvironmentVMGetCloudInitConfig(d, m)
 
 	if err != nil {
@@ -1392,24 +1672,26 @@ func resourceVirtualEnvironmentVMCreateCustom(d *schema.ResourceData, m interfac
 	tabletDevice := proxmox.CustomBool(d.Get(mkResourceVirtualEnvironmentVMTabletDevice).(bool))
 	template := proxmox.CustomBool(d.Get(mkResourceVirtualEnvironmentVMTemplate).(bool))
 
-	vgaDevice, err := resourceVirtualEnvironmentVMGetVGADeviceObject(d, m)
+	tpmStateObject, err := resourceVirtualEnvironmentVMGetTPMStateObject(d, m)
 
 	if err != nil {
 		return err
 	}
 
-	vmID := d.Get(mkResourceVirtualEnvironmentVMVMID).(int)
+	usbDeviceObjects, err := resourceVirtualEnvironmentVMGetUSBDeviceObjects(d, m)
 
-	if vmID == -1 {
-		vmIDNew, err := veClient.GetVMID()
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
-			return err
-		}
+	vgaDevice, err := resourceVirtualEnvironmentVMGetVGADeviceObject(d, m)
 
-		vmID = *vmIDNew
+	if err != nil {
+		return err
 	}
 
+	vmID := d.Get(mkResourceVirtualEnvironmentVMVMID).(int)
+
 	var memorySharedObject *proxmox.CustomSharedMemory
 
 	bootDisk := "scsi0"
@@ -1441,9 +1723,7 @@ func resourceVirtualEnvironmentVMCreateCustom(d *schema.ResourceData, m interfac
 	}
 
 	if memoryShared > 0 {
-		memorySharedName := fmt.Sprintf("vm-%d-ivshmem", vmID)
 		memorySharedObject = &proxmox.CustomSharedMemory{
-			Name: &memorySharedName,
 			Size: memoryShared,
 		}
 	}
@@ -1470,11 +1750,13 @@ func resourceVirtualEnvironmentVMCreateCustom(d *schema.ResourceData, m interfac
 		CPUSockets:          &cpuSockets,
 		CPUUnits:            &cpuUnits,
 		DedicatedMemory:     &memoryDedicated,
+		EFIDisk:             efiDiskObject,
 		FloatingMemory:      &memoryFloating,
 		IDEDevices:          ideDevices,
 		KeyboardLayout:      &keyboardLayout,
 		NetworkDevices:      networkDeviceObjects,
 		OSType:              &operatingSystemType,
+		PCIDevices:          hostPCIDeviceObjects,
 		PoolID:              &poolID,
 		SCSIDevices:         diskDeviceObjects,
 		SCSIHardware:        &scsiHardware,
@@ -1483,8 +1765,9 @@ func resourceVirtualEnvironmentVMCreateCustom(d *schema.ResourceData, m interfac
 		StartOnBoot:         &started,
 		TabletDeviceEnabled: &tabletDevice,
 		Template:            &template,
+		TPMState:            tpmStateObject,
+		USBDevices:          usbDeviceObjects,
 		VGADevice:           vgaDevice,
-		VMID:                &vmID,
 	}
 
 	// Only the root account is allowed to change the CPU architecture, which makes this check necessary.
@@ -1504,7 +1787,16 @@ func resourceVirtualEnvironmentVMCreateCustom(d *schema.ResourceData, m interfac
 		createBody.Name = &name
 	}
 
-	err = veClient.CreateVM(nodeName, createBody)
+	vmID, taskID, err := createWithVMID(config.stopContext, veClient, vmID, func(vmID int) (*string, error) {
+		createBody.VMID = &vmID
+
+		if memorySharedObject != nil {
+			memorySharedName := fmt.Sprintf("vm-%d-ivshmem", vmID)
+			memorySharedObject.Name = &memorySharedName
+		}
+
+		return veClient.CreateVMWithContext(config.stopContext, nodeName, createBody)
+	})
 
 	if err != nil {
 		return err
@@ -1512,6 +1804,12 @@ func resourceVirtualEnvironmentVMCreateCustom(d *schema.ResourceData, m interfac
 
 	d.SetId(strconv.Itoa(vmID))
 
+	err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, 600, 5)
+
+	if err != nil {
+		return err
+	}
+
 	return resourceVirtualEnvironmentVMCreateCustomDisks(d, m)
 }
 
@@ -1530,7 +1828,10 @@ func resourceVirtualEnvironmentVMCreateCustomDisks(d *schema.ResourceData, m int
 		return err
 	}
 
-	commands := []string{}
+	commandPlan := &proxmox.VirtualEnvironmentNodeCommandPlan{
+		Cleanup: []*proxmox.VirtualEnvironmentNodeCommand{},
+		Steps:   []*proxmox.VirtualEnvironmentNodeCommand{},
+	}
 
 	// Determine the ID of the next disk.
 	disk := d.Get(mkResourceVirtualEnvironmentVMDisk).([]interface{})
@@ -1551,7 +1852,7 @@ func resourceVirtualEnvironmentVMCreateCustomDisks(d *schema.ResourceData, m int
 	diskSchemaResource := diskSchemaElem.(*schema.Resource)
 	diskSpeedResource := diskSchemaResource.Schema[mkResourceVirtualEnvironmentVMDiskSpeed]
 
-	// Generate the commands required to import the specified disks.
+	// Generate the steps required to import the specified disks.
 	importedDiskCount := 0
 
 	for i, d := range disk {
@@ -1611,31 +1912,48 @@ func resourceVirtualEnvironmentVMCreateCustomDisks(d *schema.ResourceData, m int
 			filePath = fmt.Sprintf("/%s", fileIDParts[1])
 		}
 
+		datastorePath, err := veClient.GetDatastorePathWithContext(config.stopContext, fileIDParts[0])
+
+		if err != nil {
+			return err
+		}
+
 		filePathTmp := fmt.Sprintf("/tmp/vm-%d-disk-%d.%s", vmID, diskCount+importedDiskCount, fileFormat)
 
-		commands = append(
-			commands,
-			`set -e`,
-			`export nr='^[A-Za-z0-9_]+: ([A-Za-z0-9_]+)$'`,
-			`export pr='^[[:space:]]+path[[:space:]]+([^[:space:]]+)$'`,
-			`export dn=""`,
-			`export dp=""`,
-			fmt.Sprintf(`while IFS='' read -r l || [[ -n "$l" ]]; do if [[ "$l" =~ $nr ]]; then export dn="${BASH_REMATCH[1]}"; elif [[ "$l" =~ $pr ]] && [[ "$dn" == "%s" ]]; then export dp="${BASH_REMATCH[1]}"; break; fi; done < /etc/pve/storage.cfg`, fileIDParts[0]),
-			`if [[ -z "$dp" ]]; then echo "Failed to determine the datastore path"; exit 1; fi`,
-			fmt.Sprintf(`cp "${dp}%s" %s`, filePath, filePathTmp),
-			fmt.Sprintf(`qemu-img resize %s %dG`, filePathTmp, size),
-			fmt.Sprintf(`qm importdisk %d %s %s -format qcow2`, vmID, filePathTmp, datastoreID),
-			fmt.Sprintf(`qm set %d -scsi%d %s:vm-%d-disk-%d%s`, vmID, i, datastoreID, vmID, diskCount+importedDiskCount, diskOptions),
-			fmt.Sprintf(`rm -f %s`, filePathTmp),
+		diskName := fmt.Sprintf("scsi%d", i)
+
+		commandPlan.Steps = append(
+			commandPlan.Steps,
+			&proxmox.VirtualEnvironmentNodeCommand{
+				Name:    fmt.Sprintf("Copy the source file of disk %s", diskName),
+				Command: fmt.Sprintf(`cp "%s%s" %s`, *datastorePath, filePath, filePathTmp),
+			},
+			&proxmox.VirtualEnvironmentNodeCommand{
+				Name:    fmt.Sprintf("Resize the disk image of disk %s", diskName),
+				Command: fmt.Sprintf(`qemu-img resize %s %dG`, filePathTmp, size),
+			},
+			&proxmox.VirtualEnvironmentNodeCommand{
+				Name:    fmt.Sprintf("Import the disk image of disk %s", diskName),
+				Command: fmt.Sprintf(`qm importdisk %d %s %s -format qcow2`, vmID, filePathTmp, datastoreID),
+			},
+			&proxmox.VirtualEnvironmentNodeCommand{
+				Name:    fmt.Sprintf("Attach disk %s", diskName),
+				Command: fmt.Sprintf(`qm set %d -%s %s:vm-%d-disk-%d%s`, vmID, diskName, datastoreID, vmID, diskCount+importedDiskCount, diskOptions),
+			},
 		)
 
+		commandPlan.Cleanup = append(commandPlan.Cleanup, &proxmox.VirtualEnvironmentNodeCommand{
+			Name:    fmt.Sprintf("Remove the temporary disk image of disk %s", diskName),
+			Command: fmt.Sprintf(`rm -f %s`, filePathTmp),
+		})
+
 		importedDiskCount++
 	}
 
-	// Execute the commands on the node and wait for the result.
+	// Execute the steps on the node and wait for the result.
 	// This is a highly experimental approach to disk imports and is not recommended by Proxmox.
-	if len(commands) > 0 {
-		err = veClient.ExecuteNodeCommands(nodeName, commands)
+	if len(commandPlan.Steps) > 0 {
+		_, err = veClient.RunNodeCommandsWithContext(config.stopContext, nodeName, commandPlan)
 
 		if err != nil {
 			return err
@@ -1668,13 +1986,19 @@ func resourceVirtualEnvironmentVMCreateStart(d *schema.ResourceData, m interface
 	}
 
 	// Start the virtual machine and wait for it to reach a running state before continuing.
-	err = veClient.StartVM(nodeName, vmID)
+	taskID, err := veClient.StartVMWithContext(config.stopContext, nodeName, vmID)
+
+	if err != nil {
+		return err
+	}
+
+	err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, 120, 5)
 
 	if err != nil {
 		return err
 	}
 
-	err = veClient.WaitForVMState(nodeName, vmID, "running", 120, 5)
+	err = veClient.WaitForVMStateWithContext(config.stopContext, nodeName, vmID, "running", 120, 5)
 
 	if err != nil {
 		return err
@@ -1683,6 +2007,55 @@ func resourceVirtualEnvironmentVMCreateStart(d *schema.ResourceData, m interface
 	return resourceVirtualEnvironmentVMRead(d, m)
 }
 
+func resourceVirtualEnvironmentVMCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	// A VM is only re-created on another node, if migrations have been disabled.
+	if d.Id() != "" && d.HasChange(mkResourceVirtualEnvironmentVMNodeName) && !d.Get(mkResourceVirtualEnvironmentVMMigrate).(bool) {
+		return d.ForceNew(mkResourceVirtualEnvironmentVMNodeName)
+	}
+
+	// The EFI disk and the TPM state can be added and removed in place, while their properties are fixed once allocated.
+	fixedProperties := map[string][]string{
+		mkResourceVirtualEnvironmentVMEFIDisk: {
+			mkResourceVirtualEnvironmentVMEFIDiskDatastoreID,
+			mkResourceVirtualEnvironmentVMEFIDiskFileFormat,
+			mkResourceVirtualEnvironmentVMEFIDiskPreEnrolledKeys,
+			mkResourceVirtualEnvironmentVMEFIDiskType,
+		},
+		mkResourceVirtualEnvironmentVMTPMState: {
+			mkResourceVirtualEnvironmentVMTPMStateDatastoreID,
+			mkResourceVirtualEnvironmentVMTPMStateVersion,
+		},
+	}
+
+	for blockKey, propertyKeys := range fixedProperties {
+		oldBlock, newBlock := d.GetChange(blockKey)
+
+		if d.Id() == "" || len(oldBlock.([]interface{})) == 0 || len(newBlock.([]interface{})) == 0 {
+			continue
+		}
+
+		for _, propertyKey := range propertyKeys {
+			key := fmt.Sprintf("%s.0.%s", blockKey, propertyKey)
+			oldValue, newValue := d.GetChange(key)
+
+			// A missing EFI disk type equals the default type, which Proxmox VE does not report.
+			if propertyKey == mkResourceVirtualEnvironmentVMEFIDiskType && oldValue == "" && newValue == dvResourceVirtualEnvironmentVMEFIDiskType {
+				continue
+			}
+
+			if oldValue != newValue {
+				err := d.ForceNew(key)
+
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 func resourceVirtualEnvironmentVMGetAudioDeviceList(d *schema.ResourceData, m interface{}) (proxmox.CustomAudioDevices, error) {
 	devices := d.Get(mkResourceVirtualEnvironmentVMAudioDevice).([]interface{})
 	list := make(proxmox.CustomAudioDevices, len(devices))
@@ -1864,23 +2237,159 @@ func resourceVirtualEnvironmentVMGetDiskDeviceObjects(d *schema.ResourceData, m
 				diskDevice.MaxReadSpeedMbps = &speedLimitRead
 			}
 
-			if speedLimitReadBurstable > 0 {
-				diskDevice.BurstableReadSpeedMbps = &speedLimitReadBurstable
-			}
+			if speedLimitReadBurstable > 0 {
+				diskDevice.BurstableReadSpeedMbps = &speedLimitReadBurstable
+			}
+
+			if speedLimitWrite > 0 {
+				diskDevice.MaxWriteSpeedMbps = &speedLimitWrite
+			}
+
+			if speedLimitWriteBurstable > 0 {
+				diskDevice.BurstableWriteSpeedMbps = &speedLimitWriteBurstable
+			}
+		}
+
+		diskDeviceObjects[i] = diskDevice
+	}
+
+	return diskDeviceObjects, nil
+}
+
+func resourceVirtualEnvironmentVMGetEFIDiskObject(d *schema.ResourceData, m interface{}) (*proxmox.CustomEFIDisk, error) {
+	efiDisk := d.Get(mkResourceVirtualEnvironmentVMEFIDisk).([]interface{})
+
+	if len(efiDisk) == 0 || efiDisk[0] == nil {
+		return nil, nil
+	}
+
+	block := efiDisk[0].(map[string]interface{})
+
+	datastoreID, _ := block[mkResourceVirtualEnvironmentVMEFIDiskDatastoreID].(string)
+	fileFormat, _ := block[mkResourceVirtualEnvironmentVMEFIDiskFileFormat].(string)
+	preEnrolledKeys, _ := block[mkResourceVirtualEnvironmentVMEFIDiskPreEnrolledKeys].(bool)
+	efiType, _ := block[mkResourceVirtualEnvironmentVMEFIDiskType].(string)
+
+	// The keys are stored in the EFI vars template, which is only large enough in its 4 MB variant.
+	if preEnrolledKeys && efiType != "4m" {
+		return nil, fmt.Errorf("The EFI disk must be of type \"4m\" in order to pre-enroll keys")
+	}
+
+	// Proxmox VE determines the size of the EFI disk, which is why the size in the allocation is ignored.
+	efiDiskObject := &proxmox.CustomEFIDisk{
+		FileVolume: fmt.Sprintf("%s:1", datastoreID),
+		Format:     &fileFormat,
+	}
+
+	// The type is omitted for the default variant, as Proxmox VE 6.x does not support it.
+	if efiType != dvResourceVirtualEnvironmentVMEFIDiskType {
+		efiDiskObject.Type = &efiType
+	}
+
+	if preEnrolledKeys {
+		preEnrolledKeysEnabled := proxmox.CustomBool(true)
+		efiDiskObject.PreEnrolledKeys = &preEnrolledKeysEnabled
+	}
+
+	return efiDiskObject, nil
+}
+
+func resourceVirtualEnvironmentVMGetEFIDiskTypeValidator() schema.SchemaValidateFunc {
+	return validation.StringInSlice([]string{
+		"2m",
+		"4m",
+	}, false)
+}
+
+func resourceVirtualEnvironmentVMGetHostPCIDeviceObjects(d *schema.ResourceData, m interface{}) (proxmox.CustomPCIDevices, error) {
+	hostPCI := d.Get(mkResourceVirtualEnvironmentVMHostPCI).([]interface{})
+	hostPCIObjects := make(proxmox.CustomPCIDevices, len(hostPCI))
+
+	for i, hostPCIEntry := range hostPCI {
+		block, _ := hostPCIEntry.(map[string]interface{})
+
+		id, _ := block[mkResourceVirtualEnvironmentVMHostPCIID].(string)
+		mapping, _ := block[mkResourceVirtualEnvironmentVMHostPCIMapping].(string)
+		mdev, _ := block[mkResourceVirtualEnvironmentVMHostPCIMDev].(string)
+		pcie, _ := block[mkResourceVirtualEnvironmentVMHostPCIPCIE].(bool)
+		rombar, _ := block[mkResourceVirtualEnvironmentVMHostPCIROMBAR].(bool)
+		romFile, _ := block[mkResourceVirtualEnvironmentVMHostPCIROMFile].(string)
+		xvga, _ := block[mkResourceVirtualEnvironmentVMHostPCIXVGA].(bool)
+
+		if (id == "") == (mapping == "") {
+			return nil, fmt.Errorf("Host PCI device %d (hostpci%d) requires either an ID or a mapping", i, i)
+		}
+
+		device := proxmox.CustomPCIDevice{}
+
+		if id != "" {
+			device.DeviceIDs = strings.Split(id, ";")
+		} else {
+			device.Mapping = &mapping
+		}
+
+		if mdev != "" {
+			device.DevicePath = &mdev
+		}
+
+		// Only the values, which differ from the defaults used by Proxmox VE, are written to the configuration.
+		if pcie {
+			pcieEnabled := proxmox.CustomBool(true)
+			device.PCIExpress = &pcieEnabled
+		}
+
+		if !rombar {
+			rombarEnabled := proxmox.CustomBool(false)
+			device.ROMBAR = &rombarEnabled
+		}
+
+		if romFile != "" {
+			device.ROMFile = &romFile
+		}
+
+		if xvga {
+			xvgaEnabled := proxmox.CustomBool(true)
+			device.XVGA = &xvgaEnabled
+		}
+
+		hostPCIObjects[i] = device
+	}
+
+	return hostPCIObjects, nil
+}
+
+func resourceVirtualEnvironmentVMGetHostPCIIDValidator() schema.SchemaValidateFunc {
+	return func(i interface{}, k string) (ws []string, es []error) {
+		v, ok := i.(string)
+
+		if !ok {
+			es = append(es, fmt.Errorf("expected type of %s to be string", k))
+			return
+		}
 
-			if speedLimitWrite > 0 {
-				diskDevice.MaxWriteSpeedMbps = &speedLimitWrite
-			}
+		if v != "" {
+			r := regexp.MustCompile(`^(?i)([0-9a-f]{4}:)?[0-9a-f]{2}:[0-9a-f]{2}(\.[0-7])?(;([0-9a-f]{4}:)?[0-9a-f]{2}:[0-9a-f]{2}(\.[0-7])?)*$`)
+			ok := r.MatchString(v)
 
-			if speedLimitWriteBurstable > 0 {
-				diskDevice.BurstableWriteSpeedMbps = &speedLimitWriteBurstable
+			if !ok {
+				es = append(es, fmt.Errorf("expected %s to be a valid PCI device ID (0000:01:00.0), got %s", k, v))
+				return
 			}
 		}
 
-		diskDeviceObjects[i] = diskDevice
+		return
 	}
+}
 
-	return diskDeviceObjects, nil
+func resourceVirtualEnvironmentVMGetMigratedDatastoreID(d *schema.ResourceData, currentDatastoreID interface{}, datastoreID string) string {
+	// Keep the configured datastore id for disks, which have been moved to a mapped datastore during a migration.
+	targetStorage := d.Get(mkResourceVirtualEnvironmentVMMigrateTargetStorage).(map[string]interface{})
+
+	if currentDatastoreID, ok := currentDatastoreID.(string); ok && targetStorage[currentDatastoreID] == datastoreID {
+		return currentDatastoreID
+	}
+
+	return datastoreID
 }
 
 func resourceVirtualEnvironmentVMGetNetworkDeviceObjects(d *schema.ResourceData, m interface{}) (proxmox.CustomNetworkDevices, error) {
@@ -1974,6 +2483,73 @@ func resourceVirtualEnvironmentVMGetSerialDeviceValidator() schema.SchemaValidat
 	}
 }
 
+func resourceVirtualEnvironmentVMGetTPMStateObject(d *schema.ResourceData, m interface{}) (*proxmox.CustomTPMState, error) {
+	tpmState := d.Get(mkResourceVirtualEnvironmentVMTPMState).([]interface{})
+
+	if len(tpmState) == 0 || tpmState[0] == nil {
+		return nil, nil
+	}
+
+	block := tpmState[0].(map[string]interface{})
+
+	datastoreID, _ := block[mkResourceVirtualEnvironmentVMTPMStateDatastoreID].(string)
+	version, _ := block[mkResourceVirtualEnvironmentVMTPMStateVersion].(string)
+
+	return &proxmox.CustomTPMState{
+		FileVolume: fmt.Sprintf("%s:1", datastoreID),
+		Version:    &version,
+	}, nil
+}
+
+func resourceVirtualEnvironmentVMGetTPMStateVersionValidator() schema.SchemaValidateFunc {
+	return validation.StringInSlice([]string{
+		"v1.2",
+		"v2.0",
+	}, false)
+}
+
+func resourceVirtualEnvironmentVMGetUSBDeviceObjects(d *schema.ResourceData, m interface{}) (proxmox.CustomUSBDevices, error) {
+	usb := d.Get(mkResourceVirtualEnvironmentVMUSB).([]interface{})
+	usbObjects := make(proxmox.CustomUSBDevices, len(usb))
+
+	for i, usbEntry := range usb {
+		block := usbEntry.(map[string]interface{})
+
+		host, _ := block[mkResourceVirtualEnvironmentVMUSBHost].(string)
+		usb3, _ := block[mkResourceVirtualEnvironmentVMUSBUSB3].(bool)
+
+		usbObjects[i].HostDevice = host
+
+		if usb3 {
+			usb3Enabled := proxmox.CustomBool(true)
+			usbObjects[i].USB3 = &usb3Enabled
+		}
+	}
+
+	return usbObjects, nil
+}
+
+func resourceVirtualEnvironmentVMGetUSBHostValidator() schema.SchemaValidateFunc {
+	return func(i interface{}, k string) (ws []string, es []error) {
+		v, ok := i.(string)
+
+		if !ok {
+			es = append(es, fmt.Errorf("expected type of %s to be string", k))
+			return
+		}
+
+		r := regexp.MustCompile(`^(?i)(spice|[0-9a-f]{4}:[0-9a-f]{4}|\d+-\d+(\.\d+)*)$`)
+		ok = r.MatchString(v)
+
+		if !ok {
+			es = append(es, fmt.Errorf("expected %s to be a vendor:product ID (046d:c52b), a port (1-2.3) or 'spice', got %s", k, v))
+			return
+		}
+
+		return
+	}
+}
+
 func resourceVirtualEnvironmentVMGetVGADeviceObject(d *schema.ResourceData, m interface{}) (*proxmox.CustomVGADevice, error) {
 	resource := resourceVirtualEnvironmentVM()
 
@@ -2006,6 +2582,117 @@ func resourceVirtualEnvironmentVMGetVGADeviceObject(d *schema.ResourceData, m in
 	return vgaDevice, nil
 }
 
+func resourceVirtualEnvironmentVMImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	config := m.(providerConfiguration)
+	veClient, err := config.GetVEClient()
+
+	if err != nil {
+		return nil, err
+	}
+
+	nodeName, vmID, err := parseVMImportID(d.Id())
+
+	if err != nil {
+		return nil, err
+	}
+
+	poolID, err := getVMPoolID(veClient, vmID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.Set(mkResourceVirtualEnvironmentVMNodeName, nodeName)
+	d.Set(mkResourceVirtualEnvironmentVMPoolID, poolID)
+	d.Set(mkResourceVirtualEnvironmentVMVMID, vmID)
+	d.SetId(strconv.Itoa(vmID))
+
+	return []*schema.ResourceData{d}, nil
+}
+
+func resourceVirtualEnvironmentVMIsHotplugEnabled(vmConfig *proxmox.VirtualEnvironmentVMGetResponseData, deviceType string) bool {
+	// Proxmox VE enables hotplugging of disks, network devices and USB devices, unless the VM overrides it.
+	if vmConfig.Hotplug == nil {
+		return deviceType == "disk" || deviceType == "network" || deviceType == "usb"
+	}
+
+	for _, v := range *vmConfig.Hotplug {
+		switch strings.TrimSpace(v) {
+		case "0":
+			return false
+		case "1":
+			return deviceType == "disk" || deviceType == "network" || deviceType == "usb"
+		case deviceType:
+			return true
+		}
+	}
+
+	return false
+}
+
+func resourceVirtualEnvironmentVMMigrate(d *schema.ResourceData, m interface{}, vmID int) error {
+	config := m.(providerConfiguration)
+	veClient, err := config.GetVEClient()
+
+	if err != nil {
+		return err
+	}
+
+	oldNodeName, newNodeName := d.GetChange(mkResourceVirtualEnvironmentVMNodeName)
+	vmStatus, err := veClient.GetVMStatusWithContext(config.stopContext, oldNodeName.(string), vmID)
+
+	if err != nil {
+		return err
+	}
+
+	migrateTimeout, err := time.ParseDuration(d.Get(mkResourceVirtualEnvironmentVMMigrateTimeout).(string))
+
+	if err != nil {
+		return err
+	}
+
+	// Running VMs are migrated online, while Proxmox VE moves local disks to the mapped datastores on the target node.
+	// Disks on datastores without a mapping are moved to the datastores with the same IDs.
+	online := proxmox.CustomBool(vmStatus.Status == "running")
+	withLocalDisks := proxmox.CustomBool(true)
+
+	migrateBody := &proxmox.VirtualEnvironmentVMMigrateRequestBody{
+		OnlineMigration: &online,
+		TargetNode:      newNodeName.(string),
+		WithLocalDisks:  &withLocalDisks,
+	}
+
+	targetStorage := d.Get(mkResourceVirtualEnvironmentVMMigrateTargetStorage).(map[string]interface{})
+
+	if len(targetStorage) > 0 {
+		targetStorageMappings := []string{}
+
+		for sourceDatastoreID, targetDatastoreID := range targetStorage {
+			targetStorageMappings = append(targetStorageMappings, fmt.Sprintf("%s:%s", sourceDatastoreID, targetDatastoreID.(string)))
+		}
+
+		sort.Strings(targetStorageMappings)
+
+		targetStorageList := strings.Join(targetStorageMappings, ",")
+		migrateBody.TargetStorage = &targetStorageList
+	}
+
+	taskID, err := veClient.MigrateVMWithContext(config.stopContext, oldNodeName.(string), vmID, migrateBody)
+
+	if err == nil {
+		err = veClient.WaitForTaskWithContext(config.stopContext, oldNodeName.(string), *taskID, int(migrateTimeout.Seconds()), 5)
+	}
+
+	if err != nil {
+		// Keep the previous node name in the state, as the VM still resides on the previous node.
+		d.Set(mkResourceVirtualEnvironmentVMNodeName, oldNodeName)
+
+		return fmt.Errorf("Failed to migrate VM %d from node \"%s\" to node \"%s\": %s", vmID, oldNodeName, newNodeName, err.Error())
+	}
+
+	return nil
+}
+
 func resourceVirtualEnvironmentVMRead(d *schema.ResourceData, m interface{}) error {
 	config := m.(providerConfiguration)
 	veClient, err := config.GetVEClient()
@@ -2025,8 +2712,7 @@ func resourceVirtualEnvironmentVMRead(d *schema.ResourceData, m interface{}) err
 	vmConfig, err := veClient.GetVM(nodeName, vmID)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") ||
-			(strings.Contains(err.Error(), "HTTP 500") && strings.Contains(err.Error(), "does not exist")) {
+		if proxmox.IsNotFound(err) {
 			d.SetId("")
 
 			return nil
@@ -2301,6 +2987,11 @@ func resourceVirtualEnvironmentVMReadCustom(d *schema.ResourceData, m interface{
 		if len(currentDisk) > di {
 			currentDiskEntry := currentDisk[di].(map[string]interface{})
 
+			disk[mkResourceVirtualEnvironmentVMDiskDatastoreID] = resourceVirtualEnvironmentVMGetMigratedDatastoreID(
+				d,
+				currentDiskEntry[mkResourceVirtualEnvironmentVMDiskDatastoreID],
+				fileIDParts[0],
+			)
 			disk[mkResourceVirtualEnvironmentVMDiskFileFormat] = currentDiskEntry[mkResourceVirtualEnvironmentVMDiskFileFormat]
 			disk[mkResourceVirtualEnvironmentVMDiskFileID] = currentDiskEntry[mkResourceVirtualEnvironmentVMDiskFileID]
 		}
@@ -2385,6 +3076,126 @@ func resourceVirtualEnvironmentVMReadCustom(d *schema.ResourceData, m interface{
 		d.Set(mkResourceVirtualEnvironmentVMDisk, diskList)
 	}
 
+	// Compare the EFI disk to the one stored in the state.
+	currentEFIDisk := d.Get(mkResourceVirtualEnvironmentVMEFIDisk).([]interface{})
+
+	efiDiskList := []interface{}{}
+
+	if vmConfig.EFIDisk != nil {
+		efiDisk := map[string]interface{}{}
+		fileIDParts := strings.Split(vmConfig.EFIDisk.FileVolume, ":")
+
+		efiDisk[mkResourceVirtualEnvironmentVMEFIDiskDatastoreID] = fileIDParts[0]
+
+		if len(currentEFIDisk) > 0 && currentEFIDisk[0] != nil {
+			efiDisk[mkResourceVirtualEnvironmentVMEFIDiskDatastoreID] = resourceVirtualEnvironmentVMGetMigratedDatastoreID(
+				d,
+				currentEFIDisk[0].(map[string]interface{})[mkResourceVirtualEnvironmentVMEFIDiskDatastoreID],
+				fileIDParts[0],
+			)
+		}
+
+		// The file format is only part of the configuration, if it cannot be derived from the volume name.
+		if vmConfig.EFIDisk.Format != nil {
+			efiDisk[mkResourceVirtualEnvironmentVMEFIDiskFileFormat] = *vmConfig.EFIDisk.Format
+		} else if strings.HasSuffix(vmConfig.EFIDisk.FileVolume, ".qcow2") {
+			efiDisk[mkResourceVirtualEnvironmentVMEFIDiskFileFormat] = "qcow2"
+		} else if strings.HasSuffix(vmConfig.EFIDisk.FileVolume, ".vmdk") {
+			efiDisk[mkResourceVirtualEnvironmentVMEFIDiskFileFormat] = "vmdk"
+		} else {
+			efiDisk[mkResourceVirtualEnvironmentVMEFIDiskFileFormat] = "raw"
+		}
+
+		if vmConfig.EFIDisk.PreEnrolledKeys != nil {
+			efiDisk[mkResourceVirtualEnvironmentVMEFIDiskPreEnrolledKeys] = bool(*vmConfig.EFIDisk.PreEnrolledKeys)
+		} else {
+			efiDisk[mkResourceVirtualEnvironmentVMEFIDiskPreEnrolledKeys] = false
+		}
+
+		// The type is only part of the configuration for 4 MB disks, which is why the current value is otherwise kept.
+		if vmConfig.EFIDisk.Type != nil {
+			efiDisk[mkResourceVirtualEnvironmentVMEFIDiskType] = *vmConfig.EFIDisk.Type
+		} else if len(currentEFIDisk) > 0 && currentEFIDisk[0] != nil {
+			efiDisk[mkResourceVirtualEnvironmentVMEFIDiskType] = currentEFIDisk[0].(map[string]interface{})[mkResourceVirtualEnvironmentVMEFIDiskType]
+		}
+
+		efiDiskList = append(efiDiskList, efiDisk)
+	}
+
+	if len(clone) == 0 || len(currentEFIDisk) > 0 {
+		d.Set(mkResourceVirtualEnvironmentVMEFIDisk, efiDiskList)
+	}
+
+	// Compare the host PCI devices to those stored in the state.
+	currentHostPCI := d.Get(mkResourceVirtualEnvironmentVMHostPCI).([]interface{})
+
+	hostPCIDevices := []interface{}{}
+	hostPCIDevicesArray := []*proxmox.CustomPCIDevice{
+		vmConfig.PCIDevice0,
+		vmConfig.PCIDevice1,
+		vmConfig.PCIDevice2,
+		vmConfig.PCIDevice3,
+		vmConfig.PCIDevice4,
+		vmConfig.PCIDevice5,
+		vmConfig.PCIDevice6,
+		vmConfig.PCIDevice7,
+		vmConfig.PCIDevice8,
+		vmConfig.PCIDevice9,
+		vmConfig.PCIDevice10,
+		vmConfig.PCIDevice11,
+		vmConfig.PCIDevice12,
+		vmConfig.PCIDevice13,
+		vmConfig.PCIDevice14,
+		vmConfig.PCIDevice15,
+	}
+
+	// Unused slots are skipped, as the devices are renumbered, once they are updated.
+	for _, hp := range hostPCIDevicesArray {
+		if hp == nil {
+			continue
+		}
+
+		m := map[string]interface{}{
+			mkResourceVirtualEnvironmentVMHostPCIID:      strings.Join(hp.DeviceIDs, ";"),
+			mkResourceVirtualEnvironmentVMHostPCIMapping: dvResourceVirtualEnvironmentVMHostPCIMapping,
+			mkResourceVirtualEnvironmentVMHostPCIMDev:    dvResourceVirtualEnvironmentVMHostPCIMDev,
+			mkResourceVirtualEnvironmentVMHostPCIPCIE:    dvResourceVirtualEnvironmentVMHostPCIPCIE,
+			mkResourceVirtualEnvironmentVMHostPCIROMBAR:  dvResourceVirtualEnvironmentVMHostPCIROMBAR,
+			mkResourceVirtualEnvironmentVMHostPCIROMFile: dvResourceVirtualEnvironmentVMHostPCIROMFile,
+			mkResourceVirtualEnvironmentVMHostPCIXVGA:    dvResourceVirtualEnvironmentVMHostPCIXVGA,
+		}
+
+		if hp.Mapping != nil {
+			m[mkResourceVirtualEnvironmentVMHostPCIMapping] = *hp.Mapping
+		}
+
+		if hp.DevicePath != nil {
+			m[mkResourceVirtualEnvironmentVMHostPCIMDev] = *hp.DevicePath
+		}
+
+		if hp.PCIExpress != nil {
+			m[mkResourceVirtualEnvironmentVMHostPCIPCIE] = bool(*hp.PCIExpress)
+		}
+
+		if hp.ROMBAR != nil {
+			m[mkResourceVirtualEnvironmentVMHostPCIROMBAR] = bool(*hp.ROMBAR)
+		}
+
+		if hp.ROMFile != nil {
+			m[mkResourceVirtualEnvironmentVMHostPCIROMFile] = *hp.ROMFile
+		}
+
+		if hp.XVGA != nil {
+			m[mkResourceVirtualEnvironmentVMHostPCIXVGA] = bool(*hp.XVGA)
+		}
+
+		hostPCIDevices = append(hostPCIDevices, m)
+	}
+
+	if len(clone) == 0 || len(currentHostPCI) > 0 {
+		d.Set(mkResourceVirtualEnvironmentVMHostPCI, hostPCIDevices)
+	}
+
 	// Compare the initialization configuration to the one stored in the state.
 	initialization := map[string]interface{}{}
 
@@ -2519,15 +3330,18 @@ func resourceVirtualEnvironmentVMReadCustom(d *schema.ResourceData, m interface{
 
 	currentInitialization := d.Get(mkResourceVirtualEnvironmentVMInitialization).([]interface{})
 
+	// The initialization block is only present, if the VM has a cloud-init drive.
+	_, cloudInitDriveExists := initialization[mkResourceVirtualEnvironmentVMInitializationDatastoreID]
+
 	if len(clone) > 0 {
 		if len(currentInitialization) > 0 {
-			if len(initialization) > 0 {
+			if cloudInitDriveExists {
 				d.Set(mkResourceVirtualEnvironmentVMInitialization, []interface{}{initialization})
 			} else {
 				d.Set(mkResourceVirtualEnvironmentVMInitialization, []interface{}{})
 			}
 		}
-	} else if len(initialization) > 0 {
+	} else if cloudInitDriveExists {
 		d.Set(mkResourceVirtualEnvironmentVMInitialization, []interface{}{initialization})
 	} else {
 		d.Set(mkResourceVirtualEnvironmentVMInitialization, []interface{}{})
@@ -2699,6 +3513,81 @@ func resourceVirtualEnvironmentVMReadCustom(d *schema.ResourceData, m interface{
 		d.Set(mkResourceVirtualEnvironmentVMSerialDevice, serialDevices[:serialDevicesCount])
 	}
 
+	// Compare the TPM state to the one stored in the state.
+	currentTPMState := d.Get(mkResourceVirtualEnvironmentVMTPMState).([]interface{})
+
+	tpmStateList := []interface{}{}
+
+	if vmConfig.TPMState != nil {
+		tpmState := map[string]interface{}{}
+		fileIDParts := strings.Split(vmConfig.TPMState.FileVolume, ":")
+
+		tpmState[mkResourceVirtualEnvironmentVMTPMStateDatastoreID] = fileIDParts[0]
+
+		if len(currentTPMState) > 0 && currentTPMState[0] != nil {
+			tpmState[mkResourceVirtualEnvironmentVMTPMStateDatastoreID] = resourceVirtualEnvironmentVMGetMigratedDatastoreID(
+				d,
+				currentTPMState[0].(map[string]interface{})[mkResourceVirtualEnvironmentVMTPMStateDatastoreID],
+				fileIDParts[0],
+			)
+		}
+
+		if vmConfig.TPMState.Version != nil {
+			tpmState[mkResourceVirtualEnvironmentVMTPMStateVersion] = *vmConfig.TPMState.Version
+		} else {
+			tpmState[mkResourceVirtualEnvironmentVMTPMStateVersion] = "v1.2"
+		}
+
+		tpmStateList = append(tpmStateList, tpmState)
+	}
+
+	if len(clone) == 0 || len(currentTPMState) > 0 {
+		d.Set(mkResourceVirtualEnvironmentVMTPMState, tpmStateList)
+	}
+
+	// Compare the USB devices to those stored in the state.
+	currentUSB := d.Get(mkResourceVirtualEnvironmentVMUSB).([]interface{})
+
+	usbDevices := []interface{}{}
+	usbDevicesArray := []*proxmox.CustomUSBDevice{
+		vmConfig.USBDevice0,
+		vmConfig.USBDevice1,
+		vmConfig.USBDevice2,
+		vmConfig.USBDevice3,
+		vmConfig.USBDevice4,
+		vmConfig.USBDevice5,
+		vmConfig.USBDevice6,
+		vmConfig.USBDevice7,
+		vmConfig.USBDevice8,
+		vmConfig.USBDevice9,
+		vmConfig.USBDevice10,
+		vmConfig.USBDevice11,
+		vmConfig.USBDevice12,
+		vmConfig.USBDevice13,
+	}
+
+	// Unused slots are skipped, as the devices are renumbered, once they are updated.
+	for _, ud := range usbDevicesArray {
+		if ud == nil {
+			continue
+		}
+
+		m := map[string]interface{}{}
+		m[mkResourceVirtualEnvironmentVMUSBHost] = ud.HostDevice
+
+		if ud.USB3 != nil {
+			m[mkResourceVirtualEnvironmentVMUSBUSB3] = bool(*ud.USB3)
+		} else {
+			m[mkResourceVirtualEnvironmentVMUSBUSB3] = false
+		}
+
+		usbDevices = append(usbDevices, m)
+	}
+
+	if len(clone) == 0 || len(currentUSB) > 0 {
+		d.Set(mkResourceVirtualEnvironmentVMUSB, usbDevices)
+	}
+
 	// Compare the VGA configuration to the one stored in the state.
 	vga := map[string]interface{}{}
 
@@ -2779,7 +3668,7 @@ func resourceVirtualEnvironmentVMReadNetworkValues(d *schema.ResourceData, m int
 			}
 
 			macAddresses := []interface{}{}
-			networkInterfaces, err := veClient.WaitForNetworkInterfacesFromVMAgent(nodeName, vmID, int(agentTimeout.Seconds()), 5, true)
+			networkInterfaces, err := veClient.WaitForNetworkInterfacesFromVMAgentWithContext(config.stopContext, nodeName, vmID, int(agentTimeout.Seconds()), 5, true)
 
 			if err == nil && networkInterfaces.Result != nil {
 				ipv4Addresses = make([]interface{}, len(*networkInterfaces.Result))
@@ -2921,6 +3810,15 @@ func resourceVirtualEnvironmentVMUpdate(d *schema.ResourceData, m interface{}) e
 		return err
 	}
 
+	// Migrate the VM to the new node, before applying the remaining changes on that node.
+	if d.HasChange(mkResourceVirtualEnvironmentVMNodeName) {
+		err = resourceVirtualEnvironmentVMMigrate(d, m, vmID)
+
+		if err != nil {
+			return err
+		}
+	}
+
 	updateBody := &proxmox.VirtualEnvironmentVMUpdateRequestBody{
 		IDEDevices: proxmox.CustomStorageDevices{
 			proxmox.CustomStorageDevice{
@@ -3138,6 +4036,36 @@ func resourceVirtualEnvironmentVMUpdate(d *schema.ResourceData, m interface{}) e
 		rebootRequired = true
 	}
 
+	// Add or remove the EFI disk, as any other change requires the VM to be re-created.
+	if d.HasChange(mkResourceVirtualEnvironmentVMEFIDisk) {
+		updateBody.EFIDisk, err = resourceVirtualEnvironmentVMGetEFIDiskObject(d, m)
+
+		if err != nil {
+			return err
+		}
+
+		if updateBody.EFIDisk == nil {
+			delete = append(delete, "efidisk0")
+		}
+
+		rebootRequired = true
+	}
+
+	// Prepare the new host PCI devices.
+	if d.HasChange(mkResourceVirtualEnvironmentVMHostPCI) {
+		updateBody.PCIDevices, err = resourceVirtualEnvironmentVMGetHostPCIDeviceObjects(d, m)
+
+		if err != nil {
+			return err
+		}
+
+		for i := len(updateBody.PCIDevices); i < maxResourceVirtualEnvironmentVMHostPCIDevices; i++ {
+			delete = append(delete, fmt.Sprintf("hostpci%d", i))
+		}
+
+		rebootRequired = true
+	}
+
 	// Prepare the new cloud-init configuration.
 	if d.HasChange(mkResourceVirtualEnvironmentVMInitialization) {
 		initializationConfig, err := resourceVirtualEnvironmentVMGetCloudInitConfig(d, m)
@@ -3249,6 +4177,38 @@ func resourceVirtualEnvironmentVMUpdate(d *schema.ResourceData, m interface{}) e
 		rebootRequired = true
 	}
 
+	// Add or remove the TPM state, as any other change requires the VM to be re-created.
+	if d.HasChange(mkResourceVirtualEnvironmentVMTPMState) {
+		updateBody.TPMState, err = resourceVirtualEnvironmentVMGetTPMStateObject(d, m)
+
+		if err != nil {
+			return err
+		}
+
+		if updateBody.TPMState == nil {
+			delete = append(delete, "tpmstate0")
+		}
+
+		rebootRequired = true
+	}
+
+	// Prepare the new USB devices, which can be hotplugged, unless USB hotplugging has been disabled for the VM.
+	if d.HasChange(mkResourceVirtualEnvironmentVMUSB) {
+		updateBody.USBDevices, err = resourceVirtualEnvironmentVMGetUSBDeviceObjects(d, m)
+
+		if err != nil {
+			return err
+		}
+
+		for i := len(updateBody.USBDevices); i < maxResourceVirtualEnvironmentVMUSBDevices; i++ {
+			delete = append(delete, fmt.Sprintf("usb%d", i))
+		}
+
+		if !resourceVirtualEnvironmentVMIsHotplugEnabled(vmConfig, "usb") {
+			rebootRequired = true
+		}
+	}
+
 	// Prepare the new VGA configuration.
 	if d.HasChange(mkResourceVirtualEnvironmentVMVGA) {
 		updateBody.VGADevice, err = resourceVirtualEnvironmentVMGetVGADeviceObject(d, m)
@@ -3274,13 +4234,19 @@ func resourceVirtualEnvironmentVMUpdate(d *schema.ResourceData, m interface{}) e
 
 	if d.HasChange(mkResourceVirtualEnvironmentVMStarted) && !bool(template) {
 		if started {
-			err = veClient.StartVM(nodeName, vmID)
+			taskID, err := veClient.StartVMWithContext(config.stopContext, nodeName, vmID)
+
+			if err != nil {
+				return err
+			}
+
+			err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, 120, 5)
 
 			if err != nil {
 				return err
 			}
 
-			err = veClient.WaitForVMState(nodeName, vmID, "running", 120, 5)
+			err = veClient.WaitForVMStateWithContext(config.stopContext, nodeName, vmID, "running", 120, 5)
 
 			if err != nil {
 				return err
@@ -3289,7 +4255,7 @@ func resourceVirtualEnvironmentVMUpdate(d *schema.ResourceData, m interface{}) e
 			forceStop := proxmox.CustomBool(true)
 			shutdownTimeout := 300
 
-			err = veClient.ShutdownVM(nodeName, vmID, &proxmox.VirtualEnvironmentVMShutdownRequestBody{
+			taskID, err := veClient.ShutdownVMWithContext(config.stopContext, nodeName, vmID, &proxmox.VirtualEnvironmentVMShutdownRequestBody{
 				ForceStop: &forceStop,
 				Timeout:   &shutdownTimeout,
 			})
@@ -3298,7 +4264,7 @@ func resourceVirtualEnvironmentVMUpdate(d *schema.ResourceData, m interface{}) e
 				return err
 			}
 
-			err = veClient.WaitForVMState(nodeName, vmID, "stopped", 30, 5)
+			err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, shutdownTimeout+30, 5)
 
 			if err != nil {
 				return err
@@ -3312,7 +4278,7 @@ func resourceVirtualEnvironmentVMUpdate(d *schema.ResourceData, m interface{}) e
 	if !bool(template) && rebootRequired {
 		rebootTimeout := 300
 
-		err = veClient.RebootVM(nodeName, vmID, &proxmox.VirtualEnvironmentVMRebootRequestBody{
+		taskID, err := veClient.RebootVMWithContext(config.stopContext, nodeName, vmID, &proxmox.VirtualEnvironmentVMRebootRequestBody{
 			Timeout: &rebootTimeout,
 		})
 
@@ -3320,9 +4286,15 @@ func resourceVirtualEnvironmentVMUpdate(d *schema.ResourceData, m interface{}) e
 			return err
 		}
 
+		err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, rebootTimeout+30, 5)
+
+		if err != nil {
+			return err
+		}
+
 		// Wait for the agent to unpublish the network interfaces, if it's enabled.
 		if vmConfig.Agent != nil && vmConfig.Agent.Enabled != nil && *vmConfig.Agent.Enabled {
-			err = veClient.WaitForNoNetworkInterfacesFromVMAgent(nodeName, vmID, 300, 5)
+			err = veClient.WaitForNoNetworkInterfacesFromVMAgentWithContext(config.stopContext, nodeName, vmID, 300, 5)
 
 			if err != nil {
 				return err
@@ -3352,6 +4324,12 @@ func resourceVirtualEnvironmentVMDelete(d *schema.ResourceData, m interface{}) e
 	status, err := veClient.GetVMStatus(nodeName, vmID)
 
 	if err != nil {
+		if proxmox.IsNotFound(err) {
+			d.SetId("")
+
+			return nil
+		}
+
 		return err
 	}
 
@@ -3359,7 +4337,7 @@ func resourceVirtualEnvironmentVMDelete(d *schema.ResourceData, m interface{}) e
 		forceStop := proxmox.CustomBool(true)
 		shutdownTimeout := 300
 
-		err = veClient.ShutdownVM(nodeName, vmID, &proxmox.VirtualEnvironmentVMShutdownRequestBody{
+		taskID, err := veClient.ShutdownVMWithContext(config.stopContext, nodeName, vmID, &proxmox.VirtualEnvironmentVMShutdownRequestBody{
 			ForceStop: &forceStop,
 			Timeout:   &shutdownTimeout,
 		})
@@ -3368,18 +4346,17 @@ func resourceVirtualEnvironmentVMDelete(d *schema.ResourceData, m interface{}) e
 			return err
 		}
 
-		err = veClient.WaitForVMState(nodeName, vmID, "stopped", 30, 5)
+		err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, shutdownTimeout+30, 5)
 
 		if err != nil {
 			return err
 		}
 	}
 
-	err = veClient.DeleteVM(nodeName, vmID)
+	taskID, err := veClient.DeleteVMWithContext(config.stopContext, nodeName, vmID)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") ||
-			(strings.Contains(err.Error(), "HTTP 500") && strings.Contains(err.Error(), "does not exist")) {
+		if proxmox.IsNotFound(err) {
 			d.SetId("")
 
 			return nil
@@ -3388,11 +4365,10 @@ func resourceVirtualEnvironmentVMDelete(d *schema.ResourceData, m interface{}) e
 		return err
 	}
 
-	// Wait for the state to become unavailable as that clearly indicates the destruction of the VM.
-	err = veClient.WaitForVMState(nodeName, vmID, "", 60, 2)
+	err = veClient.WaitForTaskWithContext(config.stopContext, nodeName, *taskID, 600, 2)
 
-	if err == nil {
-		return fmt.Errorf("Failed to delete VM \"%d\"", vmID)
+	if err != nil {
+		return err
 	}
 
 	d.SetId("")
@@ -5,9 +5,12 @@
 package proxmoxtf
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/danitso/terraform-provider-proxmox/proxmoxtest"
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
 )
 
 // TestResourceVirtualEnvironmentVMInstantiation tests whether the ResourceVirtualEnvironmentVM instance can be instantiated.
@@ -37,9 +40,14 @@ func TestResourceVirtualEnvironmentVMSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentVMCPU,
 		mkResourceVirtualEnvironmentVMDescription,
 		mkResourceVirtualEnvironmentVMDisk,
+		mkResourceVirtualEnvironmentVMEFIDisk,
+		mkResourceVirtualEnvironmentVMHostPCI,
 		mkResourceVirtualEnvironmentVMInitialization,
 		mkResourceVirtualEnvironmentVMKeyboardLayout,
 		mkResourceVirtualEnvironmentVMMemory,
+		mkResourceVirtualEnvironmentVMMigrate,
+		mkResourceVirtualEnvironmentVMMigrateTargetStorage,
+		mkResourceVirtualEnvironmentVMMigrateTimeout,
 		mkResourceVirtualEnvironmentVMName,
 		mkResourceVirtualEnvironmentVMNetworkDevice,
 		mkResourceVirtualEnvironmentVMOperatingSystem,
@@ -48,6 +56,8 @@ func TestResourceVirtualEnvironmentVMSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentVMStarted,
 		mkResourceVirtualEnvironmentVMTabletDevice,
 		mkResourceVirtualEnvironmentVMTemplate,
+		mkResourceVirtualEnvironmentVMTPMState,
+		mkResourceVirtualEnvironmentVMUSB,
 		mkResourceVirtualEnvironmentVMVMID,
 	})
 
@@ -67,11 +77,16 @@ func TestResourceVirtualEnvironmentVMSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentVMCPU:                   schema.TypeList,
 		mkResourceVirtualEnvironmentVMDescription:           schema.TypeString,
 		mkResourceVirtualEnvironmentVMDisk:                  schema.TypeList,
+		mkResourceVirtualEnvironmentVMEFIDisk:               schema.TypeList,
+		mkResourceVirtualEnvironmentVMHostPCI:               schema.TypeList,
 		mkResourceVirtualEnvironmentVMInitialization:        schema.TypeList,
 		mkResourceVirtualEnvironmentVMIPv4Addresses:         schema.TypeList,
 		mkResourceVirtualEnvironmentVMIPv6Addresses:         schema.TypeList,
 		mkResourceVirtualEnvironmentVMKeyboardLayout:        schema.TypeString,
 		mkResourceVirtualEnvironmentVMMemory:                schema.TypeList,
+		mkResourceVirtualEnvironmentVMMigrate:               schema.TypeBool,
+		mkResourceVirtualEnvironmentVMMigrateTargetStorage:  schema.TypeMap,
+		mkResourceVirtualEnvironmentVMMigrateTimeout:        schema.TypeString,
 		mkResourceVirtualEnvironmentVMName:                  schema.TypeString,
 		mkResourceVirtualEnvironmentVMNetworkDevice:         schema.TypeList,
 		mkResourceVirtualEnvironmentVMMACAddresses:          schema.TypeList,
@@ -82,6 +97,8 @@ func TestResourceVirtualEnvironmentVMSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentVMStarted:               schema.TypeBool,
 		mkResourceVirtualEnvironmentVMTabletDevice:          schema.TypeBool,
 		mkResourceVirtualEnvironmentVMTemplate:              schema.TypeBool,
+		mkResourceVirtualEnvironmentVMTPMState:              schema.TypeList,
+		mkResourceVirtualEnvironmentVMUSB:                   schema.TypeList,
 		mkResourceVirtualEnvironmentVMVMID:                  schema.TypeInt,
 	})
 
@@ -195,6 +212,44 @@ func TestResourceVirtualEnvironmentVMSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentVMDiskSpeedWriteBurstable: schema.TypeInt,
 	})
 
+	efiDiskSchema := testNestedSchemaExistence(t, s, mkResourceVirtualEnvironmentVMEFIDisk)
+
+	testOptionalArguments(t, efiDiskSchema, []string{
+		mkResourceVirtualEnvironmentVMEFIDiskDatastoreID,
+		mkResourceVirtualEnvironmentVMEFIDiskFileFormat,
+		mkResourceVirtualEnvironmentVMEFIDiskPreEnrolledKeys,
+		mkResourceVirtualEnvironmentVMEFIDiskType,
+	})
+
+	testValueTypes(t, efiDiskSchema, map[string]schema.ValueType{
+		mkResourceVirtualEnvironmentVMEFIDiskDatastoreID:     schema.TypeString,
+		mkResourceVirtualEnvironmentVMEFIDiskFileFormat:      schema.TypeString,
+		mkResourceVirtualEnvironmentVMEFIDiskPreEnrolledKeys: schema.TypeBool,
+		mkResourceVirtualEnvironmentVMEFIDiskType:            schema.TypeString,
+	})
+
+	hostPCISchema := testNestedSchemaExistence(t, s, mkResourceVirtualEnvironmentVMHostPCI)
+
+	testOptionalArguments(t, hostPCISchema, []string{
+		mkResourceVirtualEnvironmentVMHostPCIID,
+		mkResourceVirtualEnvironmentVMHostPCIMapping,
+		mkResourceVirtualEnvironmentVMHostPCIMDev,
+		mkResourceVirtualEnvironmentVMHostPCIPCIE,
+		mkResourceVirtualEnvironmentVMHostPCIROMBAR,
+		mkResourceVirtualEnvironmentVMHostPCIROMFile,
+		mkResourceVirtualEnvironmentVMHostPCIXVGA,
+	})
+
+	testValueTypes(t, hostPCISchema, map[string]schema.ValueType{
+		mkResourceVirtualEnvironmentVMHostPCIID:      schema.TypeString,
+		mkResourceVirtualEnvironmentVMHostPCIMapping: schema.TypeString,
+		mkResourceVirtualEnvironmentVMHostPCIMDev:    schema.TypeString,
+		mkResourceVirtualEnvironmentVMHostPCIPCIE:    schema.TypeBool,
+		mkResourceVirtualEnvironmentVMHostPCIROMBAR:  schema.TypeBool,
+		mkResourceVirtualEnvironmentVMHostPCIROMFile: schema.TypeString,
+		mkResourceVirtualEnvironmentVMHostPCIXVGA:    schema.TypeBool,
+	})
+
 	initializationSchema := testNestedSchemaExistence(t, s, mkResourceVirtualEnvironmentVMInitialization)
 
 	testOptionalArguments(t, initializationSchema, []string{
@@ -327,6 +382,33 @@ func TestResourceVirtualEnvironmentVMSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentVMSerialDeviceDevice: schema.TypeString,
 	})
 
+	tpmStateSchema := testNestedSchemaExistence(t, s, mkResourceVirtualEnvironmentVMTPMState)
+
+	testOptionalArguments(t, tpmStateSchema, []string{
+		mkResourceVirtualEnvironmentVMTPMStateDatastoreID,
+		mkResourceVirtualEnvironmentVMTPMStateVersion,
+	})
+
+	testValueTypes(t, tpmStateSchema, map[string]schema.ValueType{
+		mkResourceVirtualEnvironmentVMTPMStateDatastoreID: schema.TypeString,
+		mkResourceVirtualEnvironmentVMTPMStateVersion:     schema.TypeString,
+	})
+
+	usbSchema := testNestedSchemaExistence(t, s, mkResourceVirtualEnvironmentVMUSB)
+
+	testRequiredArguments(t, usbSchema, []string{
+		mkResourceVirtualEnvironmentVMUSBHost,
+	})
+
+	testOptionalArguments(t, usbSchema, []string{
+		mkResourceVirtualEnvironmentVMUSBUSB3,
+	})
+
+	testValueTypes(t, usbSchema, map[string]schema.ValueType{
+		mkResourceVirtualEnvironmentVMUSBHost: schema.TypeString,
+		mkResourceVirtualEnvironmentVMUSBUSB3: schema.TypeBool,
+	})
+
 	vgaSchema := testNestedSchemaExistence(t, s, mkResourceVirtualEnvironmentVMVGA)
 
 	testOptionalArguments(t, vgaSchema, []string{
@@ -341,3 +423,653 @@ func TestResourceVirtualEnvironmentVMSchema(t *testing.T) {
 		mkResourceVirtualEnvironmentVMVGAType:    schema.TypeString,
 	})
 }
+
+// TestResourceVirtualEnvironmentVMLifecycle tests whether a VM can be created, read and deleted, and whether changes
+// made outside of Terraform are detected.
+func TestResourceVirtualEnvironmentVMLifecycle(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentVM()
+
+	d := schema.TestResourceDataRaw(t, s.Schema, map[string]interface{}{
+		mkResourceVirtualEnvironmentVMName:     "test",
+		mkResourceVirtualEnvironmentVMNodeName: proxmoxtest.DefaultNodeName,
+	})
+
+	err := s.Create(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to create VM: %s", err.Error())
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		vm, ok := state.Guests[100]
+
+		if !ok || vm.Config["name"] != "test" || vm.Status != "running" {
+			t.Fatalf("Expected VM 100 to be named \"test\" and to be running - got: %v", vm)
+		}
+
+		vm.Config["memory"] = "2048"
+	})
+
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read VM: %s", err.Error())
+	}
+
+	memory := d.Get(mkResourceVirtualEnvironmentVMMemory).([]interface{})[0].(map[string]interface{})
+
+	if memory[mkResourceVirtualEnvironmentVMMemoryDedicated].(int) != 2048 {
+		t.Fatalf("Expected the dedicated memory to be 2048 - got: %v", memory[mkResourceVirtualEnvironmentVMMemoryDedicated])
+	}
+
+	err = s.Delete(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to delete VM: %s", err.Error())
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		if _, ok := state.Guests[100]; ok {
+			t.Fatalf("Expected VM 100 to be deleted")
+		}
+	})
+}
+
+// TestResourceVirtualEnvironmentVMEFIDiskAndTPMState tests whether an EFI disk and a TPM state are added to and removed
+// from a VM in place, and whether only changes to their properties require the VM to be re-created.
+func TestResourceVirtualEnvironmentVMEFIDiskAndTPMState(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentVM()
+
+	efiDisk := map[string]interface{}{
+		mkResourceVirtualEnvironmentVMEFIDiskPreEnrolledKeys: true,
+		mkResourceVirtualEnvironmentVMEFIDiskType:            "4m",
+	}
+	tpmState := map[string]interface{}{}
+	raw := map[string]interface{}{
+		mkResourceVirtualEnvironmentVMBIOS:     "ovmf",
+		mkResourceVirtualEnvironmentVMNodeName: proxmoxtest.DefaultNodeName,
+		mkResourceVirtualEnvironmentVMTPMState: []interface{}{tpmState},
+	}
+
+	d := schema.TestResourceDataRaw(t, s.Schema, raw)
+	err := s.Create(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to create VM: %s", err.Error())
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		vm := state.Guests[100]
+
+		if !strings.HasPrefix(vm.Config["tpmstate0"], "local-lvm:vm-100-disk-") || !strings.Contains(vm.Config["tpmstate0"], "version=v2.0") {
+			t.Fatalf("Expected a TPM 2.0 state disk to be allocated - got: %s", vm.Config["tpmstate0"])
+		}
+	})
+
+	applyInPlace := func(state *terraform.InstanceState) *terraform.InstanceState {
+		diff, err := s.Diff(state, terraform.NewResourceConfigRaw(raw), config)
+
+		if err != nil {
+			t.Fatalf("Failed to compute the differences: %s", err.Error())
+		}
+
+		if diff == nil || diff.RequiresNew() {
+			t.Fatalf("Expected the VM to be updated in place - got: %v", diff)
+		}
+
+		state, err = s.Apply(state, diff, config)
+
+		if err != nil {
+			t.Fatalf("Failed to update VM: %s", err.Error())
+		}
+
+		return state
+	}
+
+	// Adding an EFI disk to an existing VM must not re-create it.
+	raw[mkResourceVirtualEnvironmentVMEFIDisk] = []interface{}{efiDisk}
+
+	state := applyInPlace(d.State())
+
+	server.Do(func(state *proxmoxtest.State) {
+		vm := state.Guests[100]
+
+		if !strings.HasPrefix(vm.Config["efidisk0"], "local-lvm:vm-100-disk-") || !strings.Contains(vm.Config["efidisk0"], "efitype=4m") || !strings.Contains(vm.Config["efidisk0"], "pre-enrolled-keys=1") {
+			t.Fatalf("Expected a 4 MB EFI disk with pre-enrolled keys to be allocated - got: %s", vm.Config["efidisk0"])
+		}
+
+		delete(vm.Config, "tpmstate0")
+	})
+
+	// A TPM state, which has been removed outside of Terraform, must be added again in place.
+	d = s.Data(state)
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read VM: %s", err.Error())
+	}
+
+	if len(d.Get(mkResourceVirtualEnvironmentVMTPMState).([]interface{})) != 0 {
+		t.Fatalf("Expected the removal of the TPM state to be detected")
+	}
+
+	state = applyInPlace(d.State())
+
+	server.Do(func(state *proxmoxtest.State) {
+		if _, ok := state.Guests[100].Config["tpmstate0"]; !ok {
+			t.Fatalf("Expected the TPM state to be added again")
+		}
+	})
+
+	// Removing the EFI disk must not re-create the VM either.
+	delete(raw, mkResourceVirtualEnvironmentVMEFIDisk)
+
+	state = applyInPlace(state)
+
+	server.Do(func(state *proxmoxtest.State) {
+		if _, ok := state.Guests[100].Config["efidisk0"]; ok {
+			t.Fatalf("Expected the EFI disk to be removed")
+		}
+	})
+
+	tpmState[mkResourceVirtualEnvironmentVMTPMStateVersion] = "v1.2"
+
+	diff, err := s.Diff(state, terraform.NewResourceConfigRaw(raw), config)
+
+	if err != nil {
+		t.Fatalf("Failed to compute the differences: %s", err.Error())
+	}
+
+	if diff == nil || !diff.RequiresNew() {
+		t.Fatalf("Expected a change of the TPM version to require a new VM - got: %v", diff)
+	}
+
+	efiDisk[mkResourceVirtualEnvironmentVMEFIDiskType] = "2m"
+	raw[mkResourceVirtualEnvironmentVMEFIDisk] = []interface{}{efiDisk}
+	raw[mkResourceVirtualEnvironmentVMVMID] = 101
+
+	err = s.Create(schema.TestResourceDataRaw(t, s.Schema, raw), config)
+
+	if err == nil {
+		t.Fatalf("Expected the creation to fail for a 2 MB EFI disk with pre-enrolled keys")
+	}
+
+	// The type of 2 MB disks must be omitted, as it is not supported by Proxmox VE 6.x.
+	raw = map[string]interface{}{
+		mkResourceVirtualEnvironmentVMBIOS:     "ovmf",
+		mkResourceVirtualEnvironmentVMEFIDisk:  []interface{}{map[string]interface{}{}},
+		mkResourceVirtualEnvironmentVMNodeName: proxmoxtest.DefaultNodeName,
+		mkResourceVirtualEnvironmentVMVMID:     102,
+	}
+
+	d = schema.TestResourceDataRaw(t, s.Schema, raw)
+	err = s.Create(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to create VM: %s", err.Error())
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		efiDisk := state.Guests[102].Config["efidisk0"]
+
+		if efiDisk == "" || strings.Contains(efiDisk, "efitype") || strings.Contains(efiDisk, "pre-enrolled-keys") {
+			t.Fatalf("Expected a 2 MB EFI disk to be allocated without a type and pre-enrolled keys - got: %s", efiDisk)
+		}
+	})
+
+	d.Set(mkResourceVirtualEnvironmentVMEFIDisk, []interface{}{})
+
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read VM: %s", err.Error())
+	}
+
+	efiDiskBlock := d.Get(mkResourceVirtualEnvironmentVMEFIDisk).([]interface{})[0].(map[string]interface{})
+
+	if efiDiskBlock[mkResourceVirtualEnvironmentVMEFIDiskType] != "" {
+		t.Fatalf("Expected the type to be unset, when it is missing from the configuration - got: %v", efiDiskBlock)
+	}
+
+	diff, err = s.Diff(d.State(), terraform.NewResourceConfigRaw(raw), config)
+
+	if err != nil {
+		t.Fatalf("Failed to compute the differences: %s", err.Error())
+	}
+
+	if diff != nil && diff.RequiresNew() {
+		t.Fatalf("Expected a missing type to match the default type - got: %v", diff)
+	}
+}
+
+// TestResourceVirtualEnvironmentVMHostPCI tests whether host PCI devices are passed through to a VM, and whether
+// changes made outside of Terraform are detected and reverted.
+func TestResourceVirtualEnvironmentVMHostPCI(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentVM()
+
+	gpu := map[string]interface{}{
+		mkResourceVirtualEnvironmentVMHostPCIID:   "0000:01:00.0;0000:01:00.1",
+		mkResourceVirtualEnvironmentVMHostPCIPCIE: true,
+		mkResourceVirtualEnvironmentVMHostPCIXVGA: true,
+	}
+	nic := map[string]interface{}{
+		mkResourceVirtualEnvironmentVMHostPCIMapping: "nic",
+		mkResourceVirtualEnvironmentVMHostPCIROMBAR:  false,
+		mkResourceVirtualEnvironmentVMHostPCIROMFile: "nic.rom",
+	}
+	raw := map[string]interface{}{
+		mkResourceVirtualEnvironmentVMHostPCI:  []interface{}{gpu, nic},
+		mkResourceVirtualEnvironmentVMNodeName: proxmoxtest.DefaultNodeName,
+	}
+
+	d := schema.TestResourceDataRaw(t, s.Schema, raw)
+	err := s.Create(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to create VM: %s", err.Error())
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		vm := state.Guests[100]
+
+		if vm.Config["hostpci0"] != "host=0000:01:00.0;0000:01:00.1,pcie=1,x-vga=1" {
+			t.Fatalf("Expected the GPU to be passed through as the primary GPU - got: %s", vm.Config["hostpci0"])
+		}
+
+		if vm.Config["hostpci1"] != "mapping=nic,rombar=0,romfile=nic.rom" {
+			t.Fatalf("Expected the NIC to be passed through by its mapping - got: %s", vm.Config["hostpci1"])
+		}
+
+		vm.Config["hostpci1"] = "0000:02:00.0"
+	})
+
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read VM: %s", err.Error())
+	}
+
+	hostPCI := d.Get(mkResourceVirtualEnvironmentVMHostPCI).([]interface{})
+
+	if len(hostPCI) != 2 {
+		t.Fatalf("Expected 2 host PCI devices - got: %d", len(hostPCI))
+	}
+
+	nicBlock := hostPCI[1].(map[string]interface{})
+
+	if nicBlock[mkResourceVirtualEnvironmentVMHostPCIID] != "0000:02:00.0" || nicBlock[mkResourceVirtualEnvironmentVMHostPCIMapping] != "" || nicBlock[mkResourceVirtualEnvironmentVMHostPCIROMBAR] != true {
+		t.Fatalf("Expected the modified host PCI device to be detected - got: %v", nicBlock)
+	}
+
+	raw[mkResourceVirtualEnvironmentVMHostPCI] = []interface{}{gpu}
+
+	diff, err := s.Diff(d.State(), terraform.NewResourceConfigRaw(raw), config)
+
+	if err != nil {
+		t.Fatalf("Failed to compute the differences: %s", err.Error())
+	}
+
+	state, err := s.Apply(d.State(), diff, config)
+
+	if err != nil {
+		t.Fatalf("Failed to update VM: %s", err.Error())
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		if _, ok := state.Guests[100].Config["hostpci1"]; ok {
+			t.Fatalf("Expected the second host PCI device to be removed")
+		}
+	})
+
+	// Gaps in the numbering of the devices must not result in empty blocks.
+	server.Do(func(state *proxmoxtest.State) {
+		state.Guests[100].Config["hostpci2"] = "0000:03:00.0"
+	})
+
+	d = s.Data(state)
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read VM: %s", err.Error())
+	}
+
+	hostPCI = d.Get(mkResourceVirtualEnvironmentVMHostPCI).([]interface{})
+
+	if len(hostPCI) != 2 || hostPCI[1].(map[string]interface{})[mkResourceVirtualEnvironmentVMHostPCIID] != "0000:03:00.0" {
+		t.Fatalf("Expected the unused slot to be skipped - got: %v", hostPCI)
+	}
+
+	_, err = resourceVirtualEnvironmentVMGetHostPCIDeviceObjects(d, config)
+
+	if err != nil {
+		t.Fatalf("Expected the host PCI devices to remain valid - got: %s", err.Error())
+	}
+
+	raw[mkResourceVirtualEnvironmentVMHostPCI] = []interface{}{map[string]interface{}{}}
+	raw[mkResourceVirtualEnvironmentVMVMID] = 101
+
+	err = s.Create(schema.TestResourceDataRaw(t, s.Schema, raw), config)
+
+	if err == nil {
+		t.Fatalf("Expected the creation to fail for a host PCI device without an ID or a mapping")
+	}
+}
+
+// TestResourceVirtualEnvironmentVMMigration tests whether a VM is migrated, when the node name changes, and whether it
+// is re-created instead, if migrations have been disabled.
+func TestResourceVirtualEnvironmentVMMigration(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	server.Do(func(state *proxmoxtest.State) {
+		state.Datastores["fast"] = &proxmoxtest.Datastore{
+			ContentTypes: []string{"images"},
+			Files:        map[string]*proxmoxtest.DatastoreFile{},
+			Type:         "lvmthin",
+		}
+		state.Datastores["local-lvm"] = &proxmoxtest.Datastore{
+			ContentTypes: []string{"images"},
+			Files:        map[string]*proxmoxtest.DatastoreFile{},
+			Type:         "lvmthin",
+		}
+		state.Nodes["pve2"] = &proxmoxtest.Node{}
+	})
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentVM()
+
+	raw := map[string]interface{}{
+		mkResourceVirtualEnvironmentVMDisk: []interface{}{
+			map[string]interface{}{
+				mkResourceVirtualEnvironmentVMDiskDatastoreID: "local-lvm",
+			},
+		},
+		mkResourceVirtualEnvironmentVMMigrateTargetStorage: map[string]interface{}{"local-lvm": "fast"},
+		mkResourceVirtualEnvironmentVMName:                 "test",
+		mkResourceVirtualEnvironmentVMNodeName:             proxmoxtest.DefaultNodeName,
+	}
+
+	d := schema.TestResourceDataRaw(t, s.Schema, raw)
+	err := s.Create(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to create VM: %s", err.Error())
+	}
+
+	// The fake server does not allocate disks, which is why the disk is added to the configuration directly.
+	server.Do(func(state *proxmoxtest.State) {
+		state.Guests[100].Config["scsi0"] = "local-lvm:vm-100-disk-0,size=8G"
+	})
+
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read VM: %s", err.Error())
+	}
+
+	raw[mkResourceVirtualEnvironmentVMNodeName] = "pve2"
+
+	diff, err := s.Diff(d.State(), terraform.NewResourceConfigRaw(raw), config)
+
+	if err != nil {
+		t.Fatalf("Failed to compute the differences: %s", err.Error())
+	}
+
+	if diff == nil || diff.RequiresNew() {
+		t.Fatalf("Expected the VM to be migrated instead of being replaced - got: %v", diff)
+	}
+
+	state, err := s.Apply(d.State(), diff, config)
+
+	if err != nil {
+		t.Fatalf("Failed to migrate VM: %s", err.Error())
+	}
+
+	if state.Attributes[mkResourceVirtualEnvironmentVMNodeName] != "pve2" {
+		t.Fatalf("Expected the node name to be \"pve2\" - got: %s", state.Attributes[mkResourceVirtualEnvironmentVMNodeName])
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		vm := state.Guests[100]
+
+		if vm == nil || vm.NodeName != "pve2" || vm.Status != "running" {
+			t.Fatalf("Expected VM 100 to be running on node \"pve2\" - got: %v", vm)
+		}
+
+		if !strings.HasPrefix(vm.Config["scsi0"], "fast:") {
+			t.Fatalf("Expected the disk to be moved to the mapped datastore - got: %s", vm.Config["scsi0"])
+		}
+	})
+
+	// The configured datastore must be kept for disks, which have been moved to a mapped datastore.
+	d = s.Data(state)
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read VM: %s", err.Error())
+	}
+
+	if disk := d.Get(mkResourceVirtualEnvironmentVMDisk).([]interface{}); len(disk) != 1 || disk[0].(map[string]interface{})[mkResourceVirtualEnvironmentVMDiskDatastoreID] != "local-lvm" {
+		t.Fatalf("Expected the configured datastore to be kept - got: %v", disk)
+	}
+
+	diff, err = s.Diff(d.State(), terraform.NewResourceConfigRaw(raw), config)
+
+	if err != nil {
+		t.Fatalf("Failed to compute the differences: %s", err.Error())
+	}
+
+	if diff != nil && !diff.Empty() {
+		t.Fatalf("Expected no differences after the migration - got: %v", diff)
+	}
+
+	raw[mkResourceVirtualEnvironmentVMMigrate] = false
+	raw[mkResourceVirtualEnvironmentVMNodeName] = proxmoxtest.DefaultNodeName
+
+	diff, err = s.Diff(state, terraform.NewResourceConfigRaw(raw), config)
+
+	if err != nil {
+		t.Fatalf("Failed to compute the differences: %s", err.Error())
+	}
+
+	if diff == nil || !diff.RequiresNew() {
+		t.Fatalf("Expected the VM to be replaced, when migrations have been disabled - got: %v", diff)
+	}
+}
+
+// TestResourceVirtualEnvironmentVMUSB tests whether USB devices are passed through to a VM, and whether they are
+// hotplugged instead of rebooting the VM, unless USB hotplugging has been disabled.
+func TestResourceVirtualEnvironmentVMUSB(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentVM()
+
+	dongle := map[string]interface{}{
+		mkResourceVirtualEnvironmentVMUSBHost: "046d:c52b",
+	}
+	stick := map[string]interface{}{
+		mkResourceVirtualEnvironmentVMUSBHost: "1-2.3",
+		mkResourceVirtualEnvironmentVMUSBUSB3: true,
+	}
+	raw := map[string]interface{}{
+		mkResourceVirtualEnvironmentVMNodeName: proxmoxtest.DefaultNodeName,
+		mkResourceVirtualEnvironmentVMUSB:      []interface{}{dongle, stick},
+	}
+
+	d := schema.TestResourceDataRaw(t, s.Schema, raw)
+	err := s.Create(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to create VM: %s", err.Error())
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		vm := state.Guests[100]
+
+		if vm.Config["usb0"] != "host=046d:c52b" || vm.Config["usb1"] != "host=1-2.3,usb3=1" {
+			t.Fatalf("Expected the USB devices to be passed through - got: %s and %s", vm.Config["usb0"], vm.Config["usb1"])
+		}
+
+		vm.Config["usb1"] = "host=1-2.4"
+	})
+
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read VM: %s", err.Error())
+	}
+
+	usb := d.Get(mkResourceVirtualEnvironmentVMUSB).([]interface{})
+
+	if len(usb) != 2 || usb[1].(map[string]interface{})[mkResourceVirtualEnvironmentVMUSBHost] != "1-2.4" || usb[1].(map[string]interface{})[mkResourceVirtualEnvironmentVMUSBUSB3] != false {
+		t.Fatalf("Expected the modified USB device to be detected - got: %v", usb)
+	}
+
+	testUpdate := func(devices []interface{}) int {
+		raw[mkResourceVirtualEnvironmentVMUSB] = devices
+
+		diff, err := s.Diff(d.State(), terraform.NewResourceConfigRaw(raw), config)
+
+		if err != nil {
+			t.Fatalf("Failed to compute the differences: %s", err.Error())
+		}
+
+		state, err := s.Apply(d.State(), diff, config)
+
+		if err != nil {
+			t.Fatalf("Failed to update VM: %s", err.Error())
+		}
+
+		d = s.Data(state)
+		reboots := 0
+
+		server.Do(func(state *proxmoxtest.State) {
+			for _, task := range state.Tasks {
+				if task.Type == "qmreboot" {
+					reboots++
+				}
+			}
+		})
+
+		return reboots
+	}
+
+	if reboots := testUpdate([]interface{}{dongle}); reboots != 0 {
+		t.Fatalf("Expected the USB devices to be hotplugged - got: %d reboots", reboots)
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		if _, ok := state.Guests[100].Config["usb1"]; ok {
+			t.Fatalf("Expected the second USB device to be removed")
+		}
+
+		state.Guests[100].Config["usb2"] = "host=1-2.5"
+	})
+
+	// Gaps in the numbering of the devices must not result in empty blocks.
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read VM: %s", err.Error())
+	}
+
+	usb = d.Get(mkResourceVirtualEnvironmentVMUSB).([]interface{})
+
+	if len(usb) != 2 || usb[1].(map[string]interface{})[mkResourceVirtualEnvironmentVMUSBHost] != "1-2.5" {
+		t.Fatalf("Expected the unused slot to be skipped - got: %v", usb)
+	}
+
+	server.Do(func(state *proxmoxtest.State) {
+		state.Guests[100].Config["hotplug"] = "network,disk"
+	})
+
+	if reboots := testUpdate([]interface{}{dongle, stick}); reboots != 1 {
+		t.Fatalf("Expected the VM to be rebooted, when USB hotplugging has been disabled - got: %d reboots", reboots)
+	}
+}
+
+// TestResourceVirtualEnvironmentVMImport tests whether an existing VM can be imported.
+func TestResourceVirtualEnvironmentVMImport(t *testing.T) {
+	server := proxmoxtest.NewServer()
+	defer server.Close()
+
+	server.Do(func(state *proxmoxtest.State) {
+		state.Pools["test"] = &proxmoxtest.Pool{}
+		state.Guests[100] = &proxmoxtest.Guest{
+			Config: map[string]string{
+				"cores":  "2",
+				"memory": "2048",
+				"name":   "test",
+			},
+			NodeName: proxmoxtest.DefaultNodeName,
+			PoolID:   "test",
+			Status:   "stopped",
+			Type:     "qemu",
+		}
+	})
+
+	config := testNewProviderConfiguration(t, server)
+	s := resourceVirtualEnvironmentVM()
+	d := s.Data(nil)
+
+	d.SetId("pve/100")
+
+	imported, err := s.Importer.State(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to import VM: %s", err.Error())
+	}
+
+	d = imported[0]
+	err = s.Read(d, config)
+
+	if err != nil {
+		t.Fatalf("Failed to read VM: %s", err.Error())
+	}
+
+	if d.Id() != "100" || d.Get(mkResourceVirtualEnvironmentVMNodeName).(string) != proxmoxtest.DefaultNodeName || d.Get(mkResourceVirtualEnvironmentVMVMID).(int) != 100 {
+		t.Fatalf("Expected the node name and VM identifier to be derived from the import ID - got: %s", d.Id())
+	}
+
+	if d.Get(mkResourceVirtualEnvironmentVMName).(string) != "test" || d.Get(mkResourceVirtualEnvironmentVMPoolID).(string) != "test" {
+		t.Fatalf("Expected the name and pool ID to be \"test\" - got: %v and %v", d.Get(mkResourceVirtualEnvironmentVMName), d.Get(mkResourceVirtualEnvironmentVMPoolID))
+	}
+
+	if d.Get(mkResourceVirtualEnvironmentVMStarted).(bool) {
+		t.Fatalf("Expected the VM to be stopped")
+	}
+
+	cpu := d.Get(mkResourceVirtualEnvironmentVMCPU).([]interface{})
+
+	if len(cpu) != 1 || cpu[0].(map[string]interface{})[mkResourceVirtualEnvironmentVMCPUCores].(int) != 2 {
+		t.Fatalf("Expected the CPU block to contain 2 cores - got: %v", cpu)
+	}
+
+	memory := d.Get(mkResourceVirtualEnvironmentVMMemory).([]interface{})
+
+	if len(memory) != 1 || memory[0].(map[string]interface{})[mkResourceVirtualEnvironmentVMMemoryDedicated].(int) != 2048 {
+		t.Fatalf("Expected the memory block to contain 2048 megabytes of dedicated memory - got: %v", memory)
+	}
+
+	d.SetId("100")
+
+	_, err = s.Importer.State(d, config)
+
+	if err == nil {
+		t.Fatalf("Expected the import to fail without a node name")
+	}
+}
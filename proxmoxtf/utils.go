@@ -5,15 +5,54 @@
 package proxmoxtf
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/danitso/terraform-provider-proxmox/proxmox"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/helper/validation"
 )
 
+const (
+	vmIDAllocationAttempts = 5
+)
+
+// createWithVMID creates a virtual machine or container and returns its VM identifier along with the task identifier.
+// A VM identifier is allocated, if none has been specified, in which case another one is allocated, if it has been claimed by another process in the meantime.
+func createWithVMID(ctx context.Context, veClient *proxmox.VirtualEnvironmentClient, vmID int, create func(vmID int) (*string, error)) (int, *string, error) {
+	allocateVMID := vmID == -1
+
+	for attempt := 1; ; attempt++ {
+		if allocateVMID {
+			vmIDNew, err := veClient.GetVMIDWithContext(ctx)
+
+			if err != nil {
+				return 0, nil, err
+			}
+
+			vmID = *vmIDNew
+		}
+
+		taskID, err := create(vmID)
+
+		if err == nil {
+			return vmID, taskID, nil
+		}
+
+		if !allocateVMID || !proxmox.IsAlreadyExists(err) || attempt >= vmIDAllocationAttempts {
+			return 0, nil, err
+		}
+
+		log.Printf("[DEBUG] WARNING: VM identifier %d has been claimed by another process - Allocating a new one", vmID)
+	}
+}
+
 func getBIOSValidator() schema.SchemaValidateFunc {
 	return validation.StringInSlice([]string{
 		"ovmf",
@@ -21,6 +60,17 @@ func getBIOSValidator() schema.SchemaValidateFunc {
 	}, false)
 }
 
+func getChecksumAlgorithmValidator() schema.SchemaValidateFunc {
+	return validation.StringInSlice([]string{
+		"md5",
+		"sha1",
+		"sha224",
+		"sha256",
+		"sha384",
+		"sha512",
+	}, false)
+}
+
 func getContentTypeValidator() schema.SchemaValidateFunc {
 	return validation.StringInSlice([]string{
 		"backup",
@@ -138,6 +188,15 @@ func getCPUTypeValidator() schema.SchemaValidateFunc {
 	}, false)
 }
 
+func getDecompressionAlgorithmValidator() schema.SchemaValidateFunc {
+	return validation.StringInSlice([]string{
+		"",
+		"gz",
+		"lzo",
+		"zst",
+	}, false)
+}
+
 func getFileFormatValidator() schema.SchemaValidateFunc {
 	return validation.StringInSlice([]string{
 		"qcow2",
@@ -349,8 +408,8 @@ func getVLANIDsValidator() schema.SchemaValidateFunc {
 
 func getVMIDValidator() schema.SchemaValidateFunc {
 	return func(i interface{}, k string) (ws []string, es []error) {
-		min := 100
-		max := 2147483647
+		min := proxmox.MinimumVMID
+		max := proxmox.MaximumVMID
 
 		v, ok := i.(int)
 
@@ -370,6 +429,48 @@ func getVMIDValidator() schema.SchemaValidateFunc {
 	}
 }
 
+// getVMPoolID returns the ID of the pool, which contains the specified virtual machine or container.
+func getVMPoolID(veClient *proxmox.VirtualEnvironmentClient, vmID int) (string, error) {
+	pools, err := veClient.ListPools()
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range pools {
+		pool, err := veClient.GetPool(p.ID)
+
+		if err != nil {
+			return "", err
+		}
+
+		for _, member := range pool.Members {
+			if member.VMID != nil && *member.VMID == vmID {
+				return p.ID, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// parseVMImportID parses an import ID for a virtual machine or container in the format "<node_name>/<vm_id>".
+func parseVMImportID(id string) (string, int, error) {
+	idParts := strings.Split(id, "/")
+
+	if len(idParts) != 2 || idParts[0] == "" {
+		return "", 0, fmt.Errorf("Invalid import ID \"%s\" (expected \"<node_name>/<vm_id>\")", id)
+	}
+
+	vmID, err := strconv.Atoi(idParts[1])
+
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid VM identifier \"%s\" in import ID \"%s\"", idParts[1], id)
+	}
+
+	return idParts[0], vmID, nil
+}
+
 func testComputedAttributes(t *testing.T, s *schema.Resource, keys []string) {
 	for _, v := range keys {
 		if s.Schema[v] == nil {
@@ -0,0 +1,34 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package proxmoxtf
+
+import (
+	"testing"
+
+	"github.com/danitso/terraform-provider-proxmox/proxmox"
+)
+
+// TestGetVMIDValidator tests whether VM identifiers are limited to the range, which is accepted by Proxmox VE.
+func TestGetVMIDValidator(t *testing.T) {
+	validator := getVMIDValidator()
+
+	for vmID, valid := range map[int]bool{
+		-1:                      true,
+		proxmox.MinimumVMID - 1: false,
+		proxmox.MinimumVMID:     true,
+		proxmox.MaximumVMID:     true,
+		proxmox.MaximumVMID + 1: false,
+	} {
+		_, es := validator(vmID, "vm_id")
+
+		if valid && len(es) > 0 {
+			t.Fatalf("Expected VM identifier %d to be valid - got: %v", vmID, es)
+		}
+
+		if !valid && len(es) == 0 {
+			t.Fatalf("Expected VM identifier %d to be invalid", vmID)
+		}
+	}
+}